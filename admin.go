@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	commandServe = "serve"
+
+	actorAdmin = "admin"
+
+	exportBatchSize = 500
+	importBatchSize = 100
+)
+
+var errCommandUsage = errors.New("invalid usage")
+
+// commandEnv is what an admin command runs with
+type commandEnv struct {
+	config *Config
+	db     *sql.DB
+	events IEventsHandler
+	users  *UsersHandler
+	logger *slog.Logger
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// command is a subcommand of the binary, run with the config loaded the same way as for serving.
+// setup registers the flags of the command, and returns what runs it with the arguments left after them.
+type command struct {
+	usage string
+	setup func(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error
+}
+
+var commands = map[string]command{
+	"migrate": {
+		usage: "migrate [-to version]: take the db schema to the version, the latest by default",
+		setup: setupMigrate,
+	},
+	"create-user": {
+		usage: "create-user <username>: add a user who can be issued tokens",
+		setup: setupCreateUser,
+	},
+	"issue-token": {
+		usage: "issue-token [-name name] [-ttl duration] <username>: print a new api token for the user",
+		setup: setupIssueToken,
+	},
+	"export": {
+		usage: "export [-out file]: write every event as a json line, deleted ones as just their id",
+		setup: setupExport,
+	},
+	"import": {
+		usage: "import [-in file]: apply events written by export, newer ones winning like a sync push",
+		setup: setupImport,
+	},
+	"vacuum-orphans": {
+		usage: "vacuum-orphans [-dry-run]: remove tags no event is tagged with, and attachment content no attachment refers to",
+		setup: setupVacuumOrphans,
+	},
+	"stats": {
+		usage: "stats: print counts of what is in the db",
+		setup: setupStats,
+	},
+}
+
+func commandsUsage() string {
+	names := make([]string, 0)
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usage := "usage: eventtracker [serve] [config flags]\n       eventtracker config print [config flags]\n"
+	for _, name := range names {
+		usage += "       eventtracker " + commands[name].usage + "\n"
+	}
+
+	return usage + "config flags are the same for every command, and can come before or after its own flags\n"
+}
+
+// runCommand runs an admin command, returning the exit code
+func runCommand(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprint(stderr, commandsUsage())
+		return 2
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	run := cmd.setup(flags)
+
+	config, rest, err := loadCommandConfig(args, os.LookupEnv, flags)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		fmt.Fprint(stderr, commandsUsage())
+		return 2
+	}
+
+	logger := newLogger(stderr, config.LogLevel)
+	slog.SetDefault(logger)
+
+	db, err := getDb(config.DB)
+	if err != nil {
+		logger.Error("could not connect to db", errorAttr(err))
+		return 1
+	}
+	defer db.Close()
+
+	events := &EventsHandler{}
+	events.Init(db, handlerOptions(config))
+	users := &UsersHandler{}
+	users.Init(db, handlerOptions(config))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = context.WithValue(ctx, ctxKeyActor, actorAdmin)
+	ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("command", name))
+
+	err = run(ctx, &commandEnv{
+		config: config,
+		db:     db,
+		events: events,
+		users:  users,
+		logger: logger,
+		stdin:  stdin,
+		stdout: stdout,
+	}, rest)
+	if errors.Is(err, errCommandUsage) {
+		fmt.Fprint(stderr, commandsUsage())
+		return 2
+	}
+	if err != nil {
+		logger.Error(name+" failed", errorAttr(err))
+		return 1
+	}
+
+	return 0
+}
+
+func setupMigrate(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	to := flags.Int64("to", -1, "schema version to migrate to, the latest by default")
+
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		fn := "migrate"
+
+		if len(args) > 0 || *to < -1 {
+			return errCommandUsage
+		}
+
+		target := *to
+		if target == -1 {
+			latest, err := latestMigrationVersion()
+			if err != nil {
+				return deepError.New(fn, "latest version", err)
+			}
+			target = latest
+		}
+
+		return runMigrations(ctx, cmd.db, target, cmd.logger)
+	}
+}
+
+func setupCreateUser(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		if len(args) != 1 {
+			return errCommandUsage
+		}
+
+		user, err := cmd.users.CreateUser(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cmd.stdout, "created user", user.Username)
+		return nil
+	}
+}
+
+func setupIssueToken(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	name := flags.String("name", "default", "what the token is for, like the machine using it")
+	ttl := flags.Duration("ttl", 0, "how long the token is valid for, forever by default")
+
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		if len(args) != 1 || *ttl < 0 {
+			return errCommandUsage
+		}
+
+		token, apiToken, err := cmd.users.IssueToken(ctx, args[0], *name, *ttl)
+		if err != nil {
+			return err
+		}
+
+		// the token can't be read back later, so it goes to stdout alone, for scripts to capture
+		fmt.Fprintln(cmd.stdout, token)
+		if apiToken.ExpiresAt != nil {
+			cmd.logger.Info("token issued", "user", args[0], "name", apiToken.Name, "expires_at", apiToken.ExpiresAt.Format(time.RFC3339))
+		} else {
+			cmd.logger.Info("token issued", "user", args[0], "name", apiToken.Name)
+		}
+		return nil
+	}
+}
+
+func setupExport(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	out := flags.String("out", "", "file to write to, stdout by default")
+
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		fn := "export"
+
+		if len(args) > 0 {
+			return errCommandUsage
+		}
+
+		w := cmd.stdout
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return deepError.New(fn, "create file", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		count, err := exportEvents(ctx, cmd.events, w)
+		if err != nil {
+			return deepError.New(fn, "export events", err)
+		}
+
+		cmd.logger.Info("events exported", "count", count)
+		return nil
+	}
+}
+
+// exportEvents writes every event as an EventChange, so that import can apply them like a sync push.
+// Events are read page by page in the order of their changes, the same way syncing clients do.
+func exportEvents(ctx context.Context, handler IEventsHandler, w io.Writer) (int, error) {
+	fn := "exportEvents"
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	since := SyncToken{}
+	for {
+		changes, err := handler.GetChanges(ctx, since, exportBatchSize)
+		if err != nil {
+			return count, deepError.New(fn, "get changes", err)
+		}
+
+		events := append(changes.Created, changes.Updated...)
+		for _, event := range events {
+			err = encoder.Encode(&EventChange{Event: event.Event, ChangedAt: event.UpdatedAt})
+			if err != nil {
+				return count, deepError.New(fn, "encode", err)
+			}
+			count++
+		}
+		for _, tombstone := range changes.Deleted {
+			err = encoder.Encode(&EventChange{Event: &Event{ID: tombstone.ID}, Deleted: true, ChangedAt: tombstone.DeletedAt})
+			if err != nil {
+				return count, deepError.New(fn, "encode", err)
+			}
+			count++
+		}
+
+		if !changes.HasMore {
+			return count, nil
+		}
+
+		since, err = parseSyncToken(changes.Next)
+		if err != nil {
+			return count, deepError.New(fn, "parse next token", err)
+		}
+	}
+}
+
+func setupImport(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	in := flags.String("in", "", "file to read from, stdin by default")
+
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		fn := "import"
+
+		if len(args) > 0 {
+			return errCommandUsage
+		}
+
+		r := cmd.stdin
+		if *in != "" {
+			file, err := os.Open(*in)
+			if err != nil {
+				return deepError.New(fn, "open file", err)
+			}
+			defer file.Close()
+			r = file
+		}
+
+		result, err := importEvents(ctx, cmd.events, r)
+		if err != nil {
+			return deepError.New(fn, "import events", err)
+		}
+
+		cmd.logger.Info("events imported", "applied", len(result.Applied), "conflicts", len(result.Conflicts))
+		for _, conflict := range result.Conflicts {
+			cmd.logger.Warn("event not imported", "event_id", conflict.EventID, "reason", conflict.Reason)
+		}
+		return nil
+	}
+}
+
+// importEvents applies the events written by exportEvents, in batches. Like a sync push, an event
+// the db has a newer version of is left alone and reported as a conflict.
+func importEvents(ctx context.Context, handler IEventsHandler, r io.Reader) (*PushResult, error) {
+	fn := "importEvents"
+
+	result := &PushResult{
+		Applied:   make([]string, 0),
+		Conflicts: make([]*SyncConflict, 0),
+	}
+
+	push := func(changes []*EventChange) error {
+		if len(changes) == 0 {
+			return nil
+		}
+
+		batch, err := handler.PushChanges(ctx, changes)
+		if err != nil {
+			return deepError.New(fn, "push changes", err)
+		}
+
+		result.Applied = append(result.Applied, batch.Applied...)
+		result.Conflicts = append(result.Conflicts, batch.Conflicts...)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), defaultMaxBodyBytes)
+	changes := make([]*EventChange, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		change := &EventChange{}
+		err := json.Unmarshal(scanner.Bytes(), change)
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		err = validateEventChange(change)
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !change.Deleted {
+			err = validateEventFields(ctx, handler, change.Event)
+			if err != nil {
+				return result, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		changes = append(changes, change)
+		if len(changes) == importBatchSize {
+			err = push(changes)
+			if err != nil {
+				return result, err
+			}
+			changes = make([]*EventChange, 0)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, deepError.New(fn, "read", err)
+	}
+
+	return result, push(changes)
+}
+
+func setupVacuumOrphans(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	dryRun := flags.Bool("dry-run", false, "only count the orphans")
+
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		if len(args) > 0 {
+			return errCommandUsage
+		}
+
+		blobs, err := newLocalBlobStore(cmd.config.Attachments.Dir)
+		if err != nil {
+			return err
+		}
+
+		report, err := applyRetention(ctx, cmd.events, blobs, RetentionPolicy{
+			PurgeOrphanTags:  true,
+			PurgeOrphanBlobs: true,
+			BatchSize:        cmd.config.Retention.BatchSize,
+		}, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(cmd.stdout).Encode(report)
+	}
+}
+
+func setupStats(flags *flag.FlagSet) func(ctx context.Context, cmd *commandEnv, args []string) error {
+	return func(ctx context.Context, cmd *commandEnv, args []string) error {
+		fn := "stats"
+
+		if len(args) > 0 {
+			return errCommandUsage
+		}
+
+		stats, err := collectStats(ctx, cmd.db)
+		if err != nil {
+			return deepError.New(fn, "collect", err)
+		}
+
+		encoder := json.NewEncoder(cmd.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+}
+
+// Stats are counts of what is in the db
+type Stats struct {
+	SchemaVersion  int64      `json:"schema_version"`
+	Events         int64      `json:"events"`
+	DeletedEvents  int64      `json:"deleted_events"`
+	ArchivedEvents int64      `json:"archived_events"`
+	EventTypes     int64      `json:"event_types"`
+	EventTags      int64      `json:"event_tags"`
+	OrphanTags     int64      `json:"orphan_tags"`
+	Users          int64      `json:"users"`
+	APITokens      int64      `json:"api_tokens"`
+	AuditEntries   int64      `json:"audit_entries"`
+	FirstEventAt   *time.Time `json:"first_event_at"`
+	LastEventAt    *time.Time `json:"last_event_at"`
+}
+
+func collectStats(ctx context.Context, db *sql.DB) (*Stats, error) {
+	fn := "collectStats"
+
+	stats := &Stats{}
+	var dirty bool
+	var err error
+	stats.SchemaVersion, dirty, err = currentSchemaVersion(ctx, db)
+	if err != nil {
+		return nil, deepError.New(fn, "schema version", err)
+	}
+	if dirty {
+		return nil, fmt.Errorf("schema version %d is dirty", stats.SchemaVersion)
+	}
+
+	counts := []struct {
+		target *int64
+		query  string
+	}{
+		{&stats.Events, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s'", eventsTableName, colStatus, statusActive)},
+		{&stats.DeletedEvents, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s'", eventsTableName, colStatus, statusDeleted)},
+		{&stats.ArchivedEvents, fmt.Sprintf("SELECT COUNT(*) FROM %s", eventsArchiveTableName)},
+		{&stats.EventTypes, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s'", eventTypesTableName, colStatus, statusActive)},
+		{&stats.EventTags, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s'", eventTagsTableName, colStatus, statusActive)},
+		{&stats.OrphanTags, fmt.Sprintf("SELECT COUNT(*) FROM %s T WHERE NOT EXISTS (SELECT 1 FROM %s M WHERE M.%s = T.%s)",
+			eventTagsTableName, eventTagMapTableName, eventTagMapColTagID, colDbID)},
+		{&stats.Users, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s'", usersTableName, colStatus, statusActive)},
+		{&stats.APITokens, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = '%s' AND (%s IS NULL OR %s > UTC_TIMESTAMP(6))",
+			apiTokensTableName, colStatus, statusActive, apiTokensColExpiresAt, apiTokensColExpiresAt)},
+		{&stats.AuditEntries, fmt.Sprintf("SELECT COUNT(*) FROM %s", auditLogTableName)},
+	}
+	for _, count := range counts {
+		err = db.QueryRowContext(ctx, count.query).Scan(count.target)
+		if err != nil {
+			return nil, deepError.New(fn, "count", err)
+		}
+	}
+
+	var first, last sql.NullTime
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s WHERE %s = '%s'",
+		eventsColCreatedAt, eventsColCreatedAt, eventsTableName, colStatus, statusActive)).Scan(&first, &last)
+	if err != nil {
+		return nil, deepError.New(fn, "event times", err)
+	}
+	if first.Valid {
+		stats.FirstEventAt = &first.Time
+		stats.LastEventAt = &last.Time
+	}
+
+	return stats, nil
+}
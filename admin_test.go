@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func TestExportImport(t *testing.T) {
+	fn := "TestExportImport"
+
+	ctx := context.Background()
+	source := &TestEventHandler{}
+	changedAt := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	_, err := source.PushChanges(ctx, []*EventChange{
+		{Event: GetTestEvent(), ChangedAt: changedAt},
+		{Event: GetTestEvent(), ChangedAt: changedAt.Add(time.Minute)},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+	_, err = source.PushChanges(ctx, []*EventChange{
+		{Event: &Event{ID: "2"}, Deleted: true, ChangedAt: changedAt.Add(time.Hour)},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	var out bytes.Buffer
+	count, err := exportEvents(ctx, source, &out)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 2, count, fn+": Wrong events exported")
+	util.Test.AssertEquals(t, 2, strings.Count(out.String(), "\n"), fn+": Not one event a line")
+
+	target := &TestEventHandler{}
+	result, err := importEvents(ctx, target, strings.NewReader(out.String()))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []string{"1", "2"}, result.Applied, fn+": Wrong events imported")
+
+	events, err := target.GetAllEvents(ctx)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 1, len(events), fn+": Deleted event imported")
+	util.Test.AssertEquals(t, "Test Event", events[0].Title, fn+": Wrong event imported")
+
+	// importing again changes nothing, as the events are no newer than what is there
+	result, err = importEvents(ctx, target, strings.NewReader(out.String()))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 1, len(result.Conflicts), fn+": Older event applied")
+
+	_, err = importEvents(ctx, target, strings.NewReader("{\"event\": {\"title\": \"no type\"}, \"changed_at\": \"2020-01-01T10:00:00Z\"}\n"))
+	util.Test.AssertEquals(t, true, err != nil && strings.HasPrefix(err.Error(), "line 1:"), fn+": Invalid event imported")
+}
+
+func TestMigrationPlan(t *testing.T) {
+	fn := "TestMigrationPlan"
+
+	latest, err := latestMigrationVersion()
+	util.Test.HandleIfTestError(t, err, fn)
+
+	steps, err := migrationPlan(0, latest)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, int(latest), len(steps), fn+": Wrong steps up")
+	util.Test.AssertEquals(t, migrationStep{file: "migrations/1_initial_up.sql", version: 1}, steps[0], fn+": Wrong first step")
+
+	steps, err = migrationPlan(latest, latest-2)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 2, len(steps), fn+": Wrong steps down")
+	util.Test.AssertEquals(t, latest-2, steps[1].version, fn+": Wrong version after going down")
+	util.Test.AssertEquals(t, true, strings.HasSuffix(steps[1].file, migrationDownSuffix), fn+": Not a down migration")
+
+	_, err = migrationPlan(0, latest+1)
+	util.Test.AssertEquals(t, true, err != nil, fn+": Planned a missing migration")
+}
+
+func TestSplitStatements(t *testing.T) {
+	fn := "TestSplitStatements"
+
+	statements := splitStatements("DROP TABLE a;\n\nUPDATE app_schema_version SET version = 1;\n")
+	util.Test.AssertEquals(t, []string{"DROP TABLE a", "UPDATE app_schema_version SET version = 1"}, statements, fn+": Wrong statements")
+}
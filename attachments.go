@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityAttachment = "attachment"
+
+	// attachmentFormName is the multipart form field files are uploaded as, any number of times
+	attachmentFormName = "file"
+
+	maxAttachmentFileName = 255
+	defaultContentType    = "application/octet-stream"
+)
+
+var (
+	queryGetEventAttachments = fmt.Sprintf(`
+		SELECT A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, E.%s
+		FROM %s A
+		JOIN %s E ON E.%s = A.%s
+		WHERE E.%s = ? AND E.%s = '%s'
+		ORDER BY A.%s`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus, eventAttachmentsColID, eventAttachmentsColFileName, eventAttachmentsColContentType,
+		eventAttachmentsColSize, eventAttachmentsColSHA256, eventsColID,
+		eventAttachmentsTableName,
+		eventsTableName, colDbID, eventAttachmentsColEventID,
+		eventsColID, colStatus, statusActive,
+		colDbID)
+
+	queryGetEventAttachment = fmt.Sprintf(`
+		SELECT A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, E.%s
+		FROM %s A
+		JOIN %s E ON E.%s = A.%s
+		WHERE A.%s = ? AND E.%s = ? AND E.%s = '%s'`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus, eventAttachmentsColID, eventAttachmentsColFileName, eventAttachmentsColContentType,
+		eventAttachmentsColSize, eventAttachmentsColSHA256, eventsColID,
+		eventAttachmentsTableName,
+		eventsTableName, colDbID, eventAttachmentsColEventID,
+		eventAttachmentsColID, eventsColID, colStatus, statusActive)
+
+	errAttachmentEventNotFound = newNotFoundError("Event with ID not found")
+	errAttachmentNotFound      = newNotFoundError("Attachment with ID not found")
+)
+
+// Attachment is the Db model for a file attached to an event. Its content is kept in a BlobStore by its SHA-256.
+type Attachment struct {
+	DbRecord
+	ID          string `json:"id"`
+	EventID     string `json:"event_id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// AttachmentsResponse represents the response to send to client, in case of an upload or a get attachments
+type AttachmentsResponse struct {
+	Attachments []*Attachment `json:"attachments"`
+}
+
+// attachmentFileName keeps the base of the name the client sent, so that it is safe to send back in a header
+func attachmentFileName(name string) string {
+	if index := strings.LastIndexAny(name, `/\`); index >= 0 {
+		name = name[index+1:]
+	}
+	if len(name) > maxAttachmentFileName {
+		name = name[:maxAttachmentFileName]
+	}
+
+	return name
+}
+
+// attachmentContentType is the content type the client sent along with the file, or else the one sniffed
+// from its first bytes
+func attachmentContentType(sent string, content *bufio.Reader) string {
+	if mediaType, params, err := mime.ParseMediaType(sent); err == nil && mediaType != defaultContentType {
+		return mime.FormatMediaType(mediaType, params)
+	}
+
+	// a short file gives an EOF, with what there is
+	head, _ := content.Peek(512)
+	return http.DetectContentType(head)
+}
+
+// storeAttachment puts the content of an uploaded file in the store, as an attachment of the event yet to be saved
+func storeAttachment(ctx context.Context, blobs BlobStore, eventID, fileName, contentType string, content io.Reader) (*Attachment, error) {
+	fn := "storeAttachment"
+
+	buffered := bufio.NewReader(content)
+	attachment := &Attachment{
+		EventID:     eventID,
+		FileName:    attachmentFileName(fileName),
+		ContentType: attachmentContentType(contentType, buffered),
+	}
+
+	blob, err := blobs.Put(ctx, buffered)
+	if err != nil {
+		return nil, deepError.New(fn, "put blob", err)
+	}
+	attachment.SHA256 = blob.SHA256
+	attachment.Size = blob.Size
+
+	return attachment, nil
+}
+
+// purgeOrphanBlobs removes, in batches, the blobs no attachment refers to, once they are older than orphanBlobGrace.
+// With dryRun, it only counts them.
+func purgeOrphanBlobs(ctx context.Context, handler IEventsHandler, blobs BlobStore, batchSize int, dryRun bool) (int64, error) {
+	fn := "purgeOrphanBlobs"
+
+	cutoff := time.Now().Add(-orphanBlobGrace)
+	hashes, err := blobs.List(ctx, cutoff)
+	if err != nil {
+		return 0, deepError.New(fn, "list blobs", err)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultRetentionBatchSize
+	}
+
+	var purged int64
+	for start := 0; start < len(hashes); start += batchSize {
+		batch := hashes[start:min(start+batchSize, len(hashes))]
+		attached, err := handler.FindAttachedBlobs(ctx, batch)
+		if err != nil {
+			return purged, deepError.New(fn, "find attached blobs", err)
+		}
+
+		for _, hash := range batch {
+			if attached[hash] {
+				continue
+			}
+			if dryRun {
+				purged++
+				continue
+			}
+
+			// checked against the cutoff again, as the blob may have been put again for a new attachment since
+			deleted, err := blobs.Delete(ctx, hash, cutoff)
+			if err != nil {
+				return purged, deepError.New(fn, "delete blob", err)
+			}
+			if deleted {
+				purged++
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// CreateAttachments attaches stored blobs to an active event, all of them or none
+func (handler *EventsHandler) CreateAttachments(ctx context.Context, eventID string, attachments []*Attachment) error {
+	fn := "CreateAttachments"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", eventID)
+
+	for _, attachment := range attachments {
+		attachment.ID = uuid.New().String()
+		attachment.EventID = eventID
+	}
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		event, err := txStuff.lockEvent(ctx, eventID)
+		if err != nil {
+			return deepError.New(fn, "lock event", err)
+		}
+		if event == nil {
+			return errAttachmentEventNotFound
+		}
+
+		for _, attachment := range attachments {
+			res, err := txStuff.exec(ctx,
+				fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?)",
+					eventAttachmentsTableName, eventAttachmentsColID, eventAttachmentsColEventID, eventAttachmentsColFileName,
+					eventAttachmentsColContentType, eventAttachmentsColSize, eventAttachmentsColSHA256),
+				attachment.ID, event.DbID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.SHA256)
+			if err != nil {
+				return deepError.New(fn, "insert", err)
+			}
+			attachment.DbID, err = getDbID(res)
+			if err != nil {
+				return deepError.New(fn, "get db id", err)
+			}
+
+			err = recordAudit(ctx, txStuff, auditEntityAttachment, attachment.ID, auditActionCreate, nil, attachment)
+			if err != nil {
+				return deepError.New(fn, "record audit", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logFailure(logger, "create attachments failed", err)
+		return deepError.New(fn, "transaction", err)
+	}
+
+	for _, attachment := range attachments {
+		logger.Info("attachment created", "attachment_id", attachment.ID, "size", attachment.Size)
+	}
+	return nil
+}
+
+// GetAttachments gets the attachments of an event, nil if there is no such event
+func (handler *EventsHandler) GetAttachments(ctx context.Context, eventID string) ([]*Attachment, error) {
+	fn := "GetAttachments"
+
+	event, err := handler.dbStuff.findEventByID(ctx, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "find event", err)
+	}
+	if event == nil || event.Status != statusActive {
+		return nil, nil
+	}
+
+	attachments, err := handler.dbStuff.findAttachments(ctx, queryGetEventAttachments, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "find attachments", err)
+	}
+
+	return attachments, nil
+}
+
+// GetAttachment gets a single attachment of an event, nil if there is none
+func (handler *EventsHandler) GetAttachment(ctx context.Context, eventID, attachmentID string) (*Attachment, error) {
+	fn := "GetAttachment"
+
+	attachments, err := handler.dbStuff.findAttachments(ctx, queryGetEventAttachment, attachmentID, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "find attachments", err)
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	return attachments[0], nil
+}
+
+// DeleteAttachment removes an attachment from the event. Its blob is left for purgeOrphanBlobs, as other
+// attachments may have the same content.
+func (handler *EventsHandler) DeleteAttachment(ctx context.Context, eventID, attachmentID string) error {
+	fn := "DeleteAttachment"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		attachments, err := txStuff.findAttachments(ctx, queryGetEventAttachment, attachmentID, eventID)
+		if err != nil {
+			return deepError.New(fn, "find attachment", err)
+		}
+		if len(attachments) == 0 {
+			return errAttachmentNotFound
+		}
+
+		_, err = txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventAttachmentsTableName, colDbID), attachments[0].DbID)
+		if err != nil {
+			return deepError.New(fn, "delete", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityAttachment, attachmentID, auditActionDelete, attachments[0], nil)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
+
+// FindAttachedBlobs tells which of the blobs some attachment refers to, of any event
+func (handler *EventsHandler) FindAttachedBlobs(ctx context.Context, hashes []string) (map[string]bool, error) {
+	fn := "FindAttachedBlobs"
+
+	attached := make(map[string]bool)
+	if len(hashes) == 0 {
+		return attached, nil
+	}
+
+	args := make([]interface{}, 0)
+	for _, hash := range hashes {
+		args = append(args, hash)
+	}
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx,
+		fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IN (%s)",
+			eventAttachmentsColSHA256, eventAttachmentsTableName, eventAttachmentsColSHA256, inPlaceholders(len(hashes))),
+		args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		attached[hash] = true
+	}
+
+	return attached, nil
+}
+
+// findAttachments runs one of the attachment queries
+func (dbStuff *dbStuff) findAttachments(ctx context.Context, query string, args ...interface{}) ([]*Attachment, error) {
+	fn := "findAttachments"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	attachments := make([]*Attachment, 0)
+	for rows.Next() {
+		attachment := &Attachment{}
+		err = rows.Scan(&attachment.DbID, &attachment.CreatedAt, &attachment.UpdatedAt, &attachment.Status, &attachment.ID,
+			&attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.SHA256, &attachment.EventID)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	fn := "TestLocalBlobStore"
+	ctx := context.Background()
+
+	store, err := newLocalBlobStore(t.TempDir())
+	util.Test.HandleIfTestError(t, err, fn)
+
+	first, err := store.Put(ctx, strings.NewReader("screenshot"))
+	util.Test.HandleIfTestError(t, err, fn)
+	second, err := store.Put(ctx, strings.NewReader("screenshot"))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, first, second, fn+": Same content stored differently")
+	util.Test.AssertEquals(t, int64(10), first.Size, fn+": Wrong size")
+
+	hashes, err := store.List(ctx, time.Now().Add(time.Minute))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []string{first.SHA256}, hashes, fn+": Same content kept twice")
+
+	hashes, err = store.List(ctx, time.Now().Add(-time.Minute))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 0, len(hashes), fn+": New blob listed as old")
+
+	content, err := store.Open(ctx, first.SHA256)
+	util.Test.HandleIfTestError(t, err, fn)
+	read, err := io.ReadAll(content)
+	content.Close()
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, "screenshot", string(read), fn+": Wrong content")
+
+	// a blob put again since the cutoff is kept
+	deleted, err := store.Delete(ctx, first.SHA256, time.Now().Add(-time.Minute))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, false, deleted, fn+": Fresh blob deleted")
+	_, err = store.Open(ctx, first.SHA256)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	deleted, err = store.Delete(ctx, first.SHA256, time.Now().Add(time.Minute))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, true, deleted, fn+": Blob not deleted")
+	_, err = store.Open(ctx, first.SHA256)
+	util.Test.AssertEquals(t, errBlobNotFound, err, fn+": Deleted blob opened")
+
+	_, err = store.Open(ctx, "../../etc/passwd")
+	util.Test.AssertEquals(t, true, err != nil, fn+": Path outside the store opened")
+}
+
+func TestAttachments(t *testing.T) {
+	fn := "TestAttachments"
+	ctx := context.Background()
+
+	handler := &TestEventHandler{}
+	blobs, err := newLocalBlobStore(t.TempDir())
+	util.Test.HandleIfTestError(t, err, fn)
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: handler,
+		Blobs:         blobs,
+	}
+
+	router.Use(MaxBytesMiddleware(64, map[string]int64{
+		rateLimitRouteKey(http.MethodPost, routeEventAttachments): 1024,
+	}))
+	router.HandleFunc(routeEventAttachments, GetAttachmentsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventAttachments, UploadAttachmentsHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeEventAttachment, DownloadAttachmentHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventAttachment, DeleteAttachmentHandler(env)).Methods(http.MethodDelete)
+
+	type file struct {
+		name        string
+		contentType string
+		content     string
+	}
+	upload := func(eventID string, files ...file) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, f := range files {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, attachmentFormName, f.name))
+			if f.contentType != "" {
+				header.Set("Content-Type", f.contentType)
+			}
+			part, err := writer.CreatePart(header)
+			util.Test.HandleIfTestError(t, err, fn)
+			_, err = part.Write([]byte(f.content))
+			util.Test.HandleIfTestError(t, err, fn)
+		}
+		util.Test.HandleIfTestError(t, writer.Close(), fn)
+
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(routeEventAttachmentsF, eventID), body)
+		util.Test.HandleIfTestError(t, err, fn)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = handler.CreateEvent(ctx, &Event{Title: "work", UserCreatedAt: time.Now(), Type: &EventType{Value: eventTypeStart}})
+		util.Test.HandleIfTestError(t, err, fn)
+	}
+
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 100)
+	uploaded := struct {
+		Data AttachmentsResponse `json:"data"`
+	}{}
+	rr := upload("1", file{"C:\\shots\\screen.png", "", png}, file{"notes.txt", "text/plain; charset=utf-8", "went well"})
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &uploaded), fn)
+	util.Test.AssertEquals(t, 2, len(uploaded.Data.Attachments), fn+": Wrong attachments")
+	shot := uploaded.Data.Attachments[0]
+	util.Test.AssertEquals(t, "screen.png", shot.FileName, fn+": Path kept in the file name")
+	util.Test.AssertEquals(t, "image/png", shot.ContentType, fn+": Content type not sniffed")
+
+	rr = upload("2", file{"copy.png", "image/png", png})
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for a copy")
+
+	rr = upload("10", file{"notes.txt", "", "went well"})
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Uploaded to an unknown event")
+
+	rr = upload("1", file{"big.txt", "", strings.Repeat("x", 2048)})
+	util.Test.AssertEquals(t, http.StatusRequestEntityTooLarge, rr.Code, fn+": Too large upload accepted")
+
+	// a failed upload attaches none of its files
+	rr = upload("1", file{"small.txt", "", "fine"}, file{"big.txt", "", strings.Repeat("x", 2048)})
+	util.Test.AssertEquals(t, http.StatusRequestEntityTooLarge, rr.Code, fn+": Too large upload accepted with another file")
+	existing, err := handler.GetAttachments(ctx, "1")
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 2, len(existing), fn+": File of a failed upload attached")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeEventAttachmentF, "1", shot.ID), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for download")
+	util.Test.AssertEquals(t, png, rr.Body.String(), fn+": Wrong content")
+	util.Test.AssertEquals(t, "image/png", rr.Header().Get("Content-Type"), fn+": Wrong content type")
+	util.Test.AssertEquals(t, `attachment; filename=screen.png`, rr.Header().Get("Content-Disposition"), fn+": Wrong disposition")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeEventAttachmentF, "2", shot.ID), "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Attachment of another event downloaded")
+
+	// once the blobs are old enough, the ones left without attachments are the deleted text file and the file of
+	// the failed upload
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeEventAttachmentF, "1", uploaded.Data.Attachments[1].ID), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Attachment not deleted")
+
+	old := time.Now().Add(-2 * orphanBlobGrace)
+	hashes, err := blobs.List(ctx, time.Now().Add(time.Minute))
+	util.Test.HandleIfTestError(t, err, fn)
+	for _, hash := range hashes {
+		path, err := blobs.path(hash)
+		util.Test.HandleIfTestError(t, err, fn)
+		util.Test.HandleIfTestError(t, os.Chtimes(path, old, old), fn)
+	}
+
+	purged, err := purgeOrphanBlobs(ctx, handler, blobs, 1, false)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, int64(2), purged, fn+": Wrong blobs purged")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeEventAttachmentF, "1", shot.ID), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Blob of a copy purged")
+
+	listed := struct {
+		Data AttachmentsResponse `json:"data"`
+	}{}
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeEventAttachmentsF, "1"), "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &listed), fn)
+	util.Test.AssertEquals(t, 1, len(listed.Data.Attachments), fn+": Deleted attachment listed")
+}
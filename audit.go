@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityEvent     = "event"
+	auditEntityEventType = "event_type"
+	auditEntityEventTag  = "event_tag"
+
+	auditActionCreate  = "create"
+	auditActionUpdate  = "update"
+	auditActionDelete  = "delete"
+	auditActionRestore = "restore"
+
+	actorAnonymous = "anonymous"
+)
+
+const ctxKeyActor contextKey = "actor"
+
+var (
+	queryGetAuditEntries = fmt.Sprintf(`
+		SELECT A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s, A.%s
+		FROM %s A
+		WHERE A.%s = ? AND A.%s = ?
+		ORDER BY A.%s`,
+		colDbID, auditLogColEntityType, auditLogColEntityID, auditLogColAction, auditLogColActor, auditLogColRequestID, auditLogColDiff, colCreatedAt,
+		auditLogTableName,
+		auditLogColEntityType, auditLogColEntityID,
+		colDbID)
+)
+
+// AuditChange is the value of a single field before and after a change
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry is the Db model for a single recorded change to an event, type or tag
+type AuditEntry struct {
+	DbID       int64                   `json:"-"`
+	EntityType string                  `json:"entity_type"`
+	EntityID   string                  `json:"entity_id"`
+	Action     string                  `json:"action"`
+	Actor      string                  `json:"actor"`
+	RequestID  string                  `json:"request_id"`
+	Changes    map[string]*AuditChange `json:"changes"`
+	At         time.Time               `json:"at"`
+}
+
+// EventHistoryResponse represents the response to send to client, in case of a get event history
+type EventHistoryResponse struct {
+	History []*AuditEntry `json:"history"`
+}
+
+// EventSnapshot is the state of an event at a point in time, as audited and kept in its revisions
+type EventSnapshot struct {
+	Title         string      `json:"title"`
+	Note          string      `json:"note"`
+	UserCreatedAt time.Time   `json:"created_at"`
+	TimeZone      string      `json:"time_zone,omitempty"`
+	UTCOffset     int         `json:"utc_offset,omitempty"`
+	Type          string      `json:"type"`
+	Tags          []string    `json:"tags"`
+	Fields        EventFields `json:"fields,omitempty"`
+	Status        string      `json:"status"`
+}
+
+func newEventSnapshot(event *Event) *EventSnapshot {
+	if event == nil {
+		return nil
+	}
+
+	view := &EventSnapshot{
+		Title:         event.Title,
+		Note:          event.Note,
+		UserCreatedAt: event.UserCreatedAt.UTC(),
+		TimeZone:      event.TimeZone,
+		UTCOffset:     event.UTCOffset,
+		Tags:          make([]string, 0),
+		Fields:        event.Fields,
+		Status:        event.Status,
+	}
+	if view.Status == "" {
+		view.Status = statusActive
+	}
+	if event.Type != nil {
+		view.Type = event.Type.Value
+	}
+	for _, tag := range event.Tags {
+		view.Tags = append(view.Tags, tag.Value)
+	}
+	sort.Strings(view.Tags)
+
+	return view
+}
+
+// auditDiff lists the fields that differ between two snapshots. Either can be nil, for creates and deletes.
+func auditDiff(before, after interface{}) (map[string]*AuditChange, error) {
+	fn := "auditDiff"
+
+	beforeFields, err := toJSONFields(before)
+	if err != nil {
+		return nil, deepError.New(fn, "before", err)
+	}
+
+	afterFields, err := toJSONFields(after)
+	if err != nil {
+		return nil, deepError.New(fn, "after", err)
+	}
+
+	diff := make(map[string]*AuditChange)
+	for key, beforeValue := range beforeFields {
+		if afterValue, ok := afterFields[key]; !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[key] = &AuditChange{Before: beforeValue, After: afterFields[key]}
+		}
+	}
+	for key, afterValue := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			diff[key] = &AuditChange{Before: nil, After: afterValue}
+		}
+	}
+
+	return diff, nil
+}
+
+func toJSONFields(snapshot interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if snapshot == nil {
+		return fields, nil
+	}
+	if value := reflect.ValueOf(snapshot); value.Kind() == reflect.Ptr && value.IsNil() {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
+
+// ActorMiddleware puts who is making the request in the context, for auditing.
+// Without authentication, that is the client's address.
+func ActorMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			ctx := context.WithValue(r.Context(), ctxKeyActor, actorAnonymous+"@"+host)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// actorFromContext returns who is making the request, anonymous if not known
+func actorFromContext(ctx context.Context) string {
+	actor, ok := ctx.Value(ctxKeyActor).(string)
+	if !ok || actor == "" {
+		return actorAnonymous
+	}
+
+	return actor
+}
+
+// isAnonymousActor tells if actor is the anonymous one, with or without the address it came from
+func isAnonymousActor(actor string) bool {
+	return actor == actorAnonymous || strings.HasPrefix(actor, actorAnonymous+"@")
+}
+
+// recordAudit writes what changed in an entity. It has to be called with the dbStuff of the transaction making
+// the change, so the entry is committed or rolled back along with it.
+func recordAudit(ctx context.Context, txStuff *dbStuff, entityType, entityID, action string, before, after interface{}) error {
+	fn := "recordAudit"
+
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return deepError.New(fn, "diff", err)
+	}
+
+	entry := &AuditEntry{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Actor:      actorFromContext(ctx),
+		RequestID:  requestIDFromContext(ctx),
+		Changes:    diff,
+	}
+
+	_, err = txStuff.insertAuditEntry(ctx, entry)
+	if err != nil {
+		return deepError.New(fn, "insert audit entry", err)
+	}
+
+	return nil
+}
+
+func (dbStuff *dbStuff) insertAuditEntry(ctx context.Context, entry *AuditEntry) (int64, error) {
+	fn := "insertAuditEntry"
+
+	diff, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return -1, deepError.New(fn, "marshal diff", err)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?)",
+		auditLogTableName, auditLogColEntityType, auditLogColEntityID, auditLogColAction, auditLogColActor, auditLogColRequestID, auditLogColDiff)
+
+	res, err := dbStuff.exec(ctx, query, entry.EntityType, entry.EntityID, entry.Action, entry.Actor, entry.RequestID, string(diff))
+	if err != nil {
+		return -1, deepError.New(fn, "prepare and exec", err)
+	}
+
+	return getDbID(res)
+}
+
+func (dbStuff *dbStuff) findAuditEntries(ctx context.Context, entityType, entityID string) ([]*AuditEntry, error) {
+	fn := "findAuditEntries"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetAuditEntries, entityType, entityID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*AuditEntry, 0)
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var diff []byte
+		err = rows.Scan(&entry.DbID, &entry.EntityType, &entry.EntityID, &entry.Action, &entry.Actor, &entry.RequestID, &diff, &entry.At)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+
+		err = json.Unmarshal(diff, &entry.Changes)
+		if err != nil {
+			return nil, deepError.New(fn, "unmarshal diff", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// GetEventHistory returns every recorded change to an event, oldest first. Returns nil if the event never existed.
+func (handler *EventsHandler) GetEventHistory(ctx context.Context, eventID string) ([]*AuditEntry, error) {
+	fn := "GetEventHistory"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", eventID)
+
+	event, err := handler.dbStuff.findEventByID(ctx, eventID)
+	if err != nil {
+		logger.Error("find event failed", errorAttr(err))
+		return nil, deepError.New(fn, "find event", err)
+	}
+	if event == nil {
+		return nil, nil
+	}
+
+	entries, err := handler.dbStuff.findAuditEntries(ctx, auditEntityEvent, eventID)
+	if err != nil {
+		logger.Error("find audit entries failed", errorAttr(err))
+		return nil, deepError.New(fn, "find audit entries", err)
+	}
+
+	return entries, nil
+}
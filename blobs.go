@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	defaultAttachmentsDir      = "attachments"
+	defaultAttachmentsMaxBytes = 10 << 20
+
+	// a blob no attachment refers to is only removed after this long, so that one just stored for an attachment
+	// yet to be inserted isn't taken for an orphan
+	orphanBlobGrace = 24 * time.Hour
+
+	blobsTmpDir = "tmp"
+)
+
+var (
+	blobHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	errBlobNotFound = errors.New("blob not found")
+)
+
+// AttachmentsConfig says where attachment content is kept, and how big uploads may be
+type AttachmentsConfig struct {
+	Dir string
+	// MaxBytes caps the body of upload requests, instead of max_body_bytes
+	MaxBytes int64
+}
+
+// Blob is content kept by a BlobStore, addressed by its SHA-256 as hex
+type Blob struct {
+	SHA256 string
+	Size   int64
+}
+
+// BlobStore keeps the content of attachments by its SHA-256, so the same content is only kept once
+type BlobStore interface {
+	// Put stores all of r, doing nothing but refreshing its time if the content is already there
+	Put(ctx context.Context, r io.Reader) (*Blob, error)
+	// Open reads a blob, failing with errBlobNotFound if there is none
+	Open(ctx context.Context, hash string) (io.ReadCloser, error)
+	// Delete removes a blob last put before a time, telling if it did. One put again since is kept.
+	Delete(ctx context.Context, hash string, before time.Time) (bool, error)
+	// List gives the hashes of the blobs last put before a time
+	List(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// LocalBlobStore keeps blobs as files under a dir, at <dir>/<first 2 of hash>/<next 2 of hash>/<hash>
+type LocalBlobStore struct {
+	dir string
+}
+
+func newLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	fn := "newLocalBlobStore"
+
+	err := os.MkdirAll(filepath.Join(dir, blobsTmpDir), 0o750)
+	if err != nil {
+		return nil, deepError.New(fn, "make dir", err)
+	}
+
+	return &LocalBlobStore{dir: dir}, nil
+}
+
+func (store *LocalBlobStore) path(hash string) (string, error) {
+	if !blobHashPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid blob hash %q", hash)
+	}
+
+	return filepath.Join(store.dir, hash[0:2], hash[2:4], hash), nil
+}
+
+// Put writes r to a temp file while hashing it, then moves it in place
+func (store *LocalBlobStore) Put(ctx context.Context, r io.Reader) (*Blob, error) {
+	fn := "Put"
+
+	tmp, err := os.CreateTemp(filepath.Join(store.dir, blobsTmpDir), "blob-")
+	if err != nil {
+		return nil, deepError.New(fn, "create temp", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		tmp.Close()
+		return nil, deepError.New(fn, "copy", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return nil, deepError.New(fn, "close temp", err)
+	}
+
+	blob := &Blob{SHA256: hex.EncodeToString(hasher.Sum(nil)), Size: size}
+	path, err := store.path(blob.SHA256)
+	if err != nil {
+		return nil, deepError.New(fn, "path", err)
+	}
+
+	now := time.Now()
+	err = os.Chtimes(path, now, now)
+	if err == nil {
+		return blob, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, deepError.New(fn, "touch", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return nil, deepError.New(fn, "make dir", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return nil, deepError.New(fn, "rename", err)
+	}
+
+	return blob, nil
+}
+
+// Open opens the file of a blob
+func (store *LocalBlobStore) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	fn := "Open"
+
+	path, err := store.path(hash)
+	if err != nil {
+		return nil, deepError.New(fn, "path", err)
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	if err != nil {
+		return nil, deepError.New(fn, "open", err)
+	}
+
+	return file, nil
+}
+
+// Delete moves the file of a blob out of the way before checking its time, then removes it. A Put touching it
+// before the move is seen in its time, and one after finds no file and puts it back.
+func (store *LocalBlobStore) Delete(ctx context.Context, hash string, before time.Time) (bool, error) {
+	fn := "Delete"
+
+	path, err := store.path(hash)
+	if err != nil {
+		return false, deepError.New(fn, "path", err)
+	}
+
+	moved := filepath.Join(store.dir, blobsTmpDir, "delete-"+hash)
+	err = os.Rename(path, moved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, deepError.New(fn, "move", err)
+	}
+
+	info, err := os.Stat(moved)
+	if err != nil {
+		return false, deepError.New(fn, "stat", err)
+	}
+	if !info.ModTime().Before(before) {
+		err = os.Rename(moved, path)
+		if err != nil {
+			return false, deepError.New(fn, "move back", err)
+		}
+		return false, nil
+	}
+
+	err = os.Remove(moved)
+	if err != nil {
+		return false, deepError.New(fn, "remove", err)
+	}
+
+	return true, nil
+}
+
+// List walks the dir for blob files modified before a time, leaving out temp files
+func (store *LocalBlobStore) List(ctx context.Context, before time.Time) ([]string, error) {
+	fn := "List"
+
+	hashes := make([]string, 0)
+	err := filepath.WalkDir(store.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == filepath.Join(store.dir, blobsTmpDir) {
+				return filepath.SkipDir
+			}
+			return ctx.Err()
+		}
+		if !blobHashPattern.MatchString(entry.Name()) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(before) {
+			hashes = append(hashes, entry.Name())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, deepError.New(fn, "walk", err)
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"container/list"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// lruCache is a size bounded cache, evicting the least recently used entry when full.
+// Entries older than ttl are treated as missing. Safe for concurrent use.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+	now   func() time.Time
+
+	hits   *expvar.Int
+	misses *expvar.Int
+}
+
+type lruEntry struct {
+	key      string
+	value    interface{}
+	storedAt time.Time
+}
+
+var cacheStats = expvar.NewMap("cache")
+
+func newLRUCache(name string, size int, ttl time.Duration) *lruCache {
+	cache := &lruCache{
+		size:   size,
+		ttl:    ttl,
+		items:  make(map[string]*list.Element),
+		order:  list.New(),
+		now:    time.Now,
+		hits:   new(expvar.Int),
+		misses: new(expvar.Int),
+	}
+
+	cacheStats.Set(name+"_hits", cache.hits)
+	cacheStats.Set(name+"_misses", cache.misses)
+
+	return cache
+}
+
+// Get returns the value for key, if present and not expired
+func (cache *lruCache) Get(key string) (interface{}, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, ok := cache.items[key]
+	if !ok {
+		cache.misses.Add(1)
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if cache.ttl > 0 && cache.now().Sub(entry.storedAt) > cache.ttl {
+		cache.removeElement(elem)
+		cache.misses.Add(1)
+		return nil, false
+	}
+
+	cache.order.MoveToFront(elem)
+	cache.hits.Add(1)
+	return entry.value, true
+}
+
+// Add stores value for key, evicting the oldest entry if the cache is full
+func (cache *lruCache) Add(key string, value interface{}) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.storedAt = cache.now()
+		cache.order.MoveToFront(elem)
+		return
+	}
+
+	cache.items[key] = cache.order.PushFront(&lruEntry{key: key, value: value, storedAt: cache.now()})
+
+	for cache.size > 0 && cache.order.Len() > cache.size {
+		cache.removeElement(cache.order.Back())
+	}
+}
+
+// Remove drops key from the cache, if present
+func (cache *lruCache) Remove(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		cache.removeElement(elem)
+	}
+}
+
+// Purge empties the cache
+func (cache *lruCache) Purge() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.items = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+func (cache *lruCache) removeElement(elem *list.Element) {
+	cache.order.Remove(elem)
+	delete(cache.items, elem.Value.(*lruEntry).key)
+}
+
+// lookupCache keeps event types and tags, reachable both by value and by db id.
+// Only committed rows go in, so a rolled back transaction can never leave an id behind that doesn't exist.
+// Types are never removed, and tags only by retention, which drops them here, or by vacuum-orphans from another
+// process, which the cache only learns of when a stale tag is refused by a foreign key or its ttl is up.
+type lookupCache struct {
+	types *lruCache
+	tags  *lruCache
+}
+
+func newLookupCache(size int, ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		types: newLRUCache("event_types", size, ttl),
+		tags:  newLRUCache("event_tags", size, ttl),
+	}
+}
+
+func cacheKeyValue(value string) string {
+	return "value:" + value
+}
+
+func cacheKeyID(id int64) string {
+	return "id:" + strconv.FormatInt(id, 10)
+}
+
+func (cache *lookupCache) getEventType(key string) *EventType {
+	if cache == nil {
+		return nil
+	}
+
+	cached, ok := cache.types.Get(key)
+	if !ok {
+		return nil
+	}
+
+	eventType := *cached.(*EventType)
+	return &eventType
+}
+
+func (cache *lookupCache) addEventType(eventType *EventType) {
+	if cache == nil || eventType == nil {
+		return
+	}
+
+	copied := *eventType
+	cache.types.Add(cacheKeyValue(copied.Value), &copied)
+	cache.types.Add(cacheKeyID(copied.DbID), &copied)
+}
+
+func (cache *lookupCache) getEventTag(key string) *EventTag {
+	if cache == nil {
+		return nil
+	}
+
+	cached, ok := cache.tags.Get(key)
+	if !ok {
+		return nil
+	}
+
+	eventTag := *cached.(*EventTag)
+	return &eventTag
+}
+
+func (cache *lookupCache) addEventTag(eventTag *EventTag) {
+	if cache == nil || eventTag == nil {
+		return
+	}
+
+	copied := *eventTag
+	cache.tags.Add(cacheKeyValue(copied.Value), &copied)
+	cache.tags.Add(cacheKeyID(copied.DbID), &copied)
+}
+
+// invalidateEventTag has to be called whenever a tag is renamed, merged into another or deleted
+func (cache *lookupCache) invalidateEventTag(eventTag *EventTag) {
+	if cache == nil || eventTag == nil {
+		return
+	}
+
+	cache.tags.Remove(cacheKeyValue(eventTag.Value))
+	cache.tags.Remove(cacheKeyID(eventTag.DbID))
+}
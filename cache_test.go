@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	fn := "TestLRUCacheEviction"
+	cache := newLRUCache("test_eviction", 2, 0)
+
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Get("a")
+	cache.Add("c", 3)
+
+	_, ok := cache.Get("b")
+	util.Test.AssertEquals(t, false, ok, fn+": Least recently used entry not evicted")
+
+	value, ok := cache.Get("a")
+	util.Test.AssertEquals(t, true, ok, fn+": Recently used entry evicted")
+	util.Test.AssertEquals(t, 1, value, fn+": Wrong value")
+
+	util.Test.AssertEquals(t, int64(2), cache.hits.Value(), fn+": Wrong hit count")
+	util.Test.AssertEquals(t, int64(1), cache.misses.Value(), fn+": Wrong miss count")
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	fn := "TestLRUCacheTTL"
+	cache := newLRUCache("test_ttl", 10, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	cache.Add("a", 1)
+
+	_, ok := cache.Get("a")
+	util.Test.AssertEquals(t, true, ok, fn+": Fresh entry missing")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get("a")
+	util.Test.AssertEquals(t, false, ok, fn+": Expired entry returned")
+}
+
+func TestLookupCacheInvalidation(t *testing.T) {
+	fn := "TestLookupCacheInvalidation"
+	cache := newLookupCache(10, 0)
+
+	tag := &EventTag{DbRecord: DbRecord{DbID: 7}, Value: "work"}
+	cache.addEventTag(tag)
+
+	util.Test.AssertEquals(t, tag, cache.getEventTag(cacheKeyValue("work")), fn+": Tag not found by value")
+	util.Test.AssertEquals(t, tag, cache.getEventTag(cacheKeyID(7)), fn+": Tag not found by id")
+
+	cache.invalidateEventTag(tag)
+
+	util.Test.AssertEquals(t, (*EventTag)(nil), cache.getEventTag(cacheKeyValue("work")), fn+": Tag found by value after invalidation")
+	util.Test.AssertEquals(t, (*EventTag)(nil), cache.getEventTag(cacheKeyID(7)), fn+": Tag found by id after invalidation")
+}
+
+func TestCacheStatsKeptByUsersHandler(t *testing.T) {
+	fn := "TestCacheStatsKeptByUsersHandler"
+
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/events")
+	util.Test.HandleIfTestError(t, err, fn)
+	defer db.Close()
+
+	options := HandlerOptions{CacheSize: 10}
+	events := &EventsHandler{}
+	events.Init(db, options)
+	users := &UsersHandler{}
+	users.Init(db, options)
+
+	util.Test.AssertEquals(t, (*lookupCache)(nil), users.dbStuff.cache, fn+": Users handler got a lookup cache")
+	published := cacheStats.Get("event_types_hits")
+	util.Test.AssertEquals(t, true, published == expvar.Var(events.dbStuff.cache.types.hits), fn+": Cache stats taken over")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	eventTypeStart = "start"
+	eventTypeEnd   = "end"
+	eventTypeNote  = "note"
+)
+
+// Event is an event as sent and received by the api
+type Event struct {
+	ID            string      `json:"id,omitempty"`
+	Title         string      `json:"title"`
+	Note          string      `json:"note"`
+	UserCreatedAt time.Time   `json:"created_at"`
+	Type          *EventType  `json:"type"`
+	Tags          []*EventTag `json:"tags"`
+}
+
+// EventType is the type of an event, like start or end
+type EventType struct {
+	Value string `json:"value"`
+}
+
+// EventTag is a tag on an event
+type EventTag struct {
+	Value string `json:"value"`
+}
+
+func (event *Event) typeValue() string {
+	if event.Type == nil {
+		return ""
+	}
+
+	return event.Type.Value
+}
+
+func (event *Event) tagValues() []string {
+	values := make([]string, 0)
+	for _, tag := range event.Tags {
+		values = append(values, tag.Value)
+	}
+
+	return values
+}
+
+// EventFilter narrows down the events to list. Zero values match everything.
+type EventFilter struct {
+	Type   string
+	Tags   []string
+	From   time.Time
+	To     time.Time
+	Search string
+	Limit  int
+}
+
+func (filter EventFilter) query() url.Values {
+	query := url.Values{}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+	for _, tag := range filter.Tags {
+		query.Add("tag", tag)
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.UTC().Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.UTC().Format(time.RFC3339))
+	}
+	if filter.Search != "" {
+		query.Set("q", filter.Search)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", fmt.Sprint(filter.Limit))
+	}
+
+	return query
+}
+
+// response is the envelope every api response comes in
+type response struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// APIError is a failure reported by the api
+type APIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("api error %d (code %d): %s", err.Status, err.Code, err.Message)
+}
+
+// Client calls the EventTracker REST api
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient makes a client for the api at baseURL, authenticating with token if it isn't empty
+func NewClient(baseURL, token string, timeout time.Duration) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// CreateEvent creates the event, returning its id
+func (client *Client) CreateEvent(ctx context.Context, event *Event) (string, error) {
+	var data struct {
+		EventID string `json:"eventID"`
+	}
+
+	err := client.do(ctx, http.MethodPost, "/event", nil, event, &data)
+	if err != nil {
+		return "", err
+	}
+
+	return data.EventID, nil
+}
+
+// ListEvents gets the events matching filter, ordered by their time
+func (client *Client) ListEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	var data struct {
+		Events []*Event `json:"events"`
+	}
+
+	err := client.do(ctx, http.MethodGet, "/events", filter.query(), nil, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Events, nil
+}
+
+// OpenEvent gets the latest start event with no end event after it, nil if there is none
+func (client *Client) OpenEvent(ctx context.Context) (*Event, error) {
+	starts, err := client.ListEvents(ctx, EventFilter{Type: eventTypeStart})
+	if err != nil {
+		return nil, err
+	}
+	if len(starts) == 0 {
+		return nil, nil
+	}
+
+	latest := starts[len(starts)-1]
+	ends, err := client.ListEvents(ctx, EventFilter{Type: eventTypeEnd, From: latest.UserCreatedAt, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(ends) > 0 {
+		return nil, nil
+	}
+
+	return latest, nil
+}
+
+func (client *Client) do(ctx context.Context, method, path string, query url.Values, body, data interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	target := client.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.Token)
+	}
+
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var envelope response
+	err = json.NewDecoder(res.Body).Decode(&envelope)
+	if err != nil {
+		return fmt.Errorf("unexpected response with status %d: %w", res.StatusCode, err)
+	}
+	if !envelope.Success || envelope.Error != nil {
+		apiErr := &APIError{Status: res.StatusCode, Message: "request failed"}
+		if envelope.Error != nil {
+			apiErr.Code = envelope.Error.Code
+			apiErr.Message = envelope.Error.Message
+		}
+		return apiErr
+	}
+
+	return json.Unmarshal(envelope.Data, data)
+}
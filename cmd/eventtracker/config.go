@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/magiconair/properties"
+)
+
+const (
+	defaultServer  = "http://localhost:8080"
+	defaultTimeout = 10 * time.Second
+
+	configFileName = ".eventtracker.properties"
+	envServer      = "EVENTTRACKER_SERVER"
+	envToken       = "EVENTTRACKER_TOKEN"
+	envConfig      = "EVENTTRACKER_CLI_CONFIG"
+)
+
+// Config is where the api is and how to call it. Values come from ~/.eventtracker.properties,
+// then the environment, then flags, each overriding the one before.
+type Config struct {
+	Server  string
+	Token   string
+	Timeout time.Duration
+}
+
+// loadConfig reads the properties file, an explicitly given one has to exist
+func loadConfig(fileName string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	config := &Config{Server: defaultServer, Timeout: defaultTimeout}
+
+	explicit := fileName != ""
+	if !explicit {
+		if envFile, ok := lookupEnv(envConfig); ok {
+			fileName, explicit = envFile, true
+		}
+	}
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return config, nil
+		}
+		fileName = filepath.Join(home, configFileName)
+	}
+
+	p, err := properties.LoadFiles([]string{fileName}, properties.UTF8, !explicit)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Server = p.GetString("server", config.Server)
+	config.Token = p.GetString("token", config.Token)
+	if rawTimeout, ok := p.Get("timeout"); ok {
+		config.Timeout, err = time.ParseDuration(rawTimeout)
+		if err != nil || config.Timeout <= 0 {
+			return nil, errors.New("timeout should be a positive duration, like 10s")
+		}
+	}
+
+	if server, ok := lookupEnv(envServer); ok {
+		config.Server = server
+	}
+	if token, ok := lookupEnv(envToken); ok {
+		config.Token = token
+	}
+
+	return config, nil
+}
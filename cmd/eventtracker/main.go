@@ -0,0 +1,328 @@
+// eventtracker is a command line client for the EventTracker api, to track events from the terminal.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `usage: eventtracker [-config file] [-server url] [-token token] [-output table|json] <command> [args]
+
+commands:
+  start [-tag t]... [-note n] [-at time] <title>   start something, like coding
+  stop [-tag t]... [-note n] [-at time] [title]    end what was started last, with its title and tags by default
+  note [-tag t]... [-note n] [-at time] <title>    log a single event
+  list [-type t] [-tag t]... [-from time] [-to time] [-limit n]
+  search [-type t] [-tag t]... [-from time] [-to time] [-limit n] <text>
+  status                                           show what was started and not stopped yet
+
+times are RFC 3339, like 2020-01-02T15:04:05Z, a time of today, like 15:04, or a duration ago, like 10m
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, os.LookupEnv, time.Now))
+}
+
+// cli is what every command runs with
+type cli struct {
+	client *Client
+	output string
+	stdout io.Writer
+	now    func() time.Time
+}
+
+// run runs the command in args, returning the exit code
+func run(args []string, stdout, stderr io.Writer, lookupEnv func(string) (string, bool), now func() time.Time) int {
+	flags := flag.NewFlagSet("eventtracker", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", "", "path to the properties file, default ~/"+configFileName)
+	server := flags.String("server", "", "url of the api")
+	token := flags.String("token", "", "token to call the api with")
+	output := flags.String("output", outputTable, "table or json")
+
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() == 0 || (*output != outputTable && *output != outputJSON) {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	config, err := loadConfig(*configFile, lookupEnv)
+	if err != nil {
+		fmt.Fprintln(stderr, "could not load config:", err)
+		return 2
+	}
+	if *server != "" {
+		config.Server = *server
+	}
+	if *token != "" {
+		config.Token = *token
+	}
+
+	c := &cli{
+		client: NewClient(config.Server, config.Token, config.Timeout),
+		output: *output,
+		stdout: stdout,
+		now:    now,
+	}
+
+	commands := map[string]func(ctx context.Context, args []string) error{
+		"start":  c.start,
+		"stop":   c.stop,
+		"note":   c.note,
+		"list":   c.list,
+		"search": c.search,
+		"status": c.status,
+	}
+
+	command, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	err = command(context.Background(), flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// tagsFlag collects tags given with a repeated flag, or separated by commas
+type tagsFlag []string
+
+func (tags *tagsFlag) String() string {
+	return strings.Join(*tags, ",")
+}
+
+func (tags *tagsFlag) Set(value string) error {
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*tags = append(*tags, tag)
+		}
+	}
+
+	return nil
+}
+
+// timeFlag is a time given as RFC 3339, a time of today or a duration ago
+type timeFlag struct {
+	now   func() time.Time
+	value time.Time
+}
+
+func (t *timeFlag) String() string {
+	if t.value.IsZero() {
+		return ""
+	}
+
+	return t.value.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(value string) error {
+	parsed, err := parseTime(value, t.now())
+	if err != nil {
+		return err
+	}
+
+	t.value = parsed
+	return nil
+}
+
+func parseTime(raw string, now time.Time) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed, nil
+	}
+
+	if clock, err := time.ParseInLocation("15:04", raw, now.Location()); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location()), nil
+	}
+
+	if ago, err := time.ParseDuration(raw); err == nil && ago >= 0 {
+		return now.Add(-ago), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", raw)
+}
+
+// eventFlags are the flags of the commands creating an event
+type eventFlags struct {
+	tags tagsFlag
+	note string
+	at   timeFlag
+}
+
+func (c *cli) parseEventFlags(name string, args []string) (*eventFlags, []string, error) {
+	event := &eventFlags{at: timeFlag{now: c.now}}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(&event.tags, "tag", "tag for the event, can be repeated")
+	flags.StringVar(&event.note, "note", "", "note for the event")
+	flags.Var(&event.at, "at", "time of the event, now by default")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, errUsage
+	}
+	if event.at.value.IsZero() {
+		event.at.value = c.now()
+	}
+
+	return event, flags.Args(), nil
+}
+
+func (c *cli) create(ctx context.Context, eventType, title string, flags *eventFlags) error {
+	// the time is sent with its local offset, which the api keeps
+	event := &Event{
+		Title:         title,
+		Note:          flags.note,
+		UserCreatedAt: flags.at.value,
+		Type:          &EventType{Value: eventType},
+		Tags:          make([]*EventTag, 0),
+	}
+	for _, tag := range flags.tags {
+		event.Tags = append(event.Tags, &EventTag{Value: tag})
+	}
+
+	eventID, err := c.client.CreateEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return printCreated(c.stdout, c.output, eventID)
+}
+
+func (c *cli) start(ctx context.Context, args []string) error {
+	flags, rest, err := c.parseEventFlags("start", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return errUsage
+	}
+
+	return c.create(ctx, eventTypeStart, strings.Join(rest, " "), flags)
+}
+
+func (c *cli) stop(ctx context.Context, args []string) error {
+	flags, rest, err := c.parseEventFlags("stop", args)
+	if err != nil {
+		return err
+	}
+
+	open, err := c.client.OpenEvent(ctx)
+	if err != nil {
+		return err
+	}
+	if open == nil {
+		return errors.New("nothing to stop")
+	}
+
+	title := strings.Join(rest, " ")
+	if title == "" {
+		title = open.Title
+	}
+	if len(flags.tags) == 0 {
+		flags.tags = open.tagValues()
+	}
+
+	return c.create(ctx, eventTypeEnd, title, flags)
+}
+
+func (c *cli) note(ctx context.Context, args []string) error {
+	flags, rest, err := c.parseEventFlags("note", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return errUsage
+	}
+
+	return c.create(ctx, eventTypeNote, strings.Join(rest, " "), flags)
+}
+
+func (c *cli) parseFilter(name string, args []string) (EventFilter, []string, error) {
+	var tags tagsFlag
+	from, to := timeFlag{now: c.now}, timeFlag{now: c.now}
+	filter := EventFilter{}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&filter.Type, "type", "", "type of the events")
+	flags.Var(&tags, "tag", "tag the events have to have, can be repeated")
+	flags.Var(&from, "from", "earliest time of the events")
+	flags.Var(&to, "to", "time the events have to be before")
+	flags.IntVar(&filter.Limit, "limit", 0, "max events to list")
+
+	err := flags.Parse(args)
+	if err != nil || filter.Limit < 0 {
+		return filter, nil, errUsage
+	}
+
+	filter.Tags = tags
+	filter.From = from.value
+	filter.To = to.value
+	return filter, flags.Args(), nil
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	filter, rest, err := c.parseFilter("list", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errUsage
+	}
+
+	events, err := c.client.ListEvents(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return printEvents(c.stdout, c.output, events)
+}
+
+func (c *cli) search(ctx context.Context, args []string) error {
+	filter, rest, err := c.parseFilter("search", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return errUsage
+	}
+
+	filter.Search = strings.Join(rest, " ")
+	events, err := c.client.ListEvents(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return printEvents(c.stdout, c.output, events)
+}
+
+func (c *cli) status(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	open, err := c.client.OpenEvent(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printStatus(c.stdout, c.output, open, c.now())
+}
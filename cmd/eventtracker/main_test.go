@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+// fakeAPI keeps the events created through it, and lists them filtered by type, from and search like the api does
+type fakeAPI struct {
+	events []*Event
+	tokens []string
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.tokens = append(api.tokens, r.Header.Get("Authorization"))
+
+	var data interface{}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/event":
+		event := &Event{}
+		json.NewDecoder(r.Body).Decode(event)
+		event.ID = strconv.Itoa(len(api.events) + 1)
+		api.events = append(api.events, event)
+		data = map[string]string{"eventID": event.ID}
+
+	case r.Method == http.MethodGet && r.URL.Path == "/events":
+		query := r.URL.Query()
+		from, _ := time.Parse(time.RFC3339, query.Get("from"))
+		events := make([]*Event, 0)
+		for _, event := range api.events {
+			if (query.Get("type") == "" || event.typeValue() == query.Get("type")) &&
+				!event.UserCreatedAt.Before(from) &&
+				strings.Contains(event.Title, query.Get("q")) {
+				events = append(events, event)
+			}
+		}
+		data = map[string][]*Event{"events": events}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   map[string]interface{}{"code": 4, "message": "not found"},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data})
+}
+
+func runCLI(t *testing.T, api *fakeAPI, now time.Time, args ...string) (int, string, string) {
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	// an empty config file, so that one in the home directory isn't read
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	util.Test.HandleIfTestError(t, os.WriteFile(configFile, nil, 0o600), "runCLI")
+
+	env := map[string]string{envServer: server.URL, envToken: "secret", envConfig: configFile}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr, lookupEnv, func() time.Time { return now })
+	return code, stdout.String(), stderr.String()
+}
+
+func TestStartStopStatus(t *testing.T) {
+	fn := "TestStartStopStatus"
+
+	api := &fakeAPI{}
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	code, stdout, _ := runCLI(t, api, now, "start", "-tag", "work,go", "coding")
+	util.Test.AssertEquals(t, 0, code, fn+": start failed")
+	util.Test.AssertEquals(t, "1\n", stdout, fn+": Wrong id printed")
+	util.Test.AssertEquals(t, "Bearer secret", api.tokens[0], fn+": Token not sent")
+
+	code, stdout, _ = runCLI(t, api, now.Add(30*time.Minute), "-output", "json", "status")
+	util.Test.AssertEquals(t, 0, code, fn+": status failed")
+	status := struct {
+		Open           bool    `json:"open"`
+		Event          *Event  `json:"event"`
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+	}{}
+	util.Test.HandleIfTestError(t, json.Unmarshal([]byte(stdout), &status), fn)
+	util.Test.AssertEquals(t, true, status.Open, fn+": Nothing open")
+	util.Test.AssertEquals(t, "coding", status.Event.Title, fn+": Wrong open event")
+	util.Test.AssertEquals(t, 1800.0, status.ElapsedSeconds, fn+": Wrong elapsed time")
+
+	code, _, _ = runCLI(t, api, now.Add(time.Hour), "stop")
+	util.Test.AssertEquals(t, 0, code, fn+": stop failed")
+	util.Test.AssertEquals(t, 2, len(api.events), fn+": End event not created")
+	util.Test.AssertEquals(t, eventTypeEnd, api.events[1].typeValue(), fn+": Wrong type")
+	util.Test.AssertEquals(t, "coding", api.events[1].Title, fn+": Title not copied from start")
+	util.Test.AssertEquals(t, []string{"work", "go"}, api.events[1].tagValues(), fn+": Tags not copied from start")
+
+	code, stdout, _ = runCLI(t, api, now.Add(time.Hour), "status")
+	util.Test.AssertEquals(t, 0, code, fn+": status failed")
+	util.Test.AssertEquals(t, "nothing going on\n", stdout, fn+": Event still open")
+
+	code, _, stderr := runCLI(t, api, now.Add(time.Hour), "stop")
+	util.Test.AssertEquals(t, 1, code, fn+": Stopped with nothing open")
+	util.Test.AssertEquals(t, "error: nothing to stop\n", stderr, fn+": Wrong error")
+}
+
+func TestListAndSearch(t *testing.T) {
+	fn := "TestListAndSearch"
+
+	api := &fakeAPI{}
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	runCLI(t, api, now, "note", "-at", "2020-01-01T08:00:00Z", "-note", "went well", "standup")
+	runCLI(t, api, now, "note", "-at", "30m", "lunch")
+
+	code, stdout, _ := runCLI(t, api, now, "-output", "json", "search", "lunch")
+	util.Test.AssertEquals(t, 0, code, fn+": search failed")
+	events := make([]*Event, 0)
+	util.Test.HandleIfTestError(t, json.Unmarshal([]byte(stdout), &events), fn)
+	util.Test.AssertEquals(t, 1, len(events), fn+": Wrong events found")
+	util.Test.AssertEquals(t, now.Add(-30*time.Minute), events[0].UserCreatedAt, fn+": Wrong time")
+
+	code, stdout, _ = runCLI(t, api, now, "list", "-type", eventTypeNote)
+	util.Test.AssertEquals(t, 0, code, fn+": list failed")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	util.Test.AssertEquals(t, 3, len(lines), fn+": Wrong rows")
+	util.Test.AssertEquals(t, true, strings.Contains(lines[1], "standup") && strings.Contains(lines[1], "went well"), fn+": Wrong row")
+
+	code, _, stderr := runCLI(t, api, now, "list", "-from", "yesterday")
+	util.Test.AssertEquals(t, 2, code, fn+": Invalid time accepted")
+	util.Test.AssertEquals(t, true, strings.HasPrefix(stderr, "usage:"), fn+": Usage not printed")
+}
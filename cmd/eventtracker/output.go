@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printEvents writes the events as a table, or as a JSON array
+func printEvents(w io.Writer, output string, events []*Event) error {
+	if output == outputJSON {
+		if events == nil {
+			events = make([]*Event, 0)
+		}
+		return printJSON(w, events)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tTYPE\tTITLE\tTAGS\tNOTE")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.ID,
+			event.UserCreatedAt.Local().Format(time.DateTime),
+			event.typeValue(),
+			event.Title,
+			strings.Join(event.tagValues(), ","),
+			event.Note)
+	}
+
+	return tw.Flush()
+}
+
+// printStatus writes the open start event and how long it has been going on, if there is one
+func printStatus(w io.Writer, output string, open *Event, now time.Time) error {
+	if output == outputJSON {
+		status := struct {
+			Open           bool    `json:"open"`
+			Event          *Event  `json:"event"`
+			ElapsedSeconds float64 `json:"elapsed_seconds,omitempty"`
+		}{Open: open != nil, Event: open}
+		if open != nil {
+			status.ElapsedSeconds = now.Sub(open.UserCreatedAt).Seconds()
+		}
+		return printJSON(w, status)
+	}
+
+	if open == nil {
+		_, err := fmt.Fprintln(w, "nothing going on")
+		return err
+	}
+
+	tags := ""
+	if len(open.Tags) > 0 {
+		tags = " [" + strings.Join(open.tagValues(), ",") + "]"
+	}
+	_, err := fmt.Fprintf(w, "%s%s, started %s, %s ago\n",
+		open.Title, tags, open.UserCreatedAt.Local().Format(time.DateTime), now.Sub(open.UserCreatedAt).Round(time.Second))
+	return err
+}
+
+// printCreated writes the id of a created event
+func printCreated(w io.Writer, output string, eventID string) error {
+	if output == outputJSON {
+		return printJSON(w, map[string]string{"id": eventID})
+	}
+
+	_, err := fmt.Fprintln(w, eventID)
+	return err
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCacheControl = "private, no-cache"
+)
+
+// eventsETag is a validator for a set of events, built from the _updated_at of each event, its type and its tags.
+// Any change to any of them, or to the set of events itself, gives a new etag. A removed link leaves nothing behind to go
+// by, so adding or removing a link bumps the _updated_at of both its events instead.
+// variant is anything else changing how the events are rendered, like the time zone they are shown in.
+func eventsETag(events []*Event, variant string) string {
+	hash := sha1.New()
+	writeTime := func(t time.Time) {
+		hash.Write([]byte(strconv.FormatInt(t.UnixNano(), 10)))
+		hash.Write([]byte{0})
+	}
+
+	hash.Write([]byte(variant))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strconv.Itoa(len(events))))
+	hash.Write([]byte{0})
+
+	for _, event := range events {
+		hash.Write([]byte(event.ID))
+		hash.Write([]byte{0})
+		writeTime(event.UpdatedAt)
+
+		if event.Type != nil {
+			writeTime(event.Type.UpdatedAt)
+		}
+		for _, tag := range event.Tags {
+			writeTime(tag.UpdatedAt)
+		}
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:16] + `"`
+}
+
+// eventsLastModified is the latest _updated_at among the events, their types and their tags
+func eventsLastModified(events []*Event) time.Time {
+	var lastModified time.Time
+	latest := func(t time.Time) {
+		if t.After(lastModified) {
+			lastModified = t
+		}
+	}
+
+	for _, event := range events {
+		latest(event.UpdatedAt)
+		if event.Type != nil {
+			latest(event.Type.UpdatedAt)
+		}
+		for _, tag := range event.Tags {
+			latest(tag.UpdatedAt)
+		}
+	}
+
+	return lastModified
+}
+
+// checkNotModified sets the caching headers for a response, and responds with 304 if the client's copy
+// is still fresh as per If-None-Match, or If-Modified-Since when there's no If-None-Match.
+// Returns true if the response has been sent.
+func checkNotModified(w http.ResponseWriter, r *http.Request, cacheControl, etag string, lastModified time.Time) bool {
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if !etagMatches(ifNoneMatch, etag) {
+			return false
+		}
+
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil || lastModified.Truncate(time.Second).After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches does a weak comparison of etag against a If-None-Match header value
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
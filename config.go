@@ -0,0 +1,527 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+	"github.com/magiconair/properties"
+)
+
+const (
+	defaultConfigFile = "app.properties"
+	configFlagName    = "config"
+	envPrefix         = "EVENTTRACKER_"
+	secretFileSuffix  = "_file"
+	redactedValue     = "******"
+)
+
+// where a config value came from, in increasing order of precedence
+const (
+	configSourceDefault = iota
+	configSourceFile
+	configSourceEnv
+	configSourceFlag
+)
+
+var configSourceNames = map[int]string{
+	configSourceDefault: "default",
+	configSourceFile:    "file",
+	configSourceEnv:     "env",
+	configSourceFlag:    "flag",
+}
+
+// configOption describes a single config key. Every key can be set in the properties file as is,
+// as an environment variable EVENTTRACKER_<KEY> or as a command line flag -<key>.
+// Secrets can also be read from a file given by <key>_file.
+type configOption struct {
+	key      string
+	def      string
+	usage    string
+	required bool
+	secret   bool
+}
+
+var configOptions = []configOption{
+	{key: "url", usage: "address to run the api on, host:port", required: true},
+	{key: "grpc_url", usage: "address to run the grpc api on, host:port, none turns it off"},
+	{key: "debug_url", usage: "loopback address to serve /debug/vars on, host:port, none turns it off"},
+	{key: "log_level", def: "info", usage: "debug, info, warn or error"},
+
+	{key: "user", usage: "db user", required: true},
+	{key: "password", usage: "db password", secret: true},
+	{key: "host", usage: "db host", required: true},
+	{key: "db", usage: "db name", required: true},
+	{key: "db_max_open_conns", def: strconv.Itoa(defaultMaxOpenConns), usage: "max open connections to the db"},
+	{key: "db_max_idle_conns", def: strconv.Itoa(defaultMaxIdleConns), usage: "max idle connections kept in the pool"},
+	{key: "db_conn_max_lifetime", def: defaultConnMaxLifetime.String(), usage: "max time a connection is reused"},
+	{key: "db_conn_max_idle_time", def: defaultConnMaxIdleTime.String(), usage: "max time a connection stays idle in the pool"},
+	{key: "db_retry_attempts", def: strconv.Itoa(defaultRetryAttempts), usage: "attempts for reads and deadlocked transactions, 1 means no retry"},
+	{key: "db_retry_base_delay", def: defaultRetryBaseDelay.String(), usage: "delay before the first retry, doubled for every next one"},
+	{key: "db_retry_max_delay", def: defaultRetryMaxDelay.String(), usage: "max delay between retries"},
+
+	{key: "request_timeout", def: defaultRequestTimeout.String(), usage: "max time to serve a request"},
+	{key: "query_timeout", def: defaultQueryTimeout.String(), usage: "max time for a single db query"},
+	{key: "health_check_timeout", def: defaultDependencyCheckTimeout.String(), usage: "max time for a single dependency check in readiness"},
+
+	{key: "cache_size", def: strconv.Itoa(defaultCacheSize), usage: "max event types, and event tags, cached in memory"},
+	{key: "cache_ttl", def: defaultCacheTTL.String(), usage: "max time an event type or tag stays cached"},
+
+	{key: "cache_control_events", def: defaultCacheControl, usage: "Cache-Control header for the event list"},
+	{key: "cache_control_event", def: defaultCacheControl, usage: "Cache-Control header for a single event"},
+
+	{key: "retention_interval", def: defaultRetentionInterval.String(), usage: "time between retention runs"},
+	{key: "retention_deleted_events_days", def: strconv.Itoa(defaultRetentionDeletedDays), usage: "days deleted events are kept for syncing clients, 0 keeps them forever"},
+	{key: "retention_archive_after_years", def: "0", usage: "years after which events are moved to the archive, 0 never archives"},
+	{key: "retention_purge_orphan_tags", def: strconv.FormatBool(defaultRetentionPurgeOrphanTags), usage: "remove tags no event is tagged with"},
+	{key: "retention_purge_orphan_blobs", def: strconv.FormatBool(defaultRetentionPurgeOrphanBlobs), usage: "remove attachment content no attachment refers to"},
+	{key: "retention_batch_size", def: strconv.Itoa(defaultRetentionBatchSize), usage: "rows looked at in one go by a retention run"},
+
+	{key: "templates_interval", def: defaultTemplatesInterval.String(), usage: "time between runs materializing recurring event templates"},
+	{key: "templates_lookback", def: defaultTemplatesLookback.String(), usage: "how far back a templates run looks for occurrences, to catch up after downtime"},
+
+	{key: "timers_interval", def: defaultTimersInterval.String(), usage: "time between runs ending pomodoro timers which are over"},
+
+	{key: "attachments_dir", def: defaultAttachmentsDir, usage: "dir attachment content is kept in"},
+	{key: "attachments_max_bytes", def: strconv.Itoa(defaultAttachmentsMaxBytes), usage: "max size of an attachment upload request in bytes"},
+
+	{key: "rate_limit", def: defaultRateLimit, usage: "requests a second:burst allowed per client on every route, 0 for no limit"},
+	{key: "rate_limit_routes", def: defaultRouteRateLimits, usage: "limits for single routes, as METHOD /route=rate:burst separated by commas"},
+	{key: "max_body_bytes", def: strconv.Itoa(defaultMaxBodyBytes), usage: "max size of request bodies in bytes"},
+
+	{key: "cors_allowed_origins", usage: "origins browsers may call the api from, separated by commas, none turns CORS off"},
+	{key: "cors_allowed_methods", def: defaultCORSMethods, usage: "methods allowed from other origins"},
+	{key: "cors_allowed_headers", def: defaultCORSHeaders, usage: "request headers allowed from other origins"},
+	{key: "cors_exposed_headers", def: defaultCORSExposedHeaders, usage: "response headers readable from other origins"},
+	{key: "cors_allow_credentials", def: "false", usage: "allow cookies and auth headers from other origins"},
+	{key: "cors_max_age", def: defaultCORSMaxAge.String(), usage: "how long browsers may cache a preflight response"},
+
+	{key: "read_timeout", def: defaultReadTimeout.String(), usage: "max time to read a request"},
+	{key: "write_timeout", def: defaultWriteTimeout.String(), usage: "max time to write a response"},
+	{key: "idle_timeout", def: defaultIdleTimeout.String(), usage: "max time to keep an idle keep-alive connection"},
+	{key: "shutdown_timeout", def: defaultShutdownTimeout.String(), usage: "max time to drain requests on shutdown"},
+	{key: "max_header_bytes", def: strconv.Itoa(defaultMaxHeaderBytes), usage: "max size of request headers in bytes"},
+}
+
+// DBConfig holds what is needed to connect to the db, and how to use the connections
+type DBConfig struct {
+	User            string
+	Password        string
+	Host            string
+	Name            string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	Retry           RetryPolicy
+}
+
+// Config is the effective configuration of the api
+type Config struct {
+	LogLevel           string
+	GRPCURL            string
+	DebugURL           string
+	RequestTimeout     time.Duration
+	QueryTimeout       time.Duration
+	HealthCheckTimeout time.Duration
+	CacheSize          int
+	CacheTTL           time.Duration
+	CacheControlEvents string
+	CacheControlEvent  string
+	RetentionInterval  time.Duration
+	Retention          RetentionPolicy
+	TemplatesInterval  time.Duration
+	TemplatesLookback  time.Duration
+	TimersInterval     time.Duration
+	RateLimit          RateLimitConfig
+	MaxBodyBytes       int64
+	Attachments        AttachmentsConfig
+	CORS               CORSConfig
+	Server             ServerConfig
+	DB                 DBConfig
+}
+
+type configValue struct {
+	value  string
+	source int
+}
+
+// configValues are the raw values for all config keys, after applying precedence
+type configValues map[string]configValue
+
+func (values configValues) set(key, value string, source int) {
+	if current, ok := values[key]; ok && current.source > source {
+		return
+	}
+
+	values[key] = configValue{value: value, source: source}
+}
+
+// loadConfig builds the config from defaults, the properties file, environment variables and flags,
+// each overriding the one before it. Returns a readable error listing every invalid value.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, configValues, error) {
+	fn := "loadConfig"
+
+	values, _, err := loadConfigValues(args, lookupEnv, nil)
+	if err != nil {
+		return nil, nil, deepError.New(fn, "load config values", err)
+	}
+
+	config, err := parseConfig(values)
+	if err != nil {
+		return nil, values, err
+	}
+
+	return config, values, nil
+}
+
+// loadCommandConfig is loadConfig for a subcommand, which can have flags of its own next to the config ones.
+// Returns the arguments left after the flags.
+func loadCommandConfig(args []string, lookupEnv func(string) (string, bool), commandFlags *flag.FlagSet) (*Config, []string, error) {
+	fn := "loadCommandConfig"
+
+	values, rest, err := loadConfigValues(args, lookupEnv, commandFlags)
+	if err != nil {
+		return nil, nil, deepError.New(fn, "load config values", err)
+	}
+
+	config, err := parseConfig(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, rest, nil
+}
+
+func loadConfigValues(args []string, lookupEnv func(string) (string, bool), commandFlags *flag.FlagSet) (configValues, []string, error) {
+	fn := "loadConfigValues"
+
+	keys := make([]string, 0)
+	usages := make(map[string]string)
+	for _, option := range configOptions {
+		keys = append(keys, option.key)
+		usages[option.key] = option.usage
+		if option.secret {
+			keys = append(keys, option.key+secretFileSuffix)
+			usages[option.key+secretFileSuffix] = "file to read " + option.key + " from"
+		}
+	}
+
+	flags := flag.NewFlagSet("eventtracker", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String(configFlagName, "", "path to the properties file, default "+defaultConfigFile)
+	flagValues := make(map[string]*string)
+	for _, key := range keys {
+		flagValues[key] = flags.String(key, "", usages[key])
+	}
+	if commandFlags != nil {
+		commandFlags.VisitAll(func(f *flag.Flag) {
+			flags.Var(f.Value, f.Name, f.Usage)
+		})
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, deepError.New(fn, "parse flags", err)
+	}
+
+	values := make(configValues)
+	for _, option := range configOptions {
+		values.set(option.key, option.def, configSourceDefault)
+	}
+
+	// the file to read is itself configurable, only an explicitly asked for file has to exist
+	fileName, explicit := defaultConfigFile, false
+	if envFile, ok := lookupEnv(envPrefix + strings.ToUpper(configFlagName)); ok {
+		fileName, explicit = envFile, true
+	}
+	if *configFile != "" {
+		fileName, explicit = *configFile, true
+	}
+
+	p, err := properties.LoadFiles([]string{fileName}, properties.UTF8, !explicit)
+	if err != nil {
+		return nil, nil, deepError.New(fn, "load file "+fileName, err)
+	}
+
+	for _, key := range keys {
+		if value, ok := p.Get(key); ok {
+			values.set(key, value, configSourceFile)
+		}
+
+		if value, ok := lookupEnv(envPrefix + strings.ToUpper(key)); ok {
+			values.set(key, value, configSourceEnv)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if value, ok := flagValues[f.Name]; ok {
+			values.set(f.Name, *value, configSourceFlag)
+		}
+	})
+
+	err = values.resolveSecretFiles()
+	if err != nil {
+		return nil, nil, deepError.New(fn, "resolve secret files", err)
+	}
+
+	return values, flags.Args(), nil
+}
+
+// resolveSecretFiles replaces a secret with the contents of its _file variant, if that was set with higher precedence
+func (values configValues) resolveSecretFiles() error {
+	for _, option := range configOptions {
+		if !option.secret {
+			continue
+		}
+
+		fileKey := option.key + secretFileSuffix
+		secretFile, ok := values[fileKey]
+		delete(values, fileKey)
+		if !ok || secretFile.value == "" {
+			continue
+		}
+
+		if current, ok := values[option.key]; ok && current.source > secretFile.source {
+			continue
+		}
+
+		content, err := os.ReadFile(secretFile.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileKey, err)
+		}
+
+		values[option.key] = configValue{value: strings.TrimSpace(string(content)), source: secretFile.source}
+	}
+
+	return nil
+}
+
+// configParser converts raw values to typed ones, collecting every problem on the way
+type configParser struct {
+	values configValues
+	errs   []string
+}
+
+func (parser *configParser) fail(key, format string, args ...interface{}) {
+	parser.errs = append(parser.errs, fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (parser *configParser) getString(key string) string {
+	return strings.TrimSpace(parser.values[key].value)
+}
+
+func (parser *configParser) getDuration(key string) time.Duration {
+	raw := parser.getString(key)
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		parser.fail(key, "invalid duration %q", raw)
+		return 0
+	}
+	if value <= 0 {
+		parser.fail(key, "must be positive, got %s", raw)
+	}
+
+	return value
+}
+
+func (parser *configParser) getInt(key string) int {
+	raw := parser.getString(key)
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		parser.fail(key, "invalid number %q", raw)
+		return 0
+	}
+	if value <= 0 {
+		parser.fail(key, "must be positive, got %d", value)
+	}
+
+	return value
+}
+
+// getCount is like getInt, but allows zero
+func (parser *configParser) getCount(key string) int {
+	raw := parser.getString(key)
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		parser.fail(key, "invalid number %q", raw)
+		return 0
+	}
+	if value < 0 {
+		parser.fail(key, "must not be negative, got %d", value)
+	}
+
+	return value
+}
+
+func (parser *configParser) getBool(key string) bool {
+	raw := parser.getString(key)
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		parser.fail(key, "invalid boolean %q", raw)
+		return false
+	}
+
+	return value
+}
+
+func (parser *configParser) getRateLimit(key string) RateLimit {
+	limit, err := parseRateLimit(parser.getString(key))
+	if err != nil {
+		parser.fail(key, "%s", err)
+	}
+
+	return limit
+}
+
+func (parser *configParser) getRouteRateLimits(key string) map[string]RateLimit {
+	limits, err := parseRouteRateLimits(parser.getString(key))
+	if err != nil {
+		parser.fail(key, "%s", err)
+	}
+
+	return limits
+}
+
+func parseConfig(values configValues) (*Config, error) {
+	parser := &configParser{values: values}
+
+	for _, option := range configOptions {
+		if option.required && parser.getString(option.key) == "" {
+			parser.fail(option.key, "required, set it in the properties file, as %s or as -%s", envPrefix+strings.ToUpper(option.key), option.key)
+		}
+	}
+
+	config := &Config{
+		LogLevel:           strings.ToLower(parser.getString("log_level")),
+		GRPCURL:            parser.getString("grpc_url"),
+		DebugURL:           parser.getString("debug_url"),
+		RequestTimeout:     parser.getDuration("request_timeout"),
+		QueryTimeout:       parser.getDuration("query_timeout"),
+		HealthCheckTimeout: parser.getDuration("health_check_timeout"),
+		CacheSize:          parser.getInt("cache_size"),
+		CacheTTL:           parser.getDuration("cache_ttl"),
+		CacheControlEvents: parser.getString("cache_control_events"),
+		CacheControlEvent:  parser.getString("cache_control_event"),
+		RetentionInterval:  parser.getDuration("retention_interval"),
+		TemplatesInterval:  parser.getDuration("templates_interval"),
+		TemplatesLookback:  parser.getDuration("templates_lookback"),
+		TimersInterval:     parser.getDuration("timers_interval"),
+		Retention: RetentionPolicy{
+			DeletedEventsAfterDays: parser.getCount("retention_deleted_events_days"),
+			ArchiveAfterYears:      parser.getCount("retention_archive_after_years"),
+			PurgeOrphanTags:        parser.getBool("retention_purge_orphan_tags"),
+			PurgeOrphanBlobs:       parser.getBool("retention_purge_orphan_blobs"),
+			BatchSize:              parser.getInt("retention_batch_size"),
+		},
+		RateLimit: RateLimitConfig{
+			Default: parser.getRateLimit("rate_limit"),
+			Routes:  parser.getRouteRateLimits("rate_limit_routes"),
+		},
+		MaxBodyBytes: int64(parser.getInt("max_body_bytes")),
+		Attachments: AttachmentsConfig{
+			Dir:      parser.getString("attachments_dir"),
+			MaxBytes: int64(parser.getInt("attachments_max_bytes")),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   splitList(parser.getString("cors_allowed_origins")),
+			AllowedMethods:   splitList(parser.getString("cors_allowed_methods")),
+			AllowedHeaders:   splitList(parser.getString("cors_allowed_headers")),
+			ExposedHeaders:   splitList(parser.getString("cors_exposed_headers")),
+			AllowCredentials: parser.getBool("cors_allow_credentials"),
+			MaxAge:           parser.getDuration("cors_max_age"),
+		},
+		Server: ServerConfig{
+			URL:             parser.getString("url"),
+			ReadTimeout:     parser.getDuration("read_timeout"),
+			WriteTimeout:    parser.getDuration("write_timeout"),
+			IdleTimeout:     parser.getDuration("idle_timeout"),
+			MaxHeaderBytes:  parser.getInt("max_header_bytes"),
+			ShutdownTimeout: parser.getDuration("shutdown_timeout"),
+		},
+		DB: DBConfig{
+			User:     parser.getString("user"),
+			Password: parser.values["password"].value,
+			Host:     parser.getString("host"),
+			Name:     parser.getString("db"),
+
+			MaxOpenConns:    parser.getInt("db_max_open_conns"),
+			MaxIdleConns:    parser.getInt("db_max_idle_conns"),
+			ConnMaxLifetime: parser.getDuration("db_conn_max_lifetime"),
+			ConnMaxIdleTime: parser.getDuration("db_conn_max_idle_time"),
+			Retry: RetryPolicy{
+				MaxAttempts: parser.getInt("db_retry_attempts"),
+				BaseDelay:   parser.getDuration("db_retry_base_delay"),
+				MaxDelay:    parser.getDuration("db_retry_max_delay"),
+			},
+		},
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		parser.fail("log_level", "unknown level %q", config.LogLevel)
+	}
+
+	if config.DB.MaxIdleConns > config.DB.MaxOpenConns {
+		parser.fail("db_max_idle_conns", "%d is more than db_max_open_conns %d", config.DB.MaxIdleConns, config.DB.MaxOpenConns)
+	}
+
+	if config.GRPCURL != "" {
+		if _, _, err := net.SplitHostPort(config.GRPCURL); err != nil {
+			parser.fail("grpc_url", "expected host:port, got %q", config.GRPCURL)
+		}
+	}
+
+	if config.DebugURL != "" {
+		// the vars are served without auth, so only to the machine itself
+		host, _, err := net.SplitHostPort(config.DebugURL)
+		if ip := net.ParseIP(host); err != nil || (host != "localhost" && (ip == nil || !ip.IsLoopback())) {
+			parser.fail("debug_url", "expected a loopback host:port, got %q", config.DebugURL)
+		}
+	}
+
+	if config.CORS.AllowCredentials {
+		for _, origin := range config.CORS.AllowedOrigins {
+			if origin == "*" {
+				parser.fail("cors_allowed_origins", "can't be * when cors_allow_credentials is set, browsers reject it")
+			}
+		}
+	}
+
+	if config.Server.URL != "" {
+		if _, _, err := net.SplitHostPort(config.Server.URL); err != nil {
+			parser.fail("url", "expected host:port, got %q", config.Server.URL)
+		}
+	}
+
+	if len(parser.errs) > 0 {
+		return nil, errors.New("invalid config:\n  " + strings.Join(parser.errs, "\n  "))
+	}
+
+	return config, nil
+}
+
+// printConfig writes the effective config with the source of each value, with secrets redacted
+func printConfig(w io.Writer, values configValues) {
+	for _, option := range configOptions {
+		value, ok := values[option.key]
+		if !ok {
+			continue
+		}
+
+		shown := value.value
+		if option.secret && shown != "" {
+			shown = redactedValue
+		}
+
+		fmt.Fprintf(w, "%s=%s\t# %s\n", option.key, shown, configSourceNames[value.source])
+	}
+}
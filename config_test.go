@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(fileName, []byte(content), 0600)
+	util.Test.HandleIfTestError(t, err, "writeTestFile")
+
+	return fileName
+}
+
+func testEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	fn := "TestConfigPrecedence"
+
+	configFile := writeTestFile(t, "app.properties", `
+url=localhost:8080
+user=file_user
+host=localhost:3306
+db=events
+query_timeout=5s
+`)
+	passwordFile := writeTestFile(t, "password", "s3cret\n")
+
+	env := testEnv(map[string]string{
+		"EVENTTRACKER_USER":          "env_user",
+		"EVENTTRACKER_QUERY_TIMEOUT": "7s",
+		"EVENTTRACKER_PASSWORD_FILE": passwordFile,
+	})
+
+	config, _, err := loadConfig([]string{"-config", configFile, "-query_timeout", "9s"}, env)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	util.Test.AssertEquals(t, "localhost:8080", config.Server.URL, fn+": file value not used")
+	util.Test.AssertEquals(t, "env_user", config.DB.User, fn+": env should override file")
+	util.Test.AssertEquals(t, 9*time.Second, config.QueryTimeout, fn+": flag should override env")
+	util.Test.AssertEquals(t, "s3cret", config.DB.Password, fn+": secret not read from file")
+	util.Test.AssertEquals(t, defaultRequestTimeout, config.RequestTimeout, fn+": default not used")
+}
+
+func TestConfigValidation(t *testing.T) {
+	fn := "TestConfigValidation"
+
+	configFile := writeTestFile(t, "app.properties", `
+url=8080
+user=root
+query_timeout=soon
+retention_deleted_events_days=-1
+retention_purge_orphan_tags=sometimes
+debug_url=0.0.0.0:6060
+`)
+
+	_, _, err := loadConfig([]string{"-config", configFile}, testEnv(nil))
+	if err == nil {
+		util.Test.HandleIfTestError(t, errors.New("Expected validation error"), fn)
+	}
+
+	for _, key := range []string{"url:", "host:", "db:", "query_timeout:", "retention_deleted_events_days:", "retention_purge_orphan_tags:", "debug_url:"} {
+		util.Test.AssertEquals(t, true, strings.Contains(err.Error(), key), fn+": missing error for "+key)
+	}
+	util.Test.AssertEquals(t, false, strings.Contains(err.Error(), "user:"), fn+": unexpected error for user")
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	fn := "TestConfigPrintRedactsSecrets"
+
+	env := testEnv(map[string]string{
+		"EVENTTRACKER_PASSWORD": "s3cret",
+	})
+
+	_, values, _ := loadConfig([]string{"-config", writeTestFile(t, "app.properties", "")}, env)
+
+	var out bytes.Buffer
+	printConfig(&out, values)
+
+	util.Test.AssertEquals(t, false, strings.Contains(out.String(), "s3cret"), fn+": secret printed")
+	util.Test.AssertEquals(t, true, strings.Contains(out.String(), "password="+redactedValue+"\t# env"), fn+": secret not redacted")
+}
+
+func TestCommandConfig(t *testing.T) {
+	fn := "TestCommandConfig"
+
+	configFile := writeTestFile(t, "app.properties", `
+url=localhost:8080
+user=root
+host=localhost:3306
+db=events
+`)
+
+	flags := flag.NewFlagSet("issue-token", flag.ContinueOnError)
+	name := flags.String("name", "default", "what the token is for")
+
+	config, rest, err := loadCommandConfig([]string{"-name", "laptop", "-config", configFile, "-query_timeout", "9s", "bob"}, testEnv(nil), flags)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	util.Test.AssertEquals(t, "laptop", *name, fn+": command flag not set")
+	util.Test.AssertEquals(t, 9*time.Second, config.QueryTimeout, fn+": config flag not set")
+	util.Test.AssertEquals(t, []string{"bob"}, rest, fn+": wrong arguments left")
+}
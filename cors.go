@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/handlers"
+)
+
+const (
+	defaultCORSMethods        = "GET,HEAD,POST,DELETE"
+	defaultCORSHeaders        = "Content-Type,Authorization,If-None-Match,If-Modified-Since," + headerRequestID
+	defaultCORSExposedHeaders = "ETag,Last-Modified,Retry-After," + headerRequestID
+	defaultCORSMaxAge         = 10 * time.Minute
+)
+
+// CORSConfig says which browser origins may call the api, and how. No allowed origins turns CORS off.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// splitList reads a comma separated config value, dropping empty entries
+func splitList(raw string) []string {
+	list := make([]string, 0)
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// CORSMiddleware answers preflight requests and adds the CORS headers for allowed origins.
+// It has to wrap the router rather than be used on it, as preflight OPTIONS requests match no route, and wrap
+// AuthMiddleware too, so that browsers can read its 401s.
+func CORSMiddleware(config CORSConfig) func(http.Handler) http.Handler {
+	if len(config.AllowedOrigins) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	options := []handlers.CORSOption{
+		handlers.AllowedOrigins(config.AllowedOrigins),
+		handlers.AllowedMethods(config.AllowedMethods),
+		handlers.AllowedHeaders(config.AllowedHeaders),
+		handlers.ExposedHeaders(config.ExposedHeaders),
+		handlers.MaxAge(int(config.MaxAge.Seconds())),
+	}
+	if config.AllowCredentials {
+		options = append(options, handlers.AllowCredentials())
+	}
+
+	return handlers.CORS(options...)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	fn := "TestCORSPreflight"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetEvent, func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	handler := CORSMiddleware(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: splitList(defaultCORSMethods),
+		AllowedHeaders: splitList(defaultCORSHeaders),
+		ExposedHeaders: splitList(defaultCORSExposedHeaders),
+		MaxAge:         defaultCORSMaxAge,
+	})(router)
+
+	req := httptest.NewRequest(http.MethodOptions, "/events/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "If-None-Match")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for preflight")
+	util.Test.AssertEquals(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"), fn+": Origin not allowed")
+	util.Test.AssertEquals(t, "600", rr.Header().Get("Access-Control-Max-Age"), fn+": Wrong max age")
+
+	req = httptest.NewRequest(http.MethodOptions, "/events/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for delete preflight")
+
+	req = httptest.NewRequest(http.MethodGet, "/events/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"), fn+": Origin not allowed on request")
+	util.Test.AssertEquals(t, true, rr.Header().Get("Access-Control-Expose-Headers") != "", fn+": Headers not exposed")
+
+	req = httptest.NewRequest(http.MethodGet, "/events/1", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, "", rr.Header().Get("Access-Control-Allow-Origin"), fn+": Unknown origin allowed")
+}
+
+func TestCORSUnauthorized(t *testing.T) {
+	fn := "TestCORSUnauthorized"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetEvents, func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	// wrapped as in main, so that a 401 is readable from the browser
+	handler := CORSMiddleware(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: splitList(defaultCORSMethods),
+	})(AuthMiddleware(testAuthenticator{})(router))
+
+	req := httptest.NewRequest(http.MethodGet, routeGetEvents, nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Authorization", "Bearer et_unknown")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusUnauthorized, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"), fn+": No CORS headers on 401")
+}
+
+func TestCORSDisabled(t *testing.T) {
+	fn := "TestCORSDisabled"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetEvents, func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	handler := CORSMiddleware(CORSConfig{MaxAge: time.Minute})(router)
+
+	req := httptest.NewRequest(http.MethodGet, routeGetEvents, nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, "", rr.Header().Get("Access-Control-Allow-Origin"), fn+": CORS headers without allowed origins")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// expvarCmdline is published by expvar itself, with every flag the server was started with, -password too
+const expvarCmdline = "cmdline"
+
+// DebugVarsHandler serves the published vars like expvar.Handler, leaving out cmdline
+func DebugVarsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if kv.Key == expvarCmdline {
+				return
+			}
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
+
+// runDebugServer serves the debug routes apart from the api, so they are never reachable through it, till ctx is done
+func runDebugServer(ctx context.Context, srv *http.Server, logger *slog.Logger) {
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting debug server", "url", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("debug server stopped with error", errorAttr(err))
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	err := srv.Close()
+	if err != nil {
+		logger.Error("closing debug server failed", errorAttr(err))
+	}
+}
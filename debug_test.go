@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func TestDebugVarsHandler(t *testing.T) {
+	fn := "TestDebugVarsHandler"
+
+	req, err := http.NewRequest(http.MethodGet, routeGetDebugVars, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	DebugVarsHandler().ServeHTTP(rr, req)
+
+	vars := make(map[string]json.RawMessage)
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &vars), fn)
+	_, hasCmdline := vars[expvarCmdline]
+	util.Test.AssertEquals(t, false, hasCmdline, fn+": cmdline published")
+	_, hasCache := vars["cache"]
+	util.Test.AssertEquals(t, true, hasCache, fn+": cache stats not published")
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
@@ -11,18 +14,22 @@ import (
 
 var (
 	queryGetEvents = fmt.Sprintf(`
-		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
-		FROM %s E`,
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE E.%s = '%s'`,
 		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
-		eventsTableName)
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
+		eventsTableName,
+		colStatus, statusActive)
 
 	queryGetEvent = fmt.Sprintf(`
-		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
 		FROM %s E
-		WHERE E.%s = ?`,
+		WHERE E.%s = ? AND E.%s = '%s'`,
 		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
 		eventsTableName,
-		eventsColID)
+		eventsColID, colStatus, statusActive)
 
 	queryGetEventType = fmt.Sprintf(`
 		SELECT ET.%s, ET.%s, ET.%s, ET.%s, ET.%s
@@ -50,9 +57,66 @@ var (
 
 // IEventsHandler is the common interface to use for events business logic
 type IEventsHandler interface {
-	GetAllEvents() ([]*Event, error)
-	GetEvent(eventID string) (*Event, error)
-	CreateEvent(*Event) (string, error)
+	GetAllEvents(ctx context.Context) ([]*Event, error)
+	GetLastChangeTime(ctx context.Context) (time.Time, error)
+	GetEvent(ctx context.Context, eventID string) (*Event, error)
+	CreateEvent(ctx context.Context, event *Event) (string, error)
+	GetEventHistory(ctx context.Context, eventID string) ([]*AuditEntry, error)
+	GetChanges(ctx context.Context, since SyncToken, limit int) (*SyncChanges, error)
+	PushChanges(ctx context.Context, changes []*EventChange) (*PushResult, error)
+	GetEventRevisions(ctx context.Context, eventID string) ([]*EventRevision, error)
+	GetEventRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error)
+	RestoreEventRevision(ctx context.Context, eventID string, revision int) (*Event, error)
+	ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error)
+	FindEvents(ctx context.Context, filter EventFilter) ([]*Event, error)
+	GetEventTypes(ctx context.Context) ([]*EventType, error)
+	GetEventTags(ctx context.Context) ([]*EventTag, error)
+	CreateTemplate(ctx context.Context, template *EventTemplate) (string, error)
+	GetTemplates(ctx context.Context) ([]*EventTemplate, error)
+	GetTemplate(ctx context.Context, templateID string) (*EventTemplate, error)
+	DeleteTemplate(ctx context.Context, templateID string) error
+	AddTemplateException(ctx context.Context, templateID string, at time.Time) (*EventTemplate, error)
+	MaterializeTemplates(ctx context.Context, from, to time.Time) (*MaterializeReport, error)
+	CreateEventLink(ctx context.Context, fromEventID, linkType, toEventID string) (*EventLink, error)
+	GetEventLinks(ctx context.Context, eventID string) ([]*EventLink, error)
+	DeleteEventLink(ctx context.Context, eventID, linkID string) error
+	CreateFieldSchema(ctx context.Context, schema *FieldSchema) error
+	GetFieldSchemas(ctx context.Context) ([]*FieldSchema, error)
+	DeleteFieldSchema(ctx context.Context, name string) error
+	CreateAttachments(ctx context.Context, eventID string, attachments []*Attachment) error
+	GetAttachments(ctx context.Context, eventID string) ([]*Attachment, error)
+	GetAttachment(ctx context.Context, eventID, attachmentID string) (*Attachment, error)
+	DeleteAttachment(ctx context.Context, eventID, attachmentID string) error
+	FindAttachedBlobs(ctx context.Context, hashes []string) (map[string]bool, error)
+	CreateGoal(ctx context.Context, goal *Goal) (string, error)
+	GetGoals(ctx context.Context) ([]*Goal, error)
+	GetGoal(ctx context.Context, goalID string) (*Goal, error)
+	DeleteGoal(ctx context.Context, goalID string) error
+	StartTimer(ctx context.Context, timer *Timer, event *Event) error
+	GetTimer(ctx context.Context, owner string) (*Timer, error)
+	PauseTimer(ctx context.Context, owner string, at time.Time) (*Timer, error)
+	ResumeTimer(ctx context.Context, owner string, at time.Time) (*Timer, error)
+	StopTimer(ctx context.Context, owner string, at time.Time) (*Timer, error)
+	EndDueTimers(ctx context.Context, owner string, now time.Time) (int, error)
+}
+
+// maxFindEventsLimit is the most events a client may ask to find in one go
+const maxFindEventsLimit = 1000
+
+// EventFilter narrows down the events to find. Zero values match everything.
+type EventFilter struct {
+	Type string
+	// Tags has to be on the event, all of them
+	Tags []string
+	// From and To bound the user time of the event, From inclusive and To exclusive
+	From time.Time
+	To   time.Time
+	// Search is looked for in the title and note
+	Search string
+	// Fields have to have their values on the event, all of them
+	Fields []FieldFilter
+	// Limit of 0 means no limit
+	Limit int
 }
 
 // EventsHandler is a concrete event handler for mysql
@@ -61,138 +125,518 @@ type EventsHandler struct {
 	dbStuff *dbStuff
 }
 
+// HandlerOptions tunes how the handler uses the db. The zero value means no timeouts, retries or caching.
+type HandlerOptions struct {
+	// QueryTimeout bounds every single db query
+	QueryTimeout time.Duration
+	// Retry applies to reads and to transactions aborted by mysql for deadlocks
+	Retry RetryPolicy
+	// CacheSize is the max number of event types, and of event tags, kept in memory
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
 // Init initialises the handler
-func (handler *EventsHandler) Init(db *sql.DB) {
-	handler.dbStuff = &dbStuff{db}
+func (handler *EventsHandler) Init(db *sql.DB, options HandlerOptions) {
+	handler.dbStuff = newDbStuff(db, options)
 	handler.db = db
 }
 
 // GetAllEvents gets all events
-func (handler *EventsHandler) GetAllEvents() ([]*Event, error) {
+func (handler *EventsHandler) GetAllEvents(ctx context.Context) ([]*Event, error) {
 	fn := "GetEvents"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := handler.db.Query(queryGetEvents)
+	rows, err := handler.dbStuff.query(queryCtx, queryGetEvents)
 	if err != nil {
+		logger.Error("query events failed", errorAttr(err))
 		return nil, deepError.New(fn, "query", err)
 	}
 	defer rows.Close()
 
-	events, err := handler.getEventsFromRows(rows)
+	events, err := handler.getEventsFromRows(ctx, rows)
 	if err != nil {
+		logger.Error("reading events failed", errorAttr(err))
 		return nil, deepError.New(fn, "getEventsFromDb", err)
 	}
 
+	logger.Debug("fetched events", "count", len(events))
 	return events, nil
 }
 
+// GetLastChangeTime is when an event was last written, deleted and archived ones included, zero if never.
+// Dropping an event from a list leaves it at least that late, where the events left in the list may not be.
+func (handler *EventsHandler) GetLastChangeTime(ctx context.Context) (time.Time, error) {
+	fn := "GetLastChangeTime"
+
+	query := fmt.Sprintf(`
+		SELECT MAX(W.changed_at) FROM (
+			SELECT MAX(%s) AS changed_at FROM %s
+			UNION ALL
+			SELECT MAX(%s) FROM %s
+		) W`,
+		colUpdatedAt, eventsTableName,
+		colCreatedAt, eventsArchiveTableName)
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, query)
+	if err != nil {
+		return time.Time{}, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	var lastChange sql.NullTime
+	if rows.Next() {
+		err = rows.Scan(&lastChange)
+		if err != nil {
+			return time.Time{}, deepError.New(fn, "scan", err)
+		}
+	}
+
+	return lastChange.Time, nil
+}
+
 // GetEvent finds an event by event id
-func (handler *EventsHandler) GetEvent(eventID string) (*Event, error) {
+func (handler *EventsHandler) GetEvent(ctx context.Context, eventID string) (*Event, error) {
 	fn := "GetEvent"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", eventID)
 
-	rows, err := handler.db.Query(queryGetEvent, eventID)
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, queryGetEvent, eventID)
 	if err != nil {
+		logger.Error("query event failed", errorAttr(err))
 		return nil, deepError.New(fn, "query", err)
 	}
 	defer rows.Close()
 
-	events, err := handler.getEventsFromRows(rows)
+	events, err := handler.getEventsFromRows(ctx, rows)
 	if err != nil {
+		logger.Error("reading event failed", errorAttr(err))
 		return nil, deepError.New(fn, "getEventsFromDb", err)
 	}
 
 	if len(events) == 0 {
+		logger.Debug("event not found")
 		return nil, nil
 	}
 
 	return events[0], nil
 }
 
-// CreateEvent creates an event
-// TODO: transactions
-func (handler *EventsHandler) CreateEvent(event *Event) (string, error) {
+// CreateEvent creates an event, along with its type and tags if they are new, in a single transaction
+func (handler *EventsHandler) CreateEvent(ctx context.Context, event *Event) (string, error) {
 	fn := "CreateEvent"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	event.ID = uuid.New().String()
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		return insertEventWithTags(ctx, txStuff, event)
+	})
+	if err != nil {
+		logger.Error("create event failed", errorAttr(err))
+		return "", deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("event created", "event_id", event.ID)
+	return event.ID, nil
+}
+
+// FindEvents finds the events matching filter, ordered by their user time
+func (handler *EventsHandler) FindEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	fn := "FindEvents"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	conditions := []string{fmt.Sprintf("E.%s = '%s'", colStatus, statusActive)}
+	args := make([]interface{}, 0)
+
+	if filter.Type != "" {
+		conditions = append(conditions, fmt.Sprintf("E.%s = (SELECT ETP.%s FROM %s ETP WHERE ETP.%s = ?)",
+			eventsColTypeID, colDbID, eventTypesTableName, eventTypesColValue))
+		args = append(args, filter.Type)
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`E.%s IN (
+			SELECT ETM.%s FROM %s ETM JOIN %s ETG ON ETG.%s = ETM.%s
+			WHERE ETG.%s IN (%s)
+			GROUP BY ETM.%s HAVING COUNT(DISTINCT ETG.%s) = ?)`,
+			colDbID,
+			eventTagMapColEventID, eventTagMapTableName, eventTagsTableName, colDbID, eventTagMapColTagID,
+			eventTagsColValue, inPlaceholders(len(filter.Tags)),
+			eventTagMapColEventID, colDbID))
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(filter.Tags))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("E.%s >= ?", eventsColCreatedAt))
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("E.%s < ?", eventsColCreatedAt))
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, fmt.Sprintf("(E.%s LIKE ? OR E.%s LIKE ?)", eventsColTitle, eventsColNote))
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		args = append(args, pattern, pattern)
+	}
+	for _, field := range filter.Fields {
+		conditions = append(conditions, fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(E.%s, ?)) = ?", eventsColFields))
+		args = append(args, fieldPath(field.Name), field.Value)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE %s
+		ORDER BY E.%s, E.%s`,
+		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
+		eventsTableName,
+		strings.Join(conditions, " AND "),
+		eventsColCreatedAt, colDbID)
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, query, args...)
+	if err != nil {
+		logger.Error("query events failed", errorAttr(err))
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	events, err := handler.getEventsFromRows(ctx, rows)
+	if err != nil {
+		logger.Error("reading events failed", errorAttr(err))
+		return nil, deepError.New(fn, "getEventsFromDb", err)
+	}
+
+	return events, nil
+}
+
+// escapeLike makes the wildcards of a LIKE pattern match themselves
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// GetEventTypes gets all the event types
+func (handler *EventsHandler) GetEventTypes(ctx context.Context) ([]*EventType, error) {
+	fn := "GetEventTypes"
+
+	query := fmt.Sprintf(`
+		SELECT ETP.%s, ETP.%s, ETP.%s, ETP.%s, ETP.%s
+		FROM %s ETP
+		WHERE ETP.%s = '%s'
+		ORDER BY ETP.%s`,
+		colDbID, eventTypesColValue, colCreatedAt, colUpdatedAt, colStatus,
+		eventTypesTableName,
+		colStatus, statusActive,
+		eventTypesColValue)
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, query)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	eventTypes := make([]*EventType, 0)
+	for rows.Next() {
+		eventType := &EventType{}
+		err = rows.Scan(&eventType.DbID, &eventType.Value, &eventType.CreatedAt, &eventType.UpdatedAt, &eventType.Status)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	return eventTypes, nil
+}
+
+// GetEventTags gets all the event tags
+func (handler *EventsHandler) GetEventTags(ctx context.Context) ([]*EventTag, error) {
+	fn := "GetEventTags"
+
+	query := fmt.Sprintf(`
+		SELECT ETG.%s, ETG.%s, ETG.%s, ETG.%s, ETG.%s
+		FROM %s ETG
+		WHERE ETG.%s = '%s'
+		ORDER BY ETG.%s`,
+		colDbID, eventTagsColValue, colCreatedAt, colUpdatedAt, colStatus,
+		eventTagsTableName,
+		colStatus, statusActive,
+		eventTagsColValue)
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, query)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	eventTags := make([]*EventTag, 0)
+	for rows.Next() {
+		eventTag := &EventTag{}
+		err = rows.Scan(&eventTag.DbID, &eventTag.Value, &eventTag.CreatedAt, &eventTag.UpdatedAt, &eventTag.Status)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		eventTags = append(eventTags, eventTag)
+	}
+
+	return eventTags, nil
+}
+
+// insertEventWithTags inserts an event with an already set ID, creating its type and tags if needed
+func insertEventWithTags(ctx context.Context, txStuff *dbStuff, event *Event) error {
+	fn := "insertEventWithTags"
+
+	err := resolveTypeAndTags(ctx, txStuff, event)
+	if err != nil {
+		return deepError.New(fn, "resolve type and tags", err)
+	}
+
+	event.setUTCOffset()
+	event.UserCreatedAt = event.UserCreatedAt.UTC()
+	eventDbID, err := txStuff.insertEvent(ctx, event)
+	if err != nil {
+		return deepError.New(fn, "insert event", err)
+	}
+	event.DbID = eventDbID
 
-	eventType, err := handler.findOrCreateEventType(event.Type.Value)
+	err = insertEventTagMappings(ctx, txStuff, event)
 	if err != nil {
-		return "", deepError.New(fn, "find or create event type", err)
+		return deepError.New(fn, "insert event tag mappings", err)
+	}
+
+	err = recordAudit(ctx, txStuff, auditEntityEvent, event.ID, auditActionCreate, nil, newEventSnapshot(event))
+	if err != nil {
+		return deepError.New(fn, "record audit", err)
+	}
+
+	err = recordRevision(ctx, txStuff, nil, event)
+	if err != nil {
+		return deepError.New(fn, "record revision", err)
+	}
+
+	return nil
+}
+
+// updateEventWithTags overwrites an existing event, found by its DbID, and replaces its tags.
+// before is the event as it was, with its type and tags loaded, for the audit log. action is audited as given.
+func updateEventWithTags(ctx context.Context, txStuff *dbStuff, action string, before, event *Event) error {
+	fn := "updateEventWithTags"
+
+	err := resolveTypeAndTags(ctx, txStuff, event)
+	if err != nil {
+		return deepError.New(fn, "resolve type and tags", err)
+	}
+
+	event.setUTCOffset()
+	event.UserCreatedAt = event.UserCreatedAt.UTC()
+	event.Status = statusActive
+	err = txStuff.updateEvent(ctx, event)
+	if err != nil {
+		return deepError.New(fn, "update event", err)
+	}
+
+	err = txStuff.deleteEventTagMappings(ctx, event.DbID)
+	if err != nil {
+		return deepError.New(fn, "delete event tag mappings", err)
+	}
+
+	err = insertEventTagMappings(ctx, txStuff, event)
+	if err != nil {
+		return deepError.New(fn, "insert event tag mappings", err)
+	}
+
+	err = recordAudit(ctx, txStuff, auditEntityEvent, event.ID, action, newEventSnapshot(before), newEventSnapshot(event))
+	if err != nil {
+		return deepError.New(fn, "record audit", err)
+	}
+
+	err = recordRevision(ctx, txStuff, before, event)
+	if err != nil {
+		return deepError.New(fn, "record revision", err)
+	}
+
+	return nil
+}
+
+// deleteEvent soft deletes an event. before is the event as it was, with its type and tags loaded, for the audit log.
+func deleteEvent(ctx context.Context, txStuff *dbStuff, before *Event) error {
+	fn := "deleteEvent"
+
+	err := txStuff.markEventDeleted(ctx, before.DbID)
+	if err != nil {
+		return deepError.New(fn, "mark event deleted", err)
+	}
+
+	err = txStuff.touchEventsLinkedTo(ctx, before.DbID)
+	if err != nil {
+		return deepError.New(fn, "touch linked events", err)
+	}
+
+	after := *before
+	after.Status = statusDeleted
+	err = recordAudit(ctx, txStuff, auditEntityEvent, before.ID, auditActionDelete, newEventSnapshot(before), newEventSnapshot(&after))
+	if err != nil {
+		return deepError.New(fn, "record audit", err)
+	}
+
+	err = recordRevision(ctx, txStuff, before, &after)
+	if err != nil {
+		return deepError.New(fn, "record revision", err)
+	}
+
+	return nil
+}
+
+// loadEventDetails fills in the type and tags of an event read on its own
+func loadEventDetails(ctx context.Context, dbStuff *dbStuff, event *Event) error {
+	fn := "loadEventDetails"
+
+	eventType, err := dbStuff.findEventTypeByID(ctx, event.Type.DbID)
+	if err != nil {
+		return deepError.New(fn, "find event type by id", err)
+	}
+	event.Type = eventType
+
+	eventTags, err := dbStuff.findEventTagsByEventID(ctx, event.ID)
+	if err != nil {
+		return deepError.New(fn, "find event tags by event id", err)
+	}
+	event.Tags = eventTags
+
+	return nil
+}
+
+// resolveTypeAndTags replaces the type and tags of the event with their db versions, creating the ones that are new
+func resolveTypeAndTags(ctx context.Context, txStuff *dbStuff, event *Event) error {
+	fn := "resolveTypeAndTags"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	eventType, err := findOrCreateEventType(ctx, txStuff, event.Type.Value)
+	if err != nil {
+		return deepError.New(fn, "find or create event type", err)
 	}
 	event.Type = eventType
 
 	updatedEventTags := make([]*EventTag, 0)
 	for _, eventTag := range event.Tags {
 		if eventTag == nil {
-			fmt.Println("Got a nil tag")
+			logger.Warn("skipping nil tag")
 			continue
 		}
 
-		foundEventTag, err2 := handler.findOrCreateEventTag(eventTag.Value)
-		if err2 != nil {
-			return "", deepError.New(fn, "find or create event tag", err2)
+		foundEventTag, err := findOrCreateEventTag(ctx, txStuff, eventTag.Value)
+		if err != nil {
+			return deepError.New(fn, "find or create event tag", err)
 		}
 		updatedEventTags = append(updatedEventTags, foundEventTag)
 	}
 	event.Tags = updatedEventTags
 
-	event.ID = uuid.New().String()
-	event.UserCreatedAt = event.UserCreatedAt.UTC()
-	eventDbID, err3 := handler.dbStuff.insertEvent(event)
-	if err3 != nil {
-		return "", deepError.New(fn, "insert event", err3)
-	}
-	event.DbID = eventDbID
+	return nil
+}
 
-	for _, eventTag := range event.Tags {
+// insertEventTagMappings maps the event to its resolved tags. A tag can have been cached before vacuum-orphans
+// removed it from another process, so a tag refused by the foreign key is dropped from the cache, found or created
+// again and mapped once more.
+func insertEventTagMappings(ctx context.Context, txStuff *dbStuff, event *Event) error {
+	fn := "insertEventTagMappings"
+
+	for i, eventTag := range event.Tags {
 		eventTagMap := &EventTagMap{EventID: event.DbID, TagID: eventTag.DbID}
-		_, err4 := handler.dbStuff.insertEventTagMapping(eventTagMap)
-		if err4 != nil {
-			return "", deepError.New(fn, "insert event tag map", err4)
+		_, err := txStuff.insertEventTagMapping(ctx, eventTagMap)
+		if isForeignKeyError(err) {
+			txStuff.invalidateEventTag(eventTag)
+			eventTag, err = findOrCreateEventTag(ctx, txStuff, eventTag.Value)
+			if err != nil {
+				return deepError.New(fn, "find or create event tag", err)
+			}
+			event.Tags[i] = eventTag
+
+			eventTagMap.TagID = eventTag.DbID
+			_, err = txStuff.insertEventTagMapping(ctx, eventTagMap)
+		}
+		if err != nil {
+			return deepError.New(fn, "insert event tag map", err)
 		}
 	}
 
-	return event.ID, nil
+	return nil
 }
 
-func (handler *EventsHandler) findOrCreateEventType(value string) (*EventType, error) {
+func findOrCreateEventType(ctx context.Context, dbStuff *dbStuff, value string) (*EventType, error) {
 	fn := "findOrCreateEventType"
 
-	eventType, err := handler.dbStuff.findEventTypeByValue(value)
+	eventType, err := dbStuff.findEventTypeByValue(ctx, value)
 	if err != nil {
 		return nil, deepError.New(fn, "find event type", err)
 	}
 
 	if eventType == nil {
 		eventType = &EventType{Value: value}
-		eventTypeDbID, err2 := handler.dbStuff.insertEventType(eventType)
+		eventTypeDbID, err2 := dbStuff.insertEventType(ctx, eventType)
 		if err2 != nil {
-			return nil, deepError.New(fn, "insert event type", err)
+			return nil, deepError.New(fn, "insert event type", err2)
 		}
 		eventType.DbID = eventTypeDbID
+
+		err = recordAudit(ctx, dbStuff, auditEntityEventType, eventType.Value, auditActionCreate, nil, eventType)
+		if err != nil {
+			return nil, deepError.New(fn, "record audit", err)
+		}
 	}
 
 	return eventType, nil
 }
 
-func (handler *EventsHandler) findOrCreateEventTag(value string) (*EventTag, error) {
+func findOrCreateEventTag(ctx context.Context, dbStuff *dbStuff, value string) (*EventTag, error) {
 	fn := "findOrCreateEventTag"
 
-	eventTag, err := handler.dbStuff.findEventTagByValue(value)
+	eventTag, err := dbStuff.findEventTagByValue(ctx, value)
 	if err != nil {
 		return nil, deepError.New(fn, "find event tag by value", err)
 	}
 
 	if eventTag == nil {
 		eventTag = &EventTag{Value: value}
-		eventTagDbID, err := handler.dbStuff.insertEventTag(eventTag)
+		eventTagDbID, err := dbStuff.insertEventTag(ctx, eventTag)
 		if err != nil {
 			return nil, deepError.New(fn, "insert event tag", err)
 		}
 		eventTag.DbID = eventTagDbID
+
+		err = recordAudit(ctx, dbStuff, auditEntityEventTag, eventTag.Value, auditActionCreate, nil, eventTag)
+		if err != nil {
+			return nil, deepError.New(fn, "record audit", err)
+		}
 	}
 
 	return eventTag, nil
 }
 
-func (handler *EventsHandler) getEventsFromRows(rows *sql.Rows) ([]*Event, error) {
+func (handler *EventsHandler) getEventsFromRows(ctx context.Context, rows *sql.Rows) ([]*Event, error) {
 	fn := "getEventsFromDb"
 
 	events := make([]*Event, 0)
@@ -202,28 +646,61 @@ func (handler *EventsHandler) getEventsFromRows(rows *sql.Rows) ([]*Event, error
 		event.Type = &EventType{}
 		event.Tags = make([]*EventTag, 0)
 
-		err := rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status)
+		err := rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+			&event.TimeZone, &event.UTCOffset, &event.Fields)
 		if err != nil {
 			return nil, deepError.New(fn, "scan", err)
 		}
 
-		typeID := event.Type.DbID
-		eventType, err := handler.dbStuff.findEventTypeByID(typeID)
-		if err != nil {
-			return nil, deepError.New(fn, "find event type by id", err)
-		}
+		events = append(events, event)
+	}
 
-		event.Type = eventType
+	err := loadEventsDetails(ctx, handler.dbStuff, events)
+	if err != nil {
+		return nil, deepError.New(fn, "load events details", err)
+	}
 
-		eventTags, err := handler.dbStuff.findEventTagsByEventID(event.ID)
-		if err != nil {
-			return nil, deepError.New(fn, "find event tags by event id", err)
-		}
+	return events, nil
+}
 
-		event.Tags = eventTags
+// loadEventsDetails fills in the types and tags of many events, with one query for each rather than one per event
+func loadEventsDetails(ctx context.Context, dbStuff *dbStuff, events []*Event) error {
+	fn := "loadEventsDetails"
 
-		events = append(events, event)
+	if len(events) == 0 {
+		return nil
 	}
 
-	return events, nil
+	typeIDs := make([]int64, 0)
+	eventDbIDs := make([]int64, 0)
+	for _, event := range events {
+		typeIDs = append(typeIDs, event.Type.DbID)
+		eventDbIDs = append(eventDbIDs, event.DbID)
+	}
+
+	eventTypes, err := dbStuff.findEventTypesByIDs(ctx, typeIDs)
+	if err != nil {
+		return deepError.New(fn, "find event types by ids", err)
+	}
+
+	eventTags, err := dbStuff.findEventTagsByEventDbIDs(ctx, eventDbIDs)
+	if err != nil {
+		return deepError.New(fn, "find event tags by event ids", err)
+	}
+
+	eventLinks, err := dbStuff.findEventLinksByEventDbIDs(ctx, eventDbIDs)
+	if err != nil {
+		return deepError.New(fn, "find event links by event ids", err)
+	}
+
+	for _, event := range events {
+		event.Type = eventTypes[event.Type.DbID]
+		event.Tags = eventTags[event.DbID]
+		if event.Tags == nil {
+			event.Tags = make([]*EventTag, 0)
+		}
+		event.Links = eventLinks[event.DbID]
+	}
+
+	return nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -14,7 +15,12 @@ import (
 
 func InitDb() *sql.DB {
 	p := properties.MustLoadFile("test.properties", properties.UTF8)
-	db, err := getDbFromProps(p)
+	db, err := getDb(DBConfig{
+		User:     p.GetString("user", ""),
+		Password: p.GetString("password", ""),
+		Host:     p.GetString("host", ""),
+		Name:     p.GetString("db", ""),
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -31,10 +37,11 @@ func TestGetEventDao(t *testing.T) {
 
 	defer util.Db.ClearTables(db, eventTagMapTableName, eventsTableName, eventTagsTableName)
 
+	ctx := context.Background()
 	handler := &EventsHandler{}
-	handler.Init(db)
+	handler.Init(db, HandlerOptions{})
 
-	eventType, err := handler.dbStuff.findEventTypeByValue("start")
+	eventType, err := handler.dbStuff.findEventTypeByValue(ctx, "start")
 	util.Test.HandleIfTestError(t, err, fn)
 
 	now := time.Now()
@@ -48,23 +55,23 @@ func TestGetEventDao(t *testing.T) {
 		},
 	}
 
-	eventDbID, err := handler.dbStuff.insertEvent(event)
+	eventDbID, err := handler.dbStuff.insertEvent(ctx, event)
 	util.Test.HandleIfTestError(t, err, fn)
 
 	tag1 := &EventTag{Value: "tag1"}
-	tag1ID, err := handler.dbStuff.insertEventTag(tag1)
+	tag1ID, err := handler.dbStuff.insertEventTag(ctx, tag1)
 	util.Test.HandleIfTestError(t, err, fn)
 
 	tag2 := &EventTag{Value: "tag2"}
-	tag2ID, err := handler.dbStuff.insertEventTag(tag2)
+	tag2ID, err := handler.dbStuff.insertEventTag(ctx, tag2)
 	util.Test.HandleIfTestError(t, err, fn)
 
 	tag1Map := &EventTagMap{EventID: eventDbID, TagID: tag1ID}
-	_, err = handler.dbStuff.insertEventTagMapping(tag1Map)
+	_, err = handler.dbStuff.insertEventTagMapping(ctx, tag1Map)
 	util.Test.HandleIfTestError(t, err, fn)
 
 	tag2Map := &EventTagMap{EventID: eventDbID, TagID: tag2ID}
-	_, err = handler.dbStuff.insertEventTagMapping(tag2Map)
+	_, err = handler.dbStuff.insertEventTagMapping(ctx, tag2Map)
 	util.Test.HandleIfTestError(t, err, fn)
 
 	expectedEvent := &Event{
@@ -89,7 +96,7 @@ func TestGetEventDao(t *testing.T) {
 		},
 	}
 
-	actualEvent, err := handler.GetEvent("TestEvent")
+	actualEvent, err := handler.GetEvent(ctx, "TestEvent")
 	util.Test.HandleIfTestError(t, err, fn)
 	if actualEvent == nil || actualEvent.Type == nil || actualEvent.Tags == nil || len(actualEvent.Tags) != len(expectedEvent.Tags) {
 		util.Test.HandleIfTestError(t, errors.New("Got invalid event data"), fn)
@@ -117,8 +124,9 @@ func TestCreateEventDao(t *testing.T) {
 
 	defer util.Db.ClearTables(db, eventTagMapTableName, eventsTableName, eventTagsTableName)
 
+	ctx := context.Background()
 	handler := &EventsHandler{}
-	handler.Init(db)
+	handler.Init(db, HandlerOptions{})
 
 	now := time.Now()
 	event := &Event{
@@ -134,10 +142,10 @@ func TestCreateEventDao(t *testing.T) {
 		},
 	}
 
-	eventID, err := handler.CreateEvent(event)
+	eventID, err := handler.CreateEvent(ctx, event)
 	util.Test.HandleIfTestError(t, err, fn)
 
-	eventType, err1 := handler.dbStuff.findEventTypeByValue("start")
+	eventType, err1 := handler.dbStuff.findEventTypeByValue(ctx, "start")
 	util.Test.HandleIfTestError(t, err1, fn)
 	if eventType == nil {
 		util.Test.HandleIfTestError(t, errors.New("Event Type not found"), fn)
@@ -170,11 +178,11 @@ func TestCreateEventDao(t *testing.T) {
 		util.Test.HandleIfTestError(t, errors.New("Invalid Tags Data"), fn)
 	}
 
-	dbEvent, err := handler.dbStuff.findEventByID(eventID)
+	dbEvent, err := handler.dbStuff.findEventByID(ctx, eventID)
 	util.Test.HandleIfTestError(t, err, fn)
-	tag1, err := handler.dbStuff.findEventTagByValue("tag1")
+	tag1, err := handler.dbStuff.findEventTagByValue(ctx, "tag1")
 	util.Test.HandleIfTestError(t, err, fn)
-	tag2, err := handler.dbStuff.findEventTagByValue("tag2")
+	tag2, err := handler.dbStuff.findEventTagByValue(ctx, "tag2")
 	util.Test.HandleIfTestError(t, err, fn)
 
 	queryTagMaps := fmt.Sprintf(
@@ -195,3 +203,138 @@ func TestCreateEventDao(t *testing.T) {
 		util.Test.HandleIfTestError(t, errors.New("Invalid Tag Map Data"), fn)
 	}
 }
+
+func TestRetentionDao(t *testing.T) {
+	fn := "TestRetentionDao"
+	db := InitDb()
+
+	tables := []string{eventRevisionsTableName, eventLinksTableName, eventTagMapTableName, eventsArchiveTableName, eventsTableName, eventTagsTableName}
+	err := util.Db.ClearTables(db, tables...)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	defer util.Db.ClearTables(db, tables...)
+
+	ctx := context.Background()
+	handler := &EventsHandler{}
+	handler.Init(db, HandlerOptions{})
+
+	now := time.Now()
+	create := func(at time.Time, tag string) string {
+		eventID, err := handler.CreateEvent(ctx, &Event{
+			Title:         "Some Test Event",
+			UserCreatedAt: at,
+			Type:          &EventType{Value: "start"},
+			Tags:          []*EventTag{{Value: tag}},
+		})
+		util.Test.HandleIfTestError(t, err, fn)
+		return eventID
+	}
+	oldID := create(now.AddDate(-3, 0, 0), "work")
+	deletedID := create(now, "scratch")
+	keptID := create(now, "work")
+
+	_, err = handler.CreateEventLink(ctx, deletedID, linkTypeRelates, keptID)
+	util.Test.HandleIfTestError(t, err, fn)
+	_, err = handler.PushChanges(ctx, []*EventChange{{Event: &Event{ID: deletedID}, Deleted: true, ChangedAt: now.Add(time.Minute)}})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	// deleted long enough ago to be purged, with a tag old enough to go once nothing is tagged with it
+	_, err = db.Exec(fmt.Sprintf("UPDATE %s SET %s = %s - INTERVAL 40 DAY WHERE %s = ?", eventsTableName, colUpdatedAt, colUpdatedAt, eventsColID), deletedID)
+	util.Test.HandleIfTestError(t, err, fn)
+	_, err = db.Exec(fmt.Sprintf("UPDATE %s SET %s = %s - INTERVAL 2 DAY", eventTagsTableName, colCreatedAt, colCreatedAt))
+	util.Test.HandleIfTestError(t, err, fn)
+
+	changeSeq := func(eventID string) int64 {
+		var seq int64
+		err := db.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", eventsColChangeSeq, eventsTableName, eventsColID), eventID).Scan(&seq)
+		util.Test.HandleIfTestError(t, err, fn)
+		return seq
+	}
+	count := func(query string, args ...interface{}) int64 {
+		count, err := handler.dbStuff.count(ctx, query, args...)
+		util.Test.HandleIfTestError(t, err, fn)
+		return count
+	}
+	keptSeq := changeSeq(keptID)
+
+	policy := RetentionPolicy{DeletedEventsAfterDays: 30, ArchiveAfterYears: 2, PurgeOrphanTags: true, BatchSize: 1}
+	report, err := handler.ApplyRetention(ctx, policy, true)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, &RetentionReport{DryRun: true, PurgedEvents: 1, ArchivedEvents: 1}, report, fn+": Wrong dry run report")
+	util.Test.AssertEquals(t, int64(3), count(fmt.Sprintf("SELECT COUNT(*) FROM %s", eventsTableName)), fn+": Events removed on a dry run")
+
+	report, err = handler.ApplyRetention(ctx, policy, false)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, &RetentionReport{PurgedEvents: 1, PurgedTags: 1, ArchivedEvents: 1}, report, fn+": Wrong report")
+
+	util.Test.AssertEquals(t, int64(1), count(fmt.Sprintf("SELECT COUNT(*) FROM %s", eventsTableName)), fn+": Wrong events left")
+	util.Test.AssertEquals(t, int64(1), count(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", eventsArchiveTableName, eventsArchiveColEventID), oldID), fn+": Event not archived")
+	util.Test.AssertEquals(t, int64(0), count(fmt.Sprintf("SELECT COUNT(*) FROM %s", eventLinksTableName)), fn+": Links of the purged event left")
+	util.Test.AssertEquals(t, int64(0), count(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", eventTagsTableName, eventTagsColValue), "scratch"), fn+": Orphan tag left")
+	util.Test.AssertEquals(t, int64(1), count(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", eventTagsTableName, eventTagsColValue), "work"), fn+": Tag in use purged")
+	util.Test.AssertEquals(t, true, changeSeq(keptID) > keptSeq, fn+": Event linked to the purged one not bumped")
+
+	report, err = handler.ApplyRetention(ctx, policy, false)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, &RetentionReport{}, report, fn+": Removed again")
+}
+
+func TestSyncPushDao(t *testing.T) {
+	fn := "TestSyncPushDao"
+	db := InitDb()
+
+	tables := []string{eventRevisionsTableName, eventTagMapTableName, eventsTableName, eventTagsTableName}
+	err := util.Db.ClearTables(db, tables...)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	defer util.Db.ClearTables(db, tables...)
+
+	ctx := context.Background()
+	handler := &EventsHandler{}
+	handler.Init(db, HandlerOptions{})
+
+	// as precise as the db keeps it
+	changedAt := time.Now().UTC().Truncate(time.Microsecond)
+	push := func(title string, deleted bool, at time.Time) *PushResult {
+		event := &Event{ID: "SyncEvent", Title: title, UserCreatedAt: changedAt, Type: &EventType{Value: "start"}}
+		result, err := handler.PushChanges(ctx, []*EventChange{{Event: event, Deleted: deleted, ChangedAt: at}})
+		util.Test.HandleIfTestError(t, err, fn)
+		return result
+	}
+	storedChangedAt := func() time.Time {
+		_, at, err := handler.dbStuff.lockEventForSync(ctx, "SyncEvent")
+		util.Test.HandleIfTestError(t, err, fn)
+		return at
+	}
+
+	result := push("draft", false, changedAt)
+	util.Test.AssertEquals(t, []string{"SyncEvent"}, result.Applied, fn+": New event not applied")
+	util.Test.AssertEquals(t, true, storedChangedAt().Equal(changedAt), fn+": Wrong changed at")
+
+	// an older change loses to the server's, however late the server got it
+	result = push("stale", false, changedAt.Add(-time.Hour))
+	util.Test.AssertEquals(t, 1, len(result.Conflicts), fn+": Older change applied")
+	util.Test.AssertEquals(t, conflictReasonServerNewer, result.Conflicts[0].Reason, fn+": Wrong conflict reason")
+	util.Test.AssertEquals(t, "draft", result.Conflicts[0].Server.Title, fn+": Wrong server event")
+
+	result = push("final", false, changedAt.Add(time.Hour))
+	util.Test.AssertEquals(t, []string{"SyncEvent"}, result.Applied, fn+": Newer change not applied")
+	event, err := handler.GetEvent(ctx, "SyncEvent")
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, "final", event.Title, fn+": Event not updated")
+	util.Test.AssertEquals(t, true, storedChangedAt().Equal(changedAt.Add(time.Hour)), fn+": Changed at not moved on")
+
+	result = push("", true, changedAt.Add(30*time.Minute))
+	util.Test.AssertEquals(t, 1, len(result.Conflicts), fn+": Older delete applied")
+
+	result = push("", true, changedAt.Add(2*time.Hour))
+	util.Test.AssertEquals(t, []string{"SyncEvent"}, result.Applied, fn+": Delete not applied")
+	event, err = handler.GetEvent(ctx, "SyncEvent")
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, true, event == nil, fn+": Deleted event found")
+
+	changes, err := handler.GetChanges(ctx, SyncToken{}, 10)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 1, len(changes.Deleted), fn+": No tombstone")
+	util.Test.AssertEquals(t, "SyncEvent", changes.Deleted[0].ID, fn+": Wrong tombstone")
+}
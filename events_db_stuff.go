@@ -1,32 +1,268 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jforcode/Go-DeepError"
-	"github.com/jforcode/Go-Util"
 )
 
-// TODO: implement cache
 // TODO: reduce code by commoning out
 
+// dbConn is what both *sql.DB and *sql.Tx provide, so that dbStuff can work in or out of a transaction
+type dbConn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
 type dbStuff struct {
-	db *sql.DB
+	db           *sql.DB
+	conn         dbConn
+	queryTimeout time.Duration
+	retryPolicy  RetryPolicy
+	cache        *lookupCache
+	tx           *txState
+}
+
+// txState tracks what happened in a transaction, set on the dbStuff working inside it
+type txState struct {
+	lockFailed    bool
+	afterCommit   []func()
+	changedEvents map[int64]bool
+}
+
+func (state *txState) noteError(err error) {
+	if isLockError(err) {
+		state.lockFailed = true
+	}
+}
+
+func newDbStuff(db *sql.DB, options HandlerOptions) *dbStuff {
+	var cache *lookupCache
+	if options.CacheSize > 0 {
+		cache = newLookupCache(options.CacheSize, options.CacheTTL)
+	}
+
+	return &dbStuff{
+		db:           db,
+		conn:         db,
+		queryTimeout: options.QueryTimeout,
+		retryPolicy:  options.Retry,
+		cache:        cache,
+	}
+}
+
+// afterCommit runs f once the current transaction commits, or right away if not in one
+func (dbStuff *dbStuff) afterCommit(f func()) {
+	if dbStuff.tx == nil {
+		f()
+		return
+	}
+
+	dbStuff.tx.afterCommit = append(dbStuff.tx.afterCommit, f)
 }
 
-func (dbStuff *dbStuff) findEventByID(eventID string) (*Event, error) {
+// eventsChanged notes events written in the current transaction, to be put in the change sequence when it commits.
+// Out of a transaction, the write is already committed, and the events are put in the sequence right away.
+func eventsChanged(ctx context.Context, stuff *dbStuff, eventDbIDs ...int64) error {
+	if stuff.tx == nil {
+		return stuff.withTx(ctx, func(txStuff *dbStuff) error {
+			return eventsChanged(ctx, txStuff, eventDbIDs...)
+		})
+	}
+
+	if stuff.tx.changedEvents == nil {
+		stuff.tx.changedEvents = make(map[int64]bool)
+	}
+	for _, eventDbID := range eventDbIDs {
+		stuff.tx.changedEvents[eventDbID] = true
+	}
+
+	return nil
+}
+
+// sequenceChangedEvents gives the events changed in the transaction the next number in the change sequence, as the
+// last thing before commit. The counter row stays locked till then, so numbers are handed out in the order
+// transactions commit, and a sync never gives out a token ahead of a change still to be committed.
+func (dbStuff *dbStuff) sequenceChangedEvents(ctx context.Context) error {
+	fn := "sequenceChangedEvents"
+
+	if len(dbStuff.tx.changedEvents) == 0 {
+		return nil
+	}
+
+	res, err := dbStuff.exec(ctx, fmt.Sprintf("UPDATE %s SET %s = LAST_INSERT_ID(%s + 1)",
+		eventChangeSeqTableName, eventChangeSeqColSeq, eventChangeSeqColSeq))
+	if err != nil {
+		return deepError.New(fn, "next seq", err)
+	}
+
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return deepError.New(fn, "last insert id", err)
+	}
+
+	args := []interface{}{seq}
+	for eventDbID := range dbStuff.tx.changedEvents {
+		args = append(args, eventDbID)
+	}
+
+	// _updated_at is kept as it is, it tells when the change was made
+	_, err = dbStuff.exec(ctx, fmt.Sprintf("UPDATE %s SET %s = ?, %s = %s WHERE %s IN (%s)",
+		eventsTableName, eventsColChangeSeq, colUpdatedAt, colUpdatedAt, colDbID, inPlaceholders(len(args)-1)), args...)
+	if err != nil {
+		return deepError.New(fn, "set change seq", err)
+	}
+
+	return nil
+}
+
+// cacheEventType caches a type read from the db. Rows read inside a transaction may not be committed, so are left out.
+func (dbStuff *dbStuff) cacheEventType(eventType *EventType) {
+	if dbStuff.tx == nil {
+		dbStuff.cache.addEventType(eventType)
+	}
+}
+
+func (dbStuff *dbStuff) cacheEventTag(eventTag *EventTag) {
+	if dbStuff.tx == nil {
+		dbStuff.cache.addEventTag(eventTag)
+	}
+}
+
+// invalidateEventTag drops a renamed, merged or deleted tag from the cache. Inside a transaction it is
+// dropped again after commit, so that a concurrent read can't put the old row back in meanwhile.
+func (dbStuff *dbStuff) invalidateEventTag(eventTag *EventTag) {
+	dbStuff.cache.invalidateEventTag(eventTag)
+	dbStuff.afterCommit(func() {
+		dbStuff.cache.invalidateEventTag(eventTag)
+	})
+}
+
+// withTx runs op in a transaction, committing if op succeeds. If mysql aborts the transaction for a
+// deadlock or lock wait timeout, the whole of it is run again as per the retry policy.
+// If already in a transaction, op just runs as part of it.
+func (dbStuff *dbStuff) withTx(ctx context.Context, op func(txStuff *dbStuff) error) error {
+	fn := "withTx"
+
+	if dbStuff.tx != nil {
+		return op(dbStuff)
+	}
+
+	retryable := false
+	return dbStuff.retryPolicy.do(ctx, func(ctx context.Context) error {
+		retryable = false
+
+		tx, err := dbStuff.db.BeginTx(ctx, nil)
+		if err != nil {
+			retryable = isTransientError(err)
+			return deepError.New(fn, "begin", err)
+		}
+
+		txStuff := *dbStuff
+		txStuff.conn = tx
+		txStuff.tx = &txState{}
+
+		err = op(&txStuff)
+		if err == nil {
+			err = txStuff.sequenceChangedEvents(ctx)
+		}
+		if err != nil {
+			tx.Rollback()
+			retryable = txStuff.tx.lockFailed
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			retryable = isLockError(err)
+			return deepError.New(fn, "commit", err)
+		}
+
+		for _, f := range txStuff.tx.afterCommit {
+			f()
+		}
+
+		return nil
+	}, func(error) bool {
+		return retryable
+	})
+}
+
+// withTimeout bounds a single query by the configured query timeout, on top of whatever deadline ctx has.
+func (dbStuff *dbStuff) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dbStuff.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, dbStuff.queryTimeout)
+}
+
+func (dbStuff *dbStuff) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	fn := "exec"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	stmt, err := dbStuff.conn.PrepareContext(ctx, query)
+	if err != nil {
+		dbStuff.noteError(err)
+		return nil, deepError.New(fn, "prepare", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		dbStuff.noteError(err)
+		return nil, deepError.New(fn, "exec", err)
+	}
+
+	return res, nil
+}
+
+// query runs a read. Outside a transaction reads are idempotent, so transient failures are retried.
+func (dbStuff *dbStuff) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if dbStuff.tx != nil {
+		rows, err := dbStuff.conn.QueryContext(ctx, query, args...)
+		dbStuff.noteError(err)
+		return rows, err
+	}
+
+	var rows *sql.Rows
+	err := dbStuff.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		rows, err = dbStuff.conn.QueryContext(ctx, query, args...)
+		return err
+	})
+
+	return rows, err
+}
+
+func (dbStuff *dbStuff) noteError(err error) {
+	if dbStuff.tx != nil && err != nil {
+		dbStuff.tx.noteError(err)
+	}
+}
+
+func (dbStuff *dbStuff) findEventByID(ctx context.Context, eventID string) (*Event, error) {
 	fn := "findEventById"
 
 	query := fmt.Sprintf(`
-		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
 		FROM %s E
 		WHERE E.%s = ?`,
 		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
 		eventsTableName,
 		eventsColID)
 
-	rows, err := dbStuff.db.Query(query, eventID)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, eventID)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -34,16 +270,21 @@ func (dbStuff *dbStuff) findEventByID(eventID string) (*Event, error) {
 
 	if rows.Next() {
 		event := &Event{Type: &EventType{}}
-		rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status)
+		rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+			&event.TimeZone, &event.UTCOffset, &event.Fields)
 		return event, nil
 	}
 
 	return nil, nil
 }
 
-func (dbStuff *dbStuff) findEventTypeByValue(value string) (*EventType, error) {
+func (dbStuff *dbStuff) findEventTypeByValue(ctx context.Context, value string) (*EventType, error) {
 	fn := "findEventTypeByValue"
 
+	if eventType := dbStuff.cache.getEventType(cacheKeyValue(value)); eventType != nil {
+		return eventType, nil
+	}
+
 	query := fmt.Sprintf(`
 		SELECT ETP.%s, ETP.%s, ETP.%s, ETP.%s, ETP.%s
 		FROM %s ETP
@@ -52,7 +293,10 @@ func (dbStuff *dbStuff) findEventTypeByValue(value string) (*EventType, error) {
 		eventTypesTableName,
 		eventTypesColValue)
 
-	rows, err := dbStuff.db.Query(query, value)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, value)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -61,6 +305,7 @@ func (dbStuff *dbStuff) findEventTypeByValue(value string) (*EventType, error) {
 	if rows.Next() {
 		eventType := &EventType{}
 		rows.Scan(&eventType.DbID, &eventType.Value, &eventType.CreatedAt, &eventType.UpdatedAt, &eventType.Status)
+		dbStuff.cacheEventType(eventType)
 
 		return eventType, nil
 	}
@@ -68,9 +313,13 @@ func (dbStuff *dbStuff) findEventTypeByValue(value string) (*EventType, error) {
 	return nil, nil
 }
 
-func (dbStuff *dbStuff) findEventTypeByID(id int64) (*EventType, error) {
+func (dbStuff *dbStuff) findEventTypeByID(ctx context.Context, id int64) (*EventType, error) {
 	fn := "findEventTypeByID"
 
+	if eventType := dbStuff.cache.getEventType(cacheKeyID(id)); eventType != nil {
+		return eventType, nil
+	}
+
 	query := fmt.Sprintf(`
 		SELECT ETP.%s, ETP.%s, ETP.%s, ETP.%s, ETP.%s
 		FROM %s ETP
@@ -79,7 +328,10 @@ func (dbStuff *dbStuff) findEventTypeByID(id int64) (*EventType, error) {
 		eventTypesTableName,
 		colDbID)
 
-	rows, err := dbStuff.db.Query(query, id)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, id)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -88,6 +340,7 @@ func (dbStuff *dbStuff) findEventTypeByID(id int64) (*EventType, error) {
 	if rows.Next() {
 		eventType := &EventType{}
 		rows.Scan(&eventType.DbID, &eventType.Value, &eventType.CreatedAt, &eventType.UpdatedAt, &eventType.Status)
+		dbStuff.cacheEventType(eventType)
 
 		return eventType, nil
 	}
@@ -95,9 +348,13 @@ func (dbStuff *dbStuff) findEventTypeByID(id int64) (*EventType, error) {
 	return nil, nil
 }
 
-func (dbStuff *dbStuff) findEventTagByValue(value string) (*EventTag, error) {
+func (dbStuff *dbStuff) findEventTagByValue(ctx context.Context, value string) (*EventTag, error) {
 	fn := "findEventTagByValue"
 
+	if eventTag := dbStuff.cache.getEventTag(cacheKeyValue(value)); eventTag != nil {
+		return eventTag, nil
+	}
+
 	query := fmt.Sprintf(`
 		SELECT ETG.%s, ETG.%s, ETG.%s, ETG.%s, ETG.%s
 		FROM %s ETG
@@ -106,7 +363,10 @@ func (dbStuff *dbStuff) findEventTagByValue(value string) (*EventTag, error) {
 		eventTagsTableName,
 		eventTagsColValue)
 
-	rows, err := dbStuff.db.Query(query, value)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, value)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -115,6 +375,7 @@ func (dbStuff *dbStuff) findEventTagByValue(value string) (*EventTag, error) {
 	if rows.Next() {
 		eventTag := &EventTag{}
 		rows.Scan(&eventTag.DbID, &eventTag.Value, &eventTag.CreatedAt, &eventTag.UpdatedAt, &eventTag.Status)
+		dbStuff.cacheEventTag(eventTag)
 
 		return eventTag, nil
 	}
@@ -122,9 +383,13 @@ func (dbStuff *dbStuff) findEventTagByValue(value string) (*EventTag, error) {
 	return nil, nil
 }
 
-func (dbStuff *dbStuff) findEventTagByID(id int64) (*EventTag, error) {
+func (dbStuff *dbStuff) findEventTagByID(ctx context.Context, id int64) (*EventTag, error) {
 	fn := "findEventTagByID"
 
+	if eventTag := dbStuff.cache.getEventTag(cacheKeyID(id)); eventTag != nil {
+		return eventTag, nil
+	}
+
 	query := fmt.Sprintf(`
 		SELECT ETG.%s, ETG.%s, ETG.%s, ETG.%s, ETG.%s
 		FROM %s ETG
@@ -133,7 +398,10 @@ func (dbStuff *dbStuff) findEventTagByID(id int64) (*EventTag, error) {
 		eventTagsTableName,
 		colDbID)
 
-	rows, err := dbStuff.db.Query(query, id)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, id)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -142,6 +410,7 @@ func (dbStuff *dbStuff) findEventTagByID(id int64) (*EventTag, error) {
 	if rows.Next() {
 		eventTag := &EventTag{}
 		rows.Scan(&eventTag.DbID, &eventTag.Value, &eventTag.CreatedAt, &eventTag.UpdatedAt, &eventTag.Status)
+		dbStuff.cacheEventTag(eventTag)
 
 		return eventTag, nil
 	}
@@ -149,7 +418,7 @@ func (dbStuff *dbStuff) findEventTagByID(id int64) (*EventTag, error) {
 	return nil, nil
 }
 
-func (dbStuff *dbStuff) findEventTagsByEventID(eventID string) ([]*EventTag, error) {
+func (dbStuff *dbStuff) findEventTagsByEventID(ctx context.Context, eventID string) ([]*EventTag, error) {
 	fn := "findEventTagsByEventID"
 
 	query := fmt.Sprintf(`
@@ -162,7 +431,10 @@ func (dbStuff *dbStuff) findEventTagsByEventID(eventID string) ([]*EventTag, err
 		eventTagMapTableName, eventTagMapColTagID, colDbID,
 		eventsTableName, colDbID, eventTagMapColEventID, eventsColID)
 
-	rows, err := dbStuff.db.Query(query, eventID)
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, eventID)
 	if err != nil {
 		return nil, deepError.New(fn, "query", err)
 	}
@@ -178,29 +450,124 @@ func (dbStuff *dbStuff) findEventTagsByEventID(eventID string) ([]*EventTag, err
 	return eventTags, nil
 }
 
-func (dbStuff *dbStuff) insertEvent(event *Event) (int64, error) {
+// inPlaceholders gives the ? placeholders for an IN clause of n values
+func inPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// findEventTypesByIDs finds many types in one query, leaving out those cached. Keyed by db id.
+func (dbStuff *dbStuff) findEventTypesByIDs(ctx context.Context, ids []int64) (map[int64]*EventType, error) {
+	fn := "findEventTypesByIDs"
+
+	eventTypes := make(map[int64]*EventType)
+	args := make([]interface{}, 0)
+	for _, id := range ids {
+		if _, ok := eventTypes[id]; ok {
+			continue
+		}
+
+		if eventType := dbStuff.cache.getEventType(cacheKeyID(id)); eventType != nil {
+			eventTypes[id] = eventType
+			continue
+		}
+		eventTypes[id] = nil
+		args = append(args, id)
+	}
+
+	if len(args) == 0 {
+		return eventTypes, nil
+	}
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, fmt.Sprintf(queryGetEventType, inPlaceholders(len(args))), args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		eventType := &EventType{}
+		err = rows.Scan(&eventType.DbID, &eventType.Value, &eventType.CreatedAt, &eventType.UpdatedAt, &eventType.Status)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		dbStuff.cacheEventType(eventType)
+
+		eventTypes[eventType.DbID] = eventType
+	}
+
+	return eventTypes, nil
+}
+
+// findEventTagsByEventDbIDs finds the tags of many events in one query. Keyed by the db id of the event.
+func (dbStuff *dbStuff) findEventTagsByEventDbIDs(ctx context.Context, eventDbIDs []int64) (map[int64][]*EventTag, error) {
+	fn := "findEventTagsByEventDbIDs"
+
+	eventTags := make(map[int64][]*EventTag)
+	if len(eventDbIDs) == 0 {
+		return eventTags, nil
+	}
+
+	args := make([]interface{}, 0)
+	for _, id := range eventDbIDs {
+		args = append(args, id)
+	}
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, fmt.Sprintf(queryGetEventTags, inPlaceholders(len(args))), args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		eventTag := &EventTag{}
+		var eventDbID int64
+		err = rows.Scan(&eventTag.DbID, &eventTag.Value, &eventTag.CreatedAt, &eventTag.UpdatedAt, &eventTag.Status, &eventDbID)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+
+		eventTags[eventDbID] = append(eventTags[eventDbID], eventTag)
+	}
+
+	return eventTags, nil
+}
+
+func (dbStuff *dbStuff) insertEvent(ctx context.Context, event *Event) (int64, error) {
 	fn := "insertEvent"
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (%s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?)",
-		eventsTableName, eventsColID, eventsColTitle, eventsColNote, eventsColTypeID, eventsColCreatedAt)
+		"INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		eventsTableName, eventsColID, eventsColTitle, eventsColNote, eventsColTypeID, eventsColCreatedAt, eventsColTimeZone, eventsColUTCOffset,
+		eventsColFields)
 
-	res, err := util.Db.PrepareAndExec(dbStuff.db, query, event.ID, event.Title, event.Note, event.Type.DbID, event.UserCreatedAt)
+	res, err := dbStuff.exec(ctx, query, event.ID, event.Title, event.Note, event.Type.DbID, event.UserCreatedAt, event.TimeZone, event.UTCOffset,
+		event.Fields)
 	if err != nil {
 		return -1, deepError.New(fn, "prepare and exec", err)
 	}
 
-	return getDbID(res)
+	eventDbID, err := getDbID(res)
+	if err != nil {
+		return -1, deepError.New(fn, "get db id", err)
+	}
+
+	return eventDbID, eventsChanged(ctx, dbStuff, eventDbID)
 }
 
-func (dbStuff *dbStuff) insertEventType(eventType *EventType) (int64, error) {
+func (dbStuff *dbStuff) insertEventType(ctx context.Context, eventType *EventType) (int64, error) {
 	fn := "insertEventType"
 
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s) VALUES (?)",
 		eventTypesTableName, eventTypesColValue)
 
-	res, err := util.Db.PrepareAndExec(dbStuff.db, query, eventType.Value)
+	res, err := dbStuff.exec(ctx, query, eventType.Value)
 	if err != nil {
 		return -1, deepError.New(fn, "prepare and exec", err)
 	}
@@ -209,14 +576,14 @@ func (dbStuff *dbStuff) insertEventType(eventType *EventType) (int64, error) {
 
 }
 
-func (dbStuff *dbStuff) insertEventTag(eventTag *EventTag) (int64, error) {
+func (dbStuff *dbStuff) insertEventTag(ctx context.Context, eventTag *EventTag) (int64, error) {
 	fn := "insertEventTag"
 
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s) VALUES (?)",
 		eventTagsTableName, eventTagsColValue)
 
-	res, err := util.Db.PrepareAndExec(dbStuff.db, query, eventTag.Value)
+	res, err := dbStuff.exec(ctx, query, eventTag.Value)
 	if err != nil {
 		return -1, deepError.New(fn, "prepare and exec", err)
 	}
@@ -225,14 +592,14 @@ func (dbStuff *dbStuff) insertEventTag(eventTag *EventTag) (int64, error) {
 
 }
 
-func (dbStuff *dbStuff) insertEventTagMapping(eventTagMap *EventTagMap) (int64, error) {
+func (dbStuff *dbStuff) insertEventTagMapping(ctx context.Context, eventTagMap *EventTagMap) (int64, error) {
 	fn := "insertEventTagMapping"
 
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s, %s) VALUES (?, ?)",
 		eventTagMapTableName, eventTagMapColEventID, eventTagMapColTagID)
 
-	res, err := util.Db.PrepareAndExec(dbStuff.db, query, eventTagMap.EventID, eventTagMap.TagID)
+	res, err := dbStuff.exec(ctx, query, eventTagMap.EventID, eventTagMap.TagID)
 	if err != nil {
 		return -1, deepError.New(fn, "prepare and exec", err)
 	}
@@ -240,6 +607,80 @@ func (dbStuff *dbStuff) insertEventTagMapping(eventTagMap *EventTagMap) (int64,
 	return getDbID(res)
 }
 
+func (dbStuff *dbStuff) updateEvent(ctx context.Context, event *Event) error {
+	fn := "updateEvent"
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = ?, %s = ?, %s = ?, %s = ?, %s = ?, %s = CURRENT_TIMESTAMP(6), %s = CURRENT_TIMESTAMP(6) WHERE %s = ?",
+		eventsTableName, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTimeZone, eventsColUTCOffset, eventsColFields, eventsColTypeID,
+		colStatus, colUpdatedAt, eventsColChangedAt, colDbID)
+
+	_, err := dbStuff.exec(ctx, query, event.Title, event.Note, event.UserCreatedAt, event.TimeZone, event.UTCOffset, event.Fields,
+		event.Type.DbID, event.Status, event.DbID)
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return eventsChanged(ctx, dbStuff, event.DbID)
+}
+
+// touchEvents bumps the _updated_at of events whose content didn't change, but what is sent for them did.
+// Etags, Last-Modified and sync tokens then all move past the change.
+func (dbStuff *dbStuff) touchEvents(ctx context.Context, eventDbIDs ...int64) error {
+	fn := "touchEvents"
+
+	if len(eventDbIDs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0)
+	for _, eventDbID := range eventDbIDs {
+		args = append(args, eventDbID)
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = CURRENT_TIMESTAMP(6) WHERE %s IN (%s)",
+		eventsTableName, colUpdatedAt, colDbID, inPlaceholders(len(eventDbIDs)))
+
+	_, err := dbStuff.exec(ctx, query, args...)
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return eventsChanged(ctx, dbStuff, eventDbIDs...)
+}
+
+// markEventDeleted soft deletes an event, it stays around as a tombstone for syncing clients
+func (dbStuff *dbStuff) markEventDeleted(ctx context.Context, eventDbID int64) error {
+	fn := "markEventDeleted"
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = CURRENT_TIMESTAMP(6), %s = CURRENT_TIMESTAMP(6) WHERE %s = ?",
+		eventsTableName, colStatus, colUpdatedAt, eventsColChangedAt, colDbID)
+
+	_, err := dbStuff.exec(ctx, query, statusDeleted, eventDbID)
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return eventsChanged(ctx, dbStuff, eventDbID)
+}
+
+func (dbStuff *dbStuff) deleteEventTagMappings(ctx context.Context, eventDbID int64) error {
+	fn := "deleteEventTagMappings"
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = ?",
+		eventTagMapTableName, eventTagMapColEventID)
+
+	_, err := dbStuff.exec(ctx, query, eventDbID)
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return nil
+}
+
 func getDbID(res sql.Result) (int64, error) {
 	fn := "getDbID"
 
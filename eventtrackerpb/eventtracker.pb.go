@@ -0,0 +1,1757 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: eventtracker.proto
+
+package eventtrackerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EventType struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventType) Reset() {
+	*x = EventType{}
+	mi := &file_eventtracker_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventType) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventType) ProtoMessage() {}
+
+func (x *EventType) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventType.ProtoReflect.Descriptor instead.
+func (*EventType) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EventType) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type EventTag struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventTag) Reset() {
+	*x = EventTag{}
+	mi := &file_eventtracker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventTag) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventTag) ProtoMessage() {}
+
+func (x *EventTag) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventTag.ProtoReflect.Descriptor instead.
+func (*EventTag) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EventTag) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Note  string                 `protobuf:"bytes,3,opt,name=note,proto3" json:"note,omitempty"`
+	// the time of the event as given by the user
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Type          *EventType             `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	Tags          []*EventTag            `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_eventtracker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Event) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *Event) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Event) GetType() *EventType {
+	if x != nil {
+		return x.Type
+	}
+	return nil
+}
+
+func (x *Event) GetTags() []*EventTag {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type ListEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEventsRequest) Reset() {
+	*x = ListEventsRequest{}
+	mi := &file_eventtracker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEventsRequest) ProtoMessage() {}
+
+func (x *ListEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEventsRequest.ProtoReflect.Descriptor instead.
+func (*ListEventsRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{3}
+}
+
+type GetEventRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEventRequest) Reset() {
+	*x = GetEventRequest{}
+	mi := &file_eventtracker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEventRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEventRequest) ProtoMessage() {}
+
+func (x *GetEventRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEventRequest.ProtoReflect.Descriptor instead.
+func (*GetEventRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetEventRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreateEventRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *Event                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEventRequest) Reset() {
+	*x = CreateEventRequest{}
+	mi := &file_eventtracker_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEventRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEventRequest) ProtoMessage() {}
+
+func (x *CreateEventRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEventRequest.ProtoReflect.Descriptor instead.
+func (*CreateEventRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateEventRequest) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+type CreateEventResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEventResponse) Reset() {
+	*x = CreateEventResponse{}
+	mi := &file_eventtracker_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEventResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEventResponse) ProtoMessage() {}
+
+func (x *CreateEventResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEventResponse.ProtoReflect.Descriptor instead.
+func (*CreateEventResponse) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateEventResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type AuditChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Before        *structpb.Value        `protobuf:"bytes,1,opt,name=before,proto3" json:"before,omitempty"`
+	After         *structpb.Value        `protobuf:"bytes,2,opt,name=after,proto3" json:"after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditChange) Reset() {
+	*x = AuditChange{}
+	mi := &file_eventtracker_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditChange) ProtoMessage() {}
+
+func (x *AuditChange) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditChange.ProtoReflect.Descriptor instead.
+func (*AuditChange) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AuditChange) GetBefore() *structpb.Value {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *AuditChange) GetAfter() *structpb.Value {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+type AuditEntry struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	EntityType    string                  `protobuf:"bytes,1,opt,name=entity_type,json=entityType,proto3" json:"entity_type,omitempty"`
+	EntityId      string                  `protobuf:"bytes,2,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	Action        string                  `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Actor         string                  `protobuf:"bytes,4,opt,name=actor,proto3" json:"actor,omitempty"`
+	RequestId     string                  `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Changes       map[string]*AuditChange `protobuf:"bytes,6,rep,name=changes,proto3" json:"changes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	At            *timestamppb.Timestamp  `protobuf:"bytes,7,opt,name=at,proto3" json:"at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditEntry) Reset() {
+	*x = AuditEntry{}
+	mi := &file_eventtracker_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEntry) ProtoMessage() {}
+
+func (x *AuditEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEntry.ProtoReflect.Descriptor instead.
+func (*AuditEntry) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AuditEntry) GetEntityType() string {
+	if x != nil {
+		return x.EntityType
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetChanges() map[string]*AuditChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *AuditEntry) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+type GetEventHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEventHistoryRequest) Reset() {
+	*x = GetEventHistoryRequest{}
+	mi := &file_eventtracker_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEventHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEventHistoryRequest) ProtoMessage() {}
+
+func (x *GetEventHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEventHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetEventHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetEventHistoryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetEventHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	History       []*AuditEntry          `protobuf:"bytes,1,rep,name=history,proto3" json:"history,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEventHistoryResponse) Reset() {
+	*x = GetEventHistoryResponse{}
+	mi := &file_eventtracker_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEventHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEventHistoryResponse) ProtoMessage() {}
+
+func (x *GetEventHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEventHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetEventHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetEventHistoryResponse) GetHistory() []*AuditEntry {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+// EventSnapshot is an event as it was in one of its revisions
+type EventSnapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Note          string                 `protobuf:"bytes,2,opt,name=note,proto3" json:"note,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Tags          []string               `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Status        string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventSnapshot) Reset() {
+	*x = EventSnapshot{}
+	mi := &file_eventtracker_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventSnapshot) ProtoMessage() {}
+
+func (x *EventSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventSnapshot.ProtoReflect.Descriptor instead.
+func (*EventSnapshot) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *EventSnapshot) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *EventSnapshot) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *EventSnapshot) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *EventSnapshot) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *EventSnapshot) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *EventSnapshot) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type EventRevision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      int32                  `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	Event         *EventSnapshot         `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	Actor         string                 `protobuf:"bytes,3,opt,name=actor,proto3" json:"actor,omitempty"`
+	RevisedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=revised_at,json=revisedAt,proto3" json:"revised_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventRevision) Reset() {
+	*x = EventRevision{}
+	mi := &file_eventtracker_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventRevision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventRevision) ProtoMessage() {}
+
+func (x *EventRevision) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventRevision.ProtoReflect.Descriptor instead.
+func (*EventRevision) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *EventRevision) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *EventRevision) GetEvent() *EventSnapshot {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *EventRevision) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *EventRevision) GetRevisedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RevisedAt
+	}
+	return nil
+}
+
+type ListEventRevisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEventRevisionsRequest) Reset() {
+	*x = ListEventRevisionsRequest{}
+	mi := &file_eventtracker_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEventRevisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEventRevisionsRequest) ProtoMessage() {}
+
+func (x *ListEventRevisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEventRevisionsRequest.ProtoReflect.Descriptor instead.
+func (*ListEventRevisionsRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListEventRevisionsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListEventRevisionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revisions     []*EventRevision       `protobuf:"bytes,1,rep,name=revisions,proto3" json:"revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEventRevisionsResponse) Reset() {
+	*x = ListEventRevisionsResponse{}
+	mi := &file_eventtracker_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEventRevisionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEventRevisionsResponse) ProtoMessage() {}
+
+func (x *ListEventRevisionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEventRevisionsResponse.ProtoReflect.Descriptor instead.
+func (*ListEventRevisionsResponse) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListEventRevisionsResponse) GetRevisions() []*EventRevision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type GetEventRevisionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Revision      int32                  `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEventRevisionRequest) Reset() {
+	*x = GetEventRevisionRequest{}
+	mi := &file_eventtracker_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEventRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEventRevisionRequest) ProtoMessage() {}
+
+func (x *GetEventRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEventRevisionRequest.ProtoReflect.Descriptor instead.
+func (*GetEventRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetEventRevisionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetEventRevisionRequest) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type RestoreEventRevisionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Revision      int32                  `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreEventRevisionRequest) Reset() {
+	*x = RestoreEventRevisionRequest{}
+	mi := &file_eventtracker_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreEventRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreEventRevisionRequest) ProtoMessage() {}
+
+func (x *RestoreEventRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreEventRevisionRequest.ProtoReflect.Descriptor instead.
+func (*RestoreEventRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RestoreEventRevisionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RestoreEventRevisionRequest) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type SyncEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *Event                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Deleted       bool                   `protobuf:"varint,3,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncEvent) Reset() {
+	*x = SyncEvent{}
+	mi := &file_eventtracker_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncEvent) ProtoMessage() {}
+
+func (x *SyncEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncEvent.ProtoReflect.Descriptor instead.
+func (*SyncEvent) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SyncEvent) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *SyncEvent) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *SyncEvent) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+type Tombstone struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	DeletedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tombstone) Reset() {
+	*x = Tombstone{}
+	mi := &file_eventtracker_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tombstone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tombstone) ProtoMessage() {}
+
+func (x *Tombstone) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tombstone.ProtoReflect.Descriptor instead.
+func (*Tombstone) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Tombstone) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Tombstone) GetDeletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return nil
+}
+
+type GetChangesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// token from the last sync, empty for the first one
+	Since string `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	// 0 means the server default
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChangesRequest) Reset() {
+	*x = GetChangesRequest{}
+	mi := &file_eventtracker_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChangesRequest) ProtoMessage() {}
+
+func (x *GetChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChangesRequest.ProtoReflect.Descriptor instead.
+func (*GetChangesRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetChangesRequest) GetSince() string {
+	if x != nil {
+		return x.Since
+	}
+	return ""
+}
+
+func (x *GetChangesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetChangesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Created       []*SyncEvent           `protobuf:"bytes,1,rep,name=created,proto3" json:"created,omitempty"`
+	Updated       []*SyncEvent           `protobuf:"bytes,2,rep,name=updated,proto3" json:"updated,omitempty"`
+	Deleted       []*Tombstone           `protobuf:"bytes,3,rep,name=deleted,proto3" json:"deleted,omitempty"`
+	Next          string                 `protobuf:"bytes,4,opt,name=next,proto3" json:"next,omitempty"`
+	HasMore       bool                   `protobuf:"varint,5,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChangesResponse) Reset() {
+	*x = GetChangesResponse{}
+	mi := &file_eventtracker_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChangesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChangesResponse) ProtoMessage() {}
+
+func (x *GetChangesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChangesResponse.ProtoReflect.Descriptor instead.
+func (*GetChangesResponse) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetChangesResponse) GetCreated() []*SyncEvent {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *GetChangesResponse) GetUpdated() []*SyncEvent {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *GetChangesResponse) GetDeleted() []*Tombstone {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+func (x *GetChangesResponse) GetNext() string {
+	if x != nil {
+		return x.Next
+	}
+	return ""
+}
+
+func (x *GetChangesResponse) GetHasMore() bool {
+	if x != nil {
+		return x.HasMore
+	}
+	return false
+}
+
+type EventChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *Event                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	Deleted       bool                   `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	ChangedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventChange) Reset() {
+	*x = EventChange{}
+	mi := &file_eventtracker_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventChange) ProtoMessage() {}
+
+func (x *EventChange) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventChange.ProtoReflect.Descriptor instead.
+func (*EventChange) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *EventChange) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *EventChange) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *EventChange) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+type PushChangesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Changes       []*EventChange         `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushChangesRequest) Reset() {
+	*x = PushChangesRequest{}
+	mi := &file_eventtracker_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushChangesRequest) ProtoMessage() {}
+
+func (x *PushChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushChangesRequest.ProtoReflect.Descriptor instead.
+func (*PushChangesRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *PushChangesRequest) GetChanges() []*EventChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+type SyncConflict struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EventId       string                 `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Server        *SyncEvent             `protobuf:"bytes,3,opt,name=server,proto3" json:"server,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncConflict) Reset() {
+	*x = SyncConflict{}
+	mi := &file_eventtracker_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncConflict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncConflict) ProtoMessage() {}
+
+func (x *SyncConflict) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncConflict.ProtoReflect.Descriptor instead.
+func (*SyncConflict) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *SyncConflict) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *SyncConflict) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SyncConflict) GetServer() *SyncEvent {
+	if x != nil {
+		return x.Server
+	}
+	return nil
+}
+
+type PushChangesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Applied       []string               `protobuf:"bytes,1,rep,name=applied,proto3" json:"applied,omitempty"`
+	Conflicts     []*SyncConflict        `protobuf:"bytes,2,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushChangesResponse) Reset() {
+	*x = PushChangesResponse{}
+	mi := &file_eventtracker_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushChangesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushChangesResponse) ProtoMessage() {}
+
+func (x *PushChangesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushChangesResponse.ProtoReflect.Descriptor instead.
+func (*PushChangesResponse) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *PushChangesResponse) GetApplied() []string {
+	if x != nil {
+		return x.Applied
+	}
+	return nil
+}
+
+func (x *PushChangesResponse) GetConflicts() []*SyncConflict {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+type ApplyRetentionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRetentionRequest) Reset() {
+	*x = ApplyRetentionRequest{}
+	mi := &file_eventtracker_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRetentionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRetentionRequest) ProtoMessage() {}
+
+func (x *ApplyRetentionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRetentionRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRetentionRequest) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ApplyRetentionRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type RetentionReport struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	DryRun         bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	PurgedEvents   int64                  `protobuf:"varint,2,opt,name=purged_events,json=purgedEvents,proto3" json:"purged_events,omitempty"`
+	PurgedTags     int64                  `protobuf:"varint,3,opt,name=purged_tags,json=purgedTags,proto3" json:"purged_tags,omitempty"`
+	ArchivedEvents int64                  `protobuf:"varint,4,opt,name=archived_events,json=archivedEvents,proto3" json:"archived_events,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RetentionReport) Reset() {
+	*x = RetentionReport{}
+	mi := &file_eventtracker_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetentionReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetentionReport) ProtoMessage() {}
+
+func (x *RetentionReport) ProtoReflect() protoreflect.Message {
+	mi := &file_eventtracker_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetentionReport.ProtoReflect.Descriptor instead.
+func (*RetentionReport) Descriptor() ([]byte, []int) {
+	return file_eventtracker_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RetentionReport) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *RetentionReport) GetPurgedEvents() int64 {
+	if x != nil {
+		return x.PurgedEvents
+	}
+	return 0
+}
+
+func (x *RetentionReport) GetPurgedTags() int64 {
+	if x != nil {
+		return x.PurgedTags
+	}
+	return 0
+}
+
+func (x *RetentionReport) GetArchivedEvents() int64 {
+	if x != nil {
+		return x.ArchivedEvents
+	}
+	return 0
+}
+
+var File_eventtracker_proto protoreflect.FileDescriptor
+
+const file_eventtracker_proto_rawDesc = "" +
+	"\n" +
+	"\x12eventtracker.proto\x12\x0feventtracker.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"!\n" +
+	"\tEventType\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\" \n" +
+	"\bEventTag\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\"\xdb\x01\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04note\x18\x03 \x01(\tR\x04note\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12.\n" +
+	"\x04type\x18\x05 \x01(\v2\x1a.eventtracker.v1.EventTypeR\x04type\x12-\n" +
+	"\x04tags\x18\x06 \x03(\v2\x19.eventtracker.v1.EventTagR\x04tags\"\x13\n" +
+	"\x11ListEventsRequest\"!\n" +
+	"\x0fGetEventRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"B\n" +
+	"\x12CreateEventRequest\x12,\n" +
+	"\x05event\x18\x01 \x01(\v2\x16.eventtracker.v1.EventR\x05event\"%\n" +
+	"\x13CreateEventResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"k\n" +
+	"\vAuditChange\x12.\n" +
+	"\x06before\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x06before\x12,\n" +
+	"\x05after\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05after\"\xe1\x02\n" +
+	"\n" +
+	"AuditEntry\x12\x1f\n" +
+	"\ventity_type\x18\x01 \x01(\tR\n" +
+	"entityType\x12\x1b\n" +
+	"\tentity_id\x18\x02 \x01(\tR\bentityId\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x14\n" +
+	"\x05actor\x18\x04 \x01(\tR\x05actor\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x05 \x01(\tR\trequestId\x12B\n" +
+	"\achanges\x18\x06 \x03(\v2(.eventtracker.v1.AuditEntry.ChangesEntryR\achanges\x12*\n" +
+	"\x02at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x1aX\n" +
+	"\fChangesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x122\n" +
+	"\x05value\x18\x02 \x01(\v2\x1c.eventtracker.v1.AuditChangeR\x05value:\x028\x01\"(\n" +
+	"\x16GetEventHistoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"P\n" +
+	"\x17GetEventHistoryResponse\x125\n" +
+	"\ahistory\x18\x01 \x03(\v2\x1b.eventtracker.v1.AuditEntryR\ahistory\"\xb4\x01\n" +
+	"\rEventSnapshot\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x12\n" +
+	"\x04note\x18\x02 \x01(\tR\x04note\x129\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x12\n" +
+	"\x04tags\x18\x05 \x03(\tR\x04tags\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\"\xb2\x01\n" +
+	"\rEventRevision\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\x05R\brevision\x124\n" +
+	"\x05event\x18\x02 \x01(\v2\x1e.eventtracker.v1.EventSnapshotR\x05event\x12\x14\n" +
+	"\x05actor\x18\x03 \x01(\tR\x05actor\x129\n" +
+	"\n" +
+	"revised_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\trevisedAt\"+\n" +
+	"\x19ListEventRevisionsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"Z\n" +
+	"\x1aListEventRevisionsResponse\x12<\n" +
+	"\trevisions\x18\x01 \x03(\v2\x1e.eventtracker.v1.EventRevisionR\trevisions\"E\n" +
+	"\x17GetEventRevisionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\brevision\x18\x02 \x01(\x05R\brevision\"I\n" +
+	"\x1bRestoreEventRevisionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\brevision\x18\x02 \x01(\x05R\brevision\"\x8e\x01\n" +
+	"\tSyncEvent\x12,\n" +
+	"\x05event\x18\x01 \x01(\v2\x16.eventtracker.v1.EventR\x05event\x129\n" +
+	"\n" +
+	"updated_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x18\n" +
+	"\adeleted\x18\x03 \x01(\bR\adeleted\"V\n" +
+	"\tTombstone\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x129\n" +
+	"\n" +
+	"deleted_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tdeletedAt\"?\n" +
+	"\x11GetChangesRequest\x12\x14\n" +
+	"\x05since\x18\x01 \x01(\tR\x05since\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"\xe5\x01\n" +
+	"\x12GetChangesResponse\x124\n" +
+	"\acreated\x18\x01 \x03(\v2\x1a.eventtracker.v1.SyncEventR\acreated\x124\n" +
+	"\aupdated\x18\x02 \x03(\v2\x1a.eventtracker.v1.SyncEventR\aupdated\x124\n" +
+	"\adeleted\x18\x03 \x03(\v2\x1a.eventtracker.v1.TombstoneR\adeleted\x12\x12\n" +
+	"\x04next\x18\x04 \x01(\tR\x04next\x12\x19\n" +
+	"\bhas_more\x18\x05 \x01(\bR\ahasMore\"\x90\x01\n" +
+	"\vEventChange\x12,\n" +
+	"\x05event\x18\x01 \x01(\v2\x16.eventtracker.v1.EventR\x05event\x12\x18\n" +
+	"\adeleted\x18\x02 \x01(\bR\adeleted\x129\n" +
+	"\n" +
+	"changed_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\"L\n" +
+	"\x12PushChangesRequest\x126\n" +
+	"\achanges\x18\x01 \x03(\v2\x1c.eventtracker.v1.EventChangeR\achanges\"u\n" +
+	"\fSyncConflict\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x122\n" +
+	"\x06server\x18\x03 \x01(\v2\x1a.eventtracker.v1.SyncEventR\x06server\"l\n" +
+	"\x13PushChangesResponse\x12\x18\n" +
+	"\aapplied\x18\x01 \x03(\tR\aapplied\x12;\n" +
+	"\tconflicts\x18\x02 \x03(\v2\x1d.eventtracker.v1.SyncConflictR\tconflicts\"0\n" +
+	"\x15ApplyRetentionRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"\x99\x01\n" +
+	"\x0fRetentionReport\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\x12#\n" +
+	"\rpurged_events\x18\x02 \x01(\x03R\fpurgedEvents\x12\x1f\n" +
+	"\vpurged_tags\x18\x03 \x01(\x03R\n" +
+	"purgedTags\x12'\n" +
+	"\x0farchived_events\x18\x04 \x01(\x03R\x0earchivedEvents2\x98\a\n" +
+	"\fEventTracker\x12J\n" +
+	"\n" +
+	"ListEvents\x12\".eventtracker.v1.ListEventsRequest\x1a\x16.eventtracker.v1.Event0\x01\x12D\n" +
+	"\bGetEvent\x12 .eventtracker.v1.GetEventRequest\x1a\x16.eventtracker.v1.Event\x12X\n" +
+	"\vCreateEvent\x12#.eventtracker.v1.CreateEventRequest\x1a$.eventtracker.v1.CreateEventResponse\x12d\n" +
+	"\x0fGetEventHistory\x12'.eventtracker.v1.GetEventHistoryRequest\x1a(.eventtracker.v1.GetEventHistoryResponse\x12m\n" +
+	"\x12ListEventRevisions\x12*.eventtracker.v1.ListEventRevisionsRequest\x1a+.eventtracker.v1.ListEventRevisionsResponse\x12\\\n" +
+	"\x10GetEventRevision\x12(.eventtracker.v1.GetEventRevisionRequest\x1a\x1e.eventtracker.v1.EventRevision\x12\\\n" +
+	"\x14RestoreEventRevision\x12,.eventtracker.v1.RestoreEventRevisionRequest\x1a\x16.eventtracker.v1.Event\x12U\n" +
+	"\n" +
+	"GetChanges\x12\".eventtracker.v1.GetChangesRequest\x1a#.eventtracker.v1.GetChangesResponse\x12X\n" +
+	"\vPushChanges\x12#.eventtracker.v1.PushChangesRequest\x1a$.eventtracker.v1.PushChangesResponse\x12Z\n" +
+	"\x0eApplyRetention\x12&.eventtracker.v1.ApplyRetentionRequest\x1a .eventtracker.v1.RetentionReportBDZBgithub.com/jforcode/EventTracker-api/eventtrackerpb;eventtrackerpbb\x06proto3"
+
+var (
+	file_eventtracker_proto_rawDescOnce sync.Once
+	file_eventtracker_proto_rawDescData []byte
+)
+
+func file_eventtracker_proto_rawDescGZIP() []byte {
+	file_eventtracker_proto_rawDescOnce.Do(func() {
+		file_eventtracker_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_eventtracker_proto_rawDesc), len(file_eventtracker_proto_rawDesc)))
+	})
+	return file_eventtracker_proto_rawDescData
+}
+
+var file_eventtracker_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
+var file_eventtracker_proto_goTypes = []any{
+	(*EventType)(nil),                   // 0: eventtracker.v1.EventType
+	(*EventTag)(nil),                    // 1: eventtracker.v1.EventTag
+	(*Event)(nil),                       // 2: eventtracker.v1.Event
+	(*ListEventsRequest)(nil),           // 3: eventtracker.v1.ListEventsRequest
+	(*GetEventRequest)(nil),             // 4: eventtracker.v1.GetEventRequest
+	(*CreateEventRequest)(nil),          // 5: eventtracker.v1.CreateEventRequest
+	(*CreateEventResponse)(nil),         // 6: eventtracker.v1.CreateEventResponse
+	(*AuditChange)(nil),                 // 7: eventtracker.v1.AuditChange
+	(*AuditEntry)(nil),                  // 8: eventtracker.v1.AuditEntry
+	(*GetEventHistoryRequest)(nil),      // 9: eventtracker.v1.GetEventHistoryRequest
+	(*GetEventHistoryResponse)(nil),     // 10: eventtracker.v1.GetEventHistoryResponse
+	(*EventSnapshot)(nil),               // 11: eventtracker.v1.EventSnapshot
+	(*EventRevision)(nil),               // 12: eventtracker.v1.EventRevision
+	(*ListEventRevisionsRequest)(nil),   // 13: eventtracker.v1.ListEventRevisionsRequest
+	(*ListEventRevisionsResponse)(nil),  // 14: eventtracker.v1.ListEventRevisionsResponse
+	(*GetEventRevisionRequest)(nil),     // 15: eventtracker.v1.GetEventRevisionRequest
+	(*RestoreEventRevisionRequest)(nil), // 16: eventtracker.v1.RestoreEventRevisionRequest
+	(*SyncEvent)(nil),                   // 17: eventtracker.v1.SyncEvent
+	(*Tombstone)(nil),                   // 18: eventtracker.v1.Tombstone
+	(*GetChangesRequest)(nil),           // 19: eventtracker.v1.GetChangesRequest
+	(*GetChangesResponse)(nil),          // 20: eventtracker.v1.GetChangesResponse
+	(*EventChange)(nil),                 // 21: eventtracker.v1.EventChange
+	(*PushChangesRequest)(nil),          // 22: eventtracker.v1.PushChangesRequest
+	(*SyncConflict)(nil),                // 23: eventtracker.v1.SyncConflict
+	(*PushChangesResponse)(nil),         // 24: eventtracker.v1.PushChangesResponse
+	(*ApplyRetentionRequest)(nil),       // 25: eventtracker.v1.ApplyRetentionRequest
+	(*RetentionReport)(nil),             // 26: eventtracker.v1.RetentionReport
+	nil,                                 // 27: eventtracker.v1.AuditEntry.ChangesEntry
+	(*timestamppb.Timestamp)(nil),       // 28: google.protobuf.Timestamp
+	(*structpb.Value)(nil),              // 29: google.protobuf.Value
+}
+var file_eventtracker_proto_depIdxs = []int32{
+	28, // 0: eventtracker.v1.Event.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 1: eventtracker.v1.Event.type:type_name -> eventtracker.v1.EventType
+	1,  // 2: eventtracker.v1.Event.tags:type_name -> eventtracker.v1.EventTag
+	2,  // 3: eventtracker.v1.CreateEventRequest.event:type_name -> eventtracker.v1.Event
+	29, // 4: eventtracker.v1.AuditChange.before:type_name -> google.protobuf.Value
+	29, // 5: eventtracker.v1.AuditChange.after:type_name -> google.protobuf.Value
+	27, // 6: eventtracker.v1.AuditEntry.changes:type_name -> eventtracker.v1.AuditEntry.ChangesEntry
+	28, // 7: eventtracker.v1.AuditEntry.at:type_name -> google.protobuf.Timestamp
+	8,  // 8: eventtracker.v1.GetEventHistoryResponse.history:type_name -> eventtracker.v1.AuditEntry
+	28, // 9: eventtracker.v1.EventSnapshot.created_at:type_name -> google.protobuf.Timestamp
+	11, // 10: eventtracker.v1.EventRevision.event:type_name -> eventtracker.v1.EventSnapshot
+	28, // 11: eventtracker.v1.EventRevision.revised_at:type_name -> google.protobuf.Timestamp
+	12, // 12: eventtracker.v1.ListEventRevisionsResponse.revisions:type_name -> eventtracker.v1.EventRevision
+	2,  // 13: eventtracker.v1.SyncEvent.event:type_name -> eventtracker.v1.Event
+	28, // 14: eventtracker.v1.SyncEvent.updated_at:type_name -> google.protobuf.Timestamp
+	28, // 15: eventtracker.v1.Tombstone.deleted_at:type_name -> google.protobuf.Timestamp
+	17, // 16: eventtracker.v1.GetChangesResponse.created:type_name -> eventtracker.v1.SyncEvent
+	17, // 17: eventtracker.v1.GetChangesResponse.updated:type_name -> eventtracker.v1.SyncEvent
+	18, // 18: eventtracker.v1.GetChangesResponse.deleted:type_name -> eventtracker.v1.Tombstone
+	2,  // 19: eventtracker.v1.EventChange.event:type_name -> eventtracker.v1.Event
+	28, // 20: eventtracker.v1.EventChange.changed_at:type_name -> google.protobuf.Timestamp
+	21, // 21: eventtracker.v1.PushChangesRequest.changes:type_name -> eventtracker.v1.EventChange
+	17, // 22: eventtracker.v1.SyncConflict.server:type_name -> eventtracker.v1.SyncEvent
+	23, // 23: eventtracker.v1.PushChangesResponse.conflicts:type_name -> eventtracker.v1.SyncConflict
+	7,  // 24: eventtracker.v1.AuditEntry.ChangesEntry.value:type_name -> eventtracker.v1.AuditChange
+	3,  // 25: eventtracker.v1.EventTracker.ListEvents:input_type -> eventtracker.v1.ListEventsRequest
+	4,  // 26: eventtracker.v1.EventTracker.GetEvent:input_type -> eventtracker.v1.GetEventRequest
+	5,  // 27: eventtracker.v1.EventTracker.CreateEvent:input_type -> eventtracker.v1.CreateEventRequest
+	9,  // 28: eventtracker.v1.EventTracker.GetEventHistory:input_type -> eventtracker.v1.GetEventHistoryRequest
+	13, // 29: eventtracker.v1.EventTracker.ListEventRevisions:input_type -> eventtracker.v1.ListEventRevisionsRequest
+	15, // 30: eventtracker.v1.EventTracker.GetEventRevision:input_type -> eventtracker.v1.GetEventRevisionRequest
+	16, // 31: eventtracker.v1.EventTracker.RestoreEventRevision:input_type -> eventtracker.v1.RestoreEventRevisionRequest
+	19, // 32: eventtracker.v1.EventTracker.GetChanges:input_type -> eventtracker.v1.GetChangesRequest
+	22, // 33: eventtracker.v1.EventTracker.PushChanges:input_type -> eventtracker.v1.PushChangesRequest
+	25, // 34: eventtracker.v1.EventTracker.ApplyRetention:input_type -> eventtracker.v1.ApplyRetentionRequest
+	2,  // 35: eventtracker.v1.EventTracker.ListEvents:output_type -> eventtracker.v1.Event
+	2,  // 36: eventtracker.v1.EventTracker.GetEvent:output_type -> eventtracker.v1.Event
+	6,  // 37: eventtracker.v1.EventTracker.CreateEvent:output_type -> eventtracker.v1.CreateEventResponse
+	10, // 38: eventtracker.v1.EventTracker.GetEventHistory:output_type -> eventtracker.v1.GetEventHistoryResponse
+	14, // 39: eventtracker.v1.EventTracker.ListEventRevisions:output_type -> eventtracker.v1.ListEventRevisionsResponse
+	12, // 40: eventtracker.v1.EventTracker.GetEventRevision:output_type -> eventtracker.v1.EventRevision
+	2,  // 41: eventtracker.v1.EventTracker.RestoreEventRevision:output_type -> eventtracker.v1.Event
+	20, // 42: eventtracker.v1.EventTracker.GetChanges:output_type -> eventtracker.v1.GetChangesResponse
+	24, // 43: eventtracker.v1.EventTracker.PushChanges:output_type -> eventtracker.v1.PushChangesResponse
+	26, // 44: eventtracker.v1.EventTracker.ApplyRetention:output_type -> eventtracker.v1.RetentionReport
+	35, // [35:45] is the sub-list for method output_type
+	25, // [25:35] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_eventtracker_proto_init() }
+func file_eventtracker_proto_init() {
+	if File_eventtracker_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_eventtracker_proto_rawDesc), len(file_eventtracker_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   28,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_eventtracker_proto_goTypes,
+		DependencyIndexes: file_eventtracker_proto_depIdxs,
+		MessageInfos:      file_eventtracker_proto_msgTypes,
+	}.Build()
+	File_eventtracker_proto = out.File
+	file_eventtracker_proto_goTypes = nil
+	file_eventtracker_proto_depIdxs = nil
+}
@@ -0,0 +1,475 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: eventtracker.proto
+
+package eventtrackerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EventTracker_ListEvents_FullMethodName           = "/eventtracker.v1.EventTracker/ListEvents"
+	EventTracker_GetEvent_FullMethodName             = "/eventtracker.v1.EventTracker/GetEvent"
+	EventTracker_CreateEvent_FullMethodName          = "/eventtracker.v1.EventTracker/CreateEvent"
+	EventTracker_GetEventHistory_FullMethodName      = "/eventtracker.v1.EventTracker/GetEventHistory"
+	EventTracker_ListEventRevisions_FullMethodName   = "/eventtracker.v1.EventTracker/ListEventRevisions"
+	EventTracker_GetEventRevision_FullMethodName     = "/eventtracker.v1.EventTracker/GetEventRevision"
+	EventTracker_RestoreEventRevision_FullMethodName = "/eventtracker.v1.EventTracker/RestoreEventRevision"
+	EventTracker_GetChanges_FullMethodName           = "/eventtracker.v1.EventTracker/GetChanges"
+	EventTracker_PushChanges_FullMethodName          = "/eventtracker.v1.EventTracker/PushChanges"
+	EventTracker_ApplyRetention_FullMethodName       = "/eventtracker.v1.EventTracker/ApplyRetention"
+)
+
+// EventTrackerClient is the client API for EventTracker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// EventTracker is the gRPC face of the events api. It behaves the same as the REST routes.
+type EventTrackerClient interface {
+	// ListEvents streams every event that isn't deleted
+	ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	GetEvent(ctx context.Context, in *GetEventRequest, opts ...grpc.CallOption) (*Event, error)
+	CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*CreateEventResponse, error)
+	GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResponse, error)
+	ListEventRevisions(ctx context.Context, in *ListEventRevisionsRequest, opts ...grpc.CallOption) (*ListEventRevisionsResponse, error)
+	GetEventRevision(ctx context.Context, in *GetEventRevisionRequest, opts ...grpc.CallOption) (*EventRevision, error)
+	RestoreEventRevision(ctx context.Context, in *RestoreEventRevisionRequest, opts ...grpc.CallOption) (*Event, error)
+	GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (*GetChangesResponse, error)
+	PushChanges(ctx context.Context, in *PushChangesRequest, opts ...grpc.CallOption) (*PushChangesResponse, error)
+	// ApplyRetention runs the server's retention policy right away
+	ApplyRetention(ctx context.Context, in *ApplyRetentionRequest, opts ...grpc.CallOption) (*RetentionReport, error)
+}
+
+type eventTrackerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventTrackerClient(cc grpc.ClientConnInterface) EventTrackerClient {
+	return &eventTrackerClient{cc}
+}
+
+func (c *eventTrackerClient) ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventTracker_ServiceDesc.Streams[0], EventTracker_ListEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventTracker_ListEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *eventTrackerClient) GetEvent(ctx context.Context, in *GetEventRequest, opts ...grpc.CallOption) (*Event, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Event)
+	err := c.cc.Invoke(ctx, EventTracker_GetEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*CreateEventResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateEventResponse)
+	err := c.cc.Invoke(ctx, EventTracker_CreateEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetEventHistoryResponse)
+	err := c.cc.Invoke(ctx, EventTracker_GetEventHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) ListEventRevisions(ctx context.Context, in *ListEventRevisionsRequest, opts ...grpc.CallOption) (*ListEventRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEventRevisionsResponse)
+	err := c.cc.Invoke(ctx, EventTracker_ListEventRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) GetEventRevision(ctx context.Context, in *GetEventRevisionRequest, opts ...grpc.CallOption) (*EventRevision, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EventRevision)
+	err := c.cc.Invoke(ctx, EventTracker_GetEventRevision_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) RestoreEventRevision(ctx context.Context, in *RestoreEventRevisionRequest, opts ...grpc.CallOption) (*Event, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Event)
+	err := c.cc.Invoke(ctx, EventTracker_RestoreEventRevision_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (*GetChangesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetChangesResponse)
+	err := c.cc.Invoke(ctx, EventTracker_GetChanges_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) PushChanges(ctx context.Context, in *PushChangesRequest, opts ...grpc.CallOption) (*PushChangesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PushChangesResponse)
+	err := c.cc.Invoke(ctx, EventTracker_PushChanges_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventTrackerClient) ApplyRetention(ctx context.Context, in *ApplyRetentionRequest, opts ...grpc.CallOption) (*RetentionReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetentionReport)
+	err := c.cc.Invoke(ctx, EventTracker_ApplyRetention_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EventTrackerServer is the server API for EventTracker service.
+// All implementations must embed UnimplementedEventTrackerServer
+// for forward compatibility.
+//
+// EventTracker is the gRPC face of the events api. It behaves the same as the REST routes.
+type EventTrackerServer interface {
+	// ListEvents streams every event that isn't deleted
+	ListEvents(*ListEventsRequest, grpc.ServerStreamingServer[Event]) error
+	GetEvent(context.Context, *GetEventRequest) (*Event, error)
+	CreateEvent(context.Context, *CreateEventRequest) (*CreateEventResponse, error)
+	GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResponse, error)
+	ListEventRevisions(context.Context, *ListEventRevisionsRequest) (*ListEventRevisionsResponse, error)
+	GetEventRevision(context.Context, *GetEventRevisionRequest) (*EventRevision, error)
+	RestoreEventRevision(context.Context, *RestoreEventRevisionRequest) (*Event, error)
+	GetChanges(context.Context, *GetChangesRequest) (*GetChangesResponse, error)
+	PushChanges(context.Context, *PushChangesRequest) (*PushChangesResponse, error)
+	// ApplyRetention runs the server's retention policy right away
+	ApplyRetention(context.Context, *ApplyRetentionRequest) (*RetentionReport, error)
+	mustEmbedUnimplementedEventTrackerServer()
+}
+
+// UnimplementedEventTrackerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEventTrackerServer struct{}
+
+func (UnimplementedEventTrackerServer) ListEvents(*ListEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method ListEvents not implemented")
+}
+func (UnimplementedEventTrackerServer) GetEvent(context.Context, *GetEventRequest) (*Event, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetEvent not implemented")
+}
+func (UnimplementedEventTrackerServer) CreateEvent(context.Context, *CreateEventRequest) (*CreateEventResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateEvent not implemented")
+}
+func (UnimplementedEventTrackerServer) GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetEventHistory not implemented")
+}
+func (UnimplementedEventTrackerServer) ListEventRevisions(context.Context, *ListEventRevisionsRequest) (*ListEventRevisionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListEventRevisions not implemented")
+}
+func (UnimplementedEventTrackerServer) GetEventRevision(context.Context, *GetEventRevisionRequest) (*EventRevision, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetEventRevision not implemented")
+}
+func (UnimplementedEventTrackerServer) RestoreEventRevision(context.Context, *RestoreEventRevisionRequest) (*Event, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreEventRevision not implemented")
+}
+func (UnimplementedEventTrackerServer) GetChanges(context.Context, *GetChangesRequest) (*GetChangesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetChanges not implemented")
+}
+func (UnimplementedEventTrackerServer) PushChanges(context.Context, *PushChangesRequest) (*PushChangesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PushChanges not implemented")
+}
+func (UnimplementedEventTrackerServer) ApplyRetention(context.Context, *ApplyRetentionRequest) (*RetentionReport, error) {
+	return nil, status.Error(codes.Unimplemented, "method ApplyRetention not implemented")
+}
+func (UnimplementedEventTrackerServer) mustEmbedUnimplementedEventTrackerServer() {}
+func (UnimplementedEventTrackerServer) testEmbeddedByValue()                      {}
+
+// UnsafeEventTrackerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventTrackerServer will
+// result in compilation errors.
+type UnsafeEventTrackerServer interface {
+	mustEmbedUnimplementedEventTrackerServer()
+}
+
+func RegisterEventTrackerServer(s grpc.ServiceRegistrar, srv EventTrackerServer) {
+	// If the following call panics, it indicates UnimplementedEventTrackerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EventTracker_ServiceDesc, srv)
+}
+
+func _EventTracker_ListEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventTrackerServer).ListEvents(m, &grpc.GenericServerStream[ListEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventTracker_ListEventsServer = grpc.ServerStreamingServer[Event]
+
+func _EventTracker_GetEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).GetEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_GetEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).GetEvent(ctx, req.(*GetEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_CreateEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).CreateEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_CreateEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).CreateEvent(ctx, req.(*CreateEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_GetEventHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).GetEventHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_GetEventHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).GetEventHistory(ctx, req.(*GetEventHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_ListEventRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEventRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).ListEventRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_ListEventRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).ListEventRevisions(ctx, req.(*ListEventRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_GetEventRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).GetEventRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_GetEventRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).GetEventRevision(ctx, req.(*GetEventRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_RestoreEventRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreEventRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).RestoreEventRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_RestoreEventRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).RestoreEventRevision(ctx, req.(*RestoreEventRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_GetChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).GetChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_GetChanges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).GetChanges(ctx, req.(*GetChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_PushChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).PushChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_PushChanges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).PushChanges(ctx, req.(*PushChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventTracker_ApplyRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRetentionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventTrackerServer).ApplyRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventTracker_ApplyRetention_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventTrackerServer).ApplyRetention(ctx, req.(*ApplyRetentionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EventTracker_ServiceDesc is the grpc.ServiceDesc for EventTracker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventTracker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eventtracker.v1.EventTracker",
+	HandlerType: (*EventTrackerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetEvent",
+			Handler:    _EventTracker_GetEvent_Handler,
+		},
+		{
+			MethodName: "CreateEvent",
+			Handler:    _EventTracker_CreateEvent_Handler,
+		},
+		{
+			MethodName: "GetEventHistory",
+			Handler:    _EventTracker_GetEventHistory_Handler,
+		},
+		{
+			MethodName: "ListEventRevisions",
+			Handler:    _EventTracker_ListEventRevisions_Handler,
+		},
+		{
+			MethodName: "GetEventRevision",
+			Handler:    _EventTracker_GetEventRevision_Handler,
+		},
+		{
+			MethodName: "RestoreEventRevision",
+			Handler:    _EventTracker_RestoreEventRevision_Handler,
+		},
+		{
+			MethodName: "GetChanges",
+			Handler:    _EventTracker_GetChanges_Handler,
+		},
+		{
+			MethodName: "PushChanges",
+			Handler:    _EventTracker_PushChanges_Handler,
+		},
+		{
+			MethodName: "ApplyRetention",
+			Handler:    _EventTracker_ApplyRetention_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListEvents",
+			Handler:       _EventTracker_ListEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "eventtracker.proto",
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityFieldSchema = "field_schema"
+
+	fieldTypeString    = "string"
+	fieldTypeNumber    = "number"
+	fieldTypeBoolean   = "boolean"
+	fieldTypeTimestamp = "timestamp"
+
+	maxEventFields = 50
+)
+
+var (
+	fieldNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{1,64}$`)
+	fieldTypes       = []string{fieldTypeString, fieldTypeNumber, fieldTypeBoolean, fieldTypeTimestamp}
+
+	queryGetFieldSchemas = fmt.Sprintf(`
+		SELECT S.%s, S.%s, S.%s, S.%s, S.%s, S.%s, S.%s
+		FROM %s S
+		ORDER BY S.%s`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus, fieldSchemasColName, fieldSchemasColType, fieldSchemasColDescription,
+		fieldSchemasTableName,
+		fieldSchemasColName)
+
+	errFieldSchemaExists   = newBadRequestError("field already has a schema")
+	errFieldSchemaNotFound = newNotFoundError("Field schema not found")
+)
+
+// EventFields are the custom fields of an event, by name. Values are strings, numbers or booleans,
+// with timestamps kept as RFC 3339 strings in UTC. Stored as a JSON column.
+type EventFields map[string]interface{}
+
+// Scan reads the fields from their JSON column
+func (fields *EventFields) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*fields = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, fields)
+	case string:
+		return json.Unmarshal([]byte(src), fields)
+	}
+
+	return fmt.Errorf("can't scan %T into event fields", src)
+}
+
+// Value writes the fields to their JSON column, NULL when there are none
+func (fields EventFields) Value() (driver.Value, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	fieldsJSON, err := json.Marshal(map[string]interface{}(fields))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(fieldsJSON), nil
+}
+
+// FieldSchema is the Db model for the type a custom field has to have on every event
+type FieldSchema struct {
+	DbRecord
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// FieldSchemaResponse represents the response to send to client, in case of a create field schema
+type FieldSchemaResponse struct {
+	Field *FieldSchema `json:"field"`
+}
+
+// FieldSchemasResponse represents the response to send to client, in case of a get field schemas
+type FieldSchemasResponse struct {
+	Fields []*FieldSchema `json:"fields"`
+}
+
+// FieldFilter matches events whose field has the value, compared as text, like "true" for a boolean
+type FieldFilter struct {
+	Name  string
+	Value string
+}
+
+func validateFieldSchema(schema *FieldSchema) error {
+	if !fieldNamePattern.MatchString(schema.Name) {
+		return errors.New("field name should be 1 to 64 letters, digits or _")
+	}
+
+	for _, fieldType := range fieldTypes {
+		if schema.Type == fieldType {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("field type should be one of %s", strings.Join(fieldTypes, ", "))
+}
+
+// validateEventFields checks the fields of an event against their schemas, if they have one, turning timestamps to UTC.
+// Fields without a schema can be strings, numbers or booleans.
+func validateEventFields(ctx context.Context, handler IEventsHandler, event *Event) error {
+	if len(event.Fields) == 0 {
+		return nil
+	}
+	if len(event.Fields) > maxEventFields {
+		return newBadRequestError(fmt.Sprintf("an event can have at most %d fields", maxEventFields))
+	}
+
+	schemas, err := handler.GetFieldSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	types := make(map[string]string)
+	for _, schema := range schemas {
+		types[schema.Name] = schema.Type
+	}
+
+	for name, value := range event.Fields {
+		if !fieldNamePattern.MatchString(name) {
+			return newBadRequestError(fmt.Sprintf("field %q: name should be 1 to 64 letters, digits or _", name))
+		}
+
+		value, err = checkFieldValue(types[name], value)
+		if err != nil {
+			return newBadRequestError(fmt.Sprintf("field %q: %s", name, err.Error()))
+		}
+		event.Fields[name] = value
+	}
+
+	return nil
+}
+
+// checkFieldValue checks a value is of fieldType, or of any type if it is empty, and gives it back as it is stored
+func checkFieldValue(fieldType string, value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		if fieldType == fieldTypeTimestamp {
+			timestamp, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.New("should be an RFC 3339 timestamp")
+			}
+			return timestamp.UTC().Format(time.RFC3339Nano), nil
+		}
+		if fieldType == "" || fieldType == fieldTypeString {
+			return value, nil
+		}
+	case float64:
+		if fieldType == "" || fieldType == fieldTypeNumber {
+			return value, nil
+		}
+	case bool:
+		if fieldType == "" || fieldType == fieldTypeBoolean {
+			return value, nil
+		}
+	}
+
+	if fieldType == "" {
+		return nil, errors.New("should be a string, number or boolean")
+	}
+	return nil, fmt.Errorf("should be a %s", fieldType)
+}
+
+// fieldValueText is a field value as text, the way mysql gives it out of a JSON column
+func fieldValueText(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// parseFieldFilters reads field filters given as name:value
+func parseFieldFilters(raw []string) ([]FieldFilter, error) {
+	filters := make([]FieldFilter, 0)
+	for _, rawFilter := range raw {
+		name, value, ok := strings.Cut(rawFilter, ":")
+		if !ok || !fieldNamePattern.MatchString(name) {
+			return nil, errors.New("field should be given as name:value")
+		}
+		filters = append(filters, FieldFilter{Name: name, Value: value})
+	}
+	sort.SliceStable(filters, func(i, j int) bool {
+		return filters[i].Name < filters[j].Name
+	})
+
+	return filters, nil
+}
+
+// fieldPath is the JSON path of a field, its name is already known to be safe
+func fieldPath(name string) string {
+	return `$."` + name + `"`
+}
+
+// CreateFieldSchema makes events have to give the field the type of the schema. Events which already have
+// the field aren't checked.
+func (handler *EventsHandler) CreateFieldSchema(ctx context.Context, schema *FieldSchema) error {
+	fn := "CreateFieldSchema"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		res, err := txStuff.exec(ctx,
+			fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s, %s) VALUES (?, ?, ?)",
+				fieldSchemasTableName, fieldSchemasColName, fieldSchemasColType, fieldSchemasColDescription),
+			schema.Name, schema.Type, schema.Description)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return deepError.New(fn, "rows affected", err)
+		}
+		if inserted == 0 {
+			return errFieldSchemaExists
+		}
+		schema.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityFieldSchema, schema.Name, auditActionCreate, nil, schema)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
+
+// GetFieldSchemas gets the schemas of all fields which have one
+func (handler *EventsHandler) GetFieldSchemas(ctx context.Context) ([]*FieldSchema, error) {
+	fn := "GetFieldSchemas"
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, queryGetFieldSchemas)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	schemas := make([]*FieldSchema, 0)
+	for rows.Next() {
+		schema := &FieldSchema{}
+		var description *string
+		err = rows.Scan(&schema.DbID, &schema.CreatedAt, &schema.UpdatedAt, &schema.Status, &schema.Name, &schema.Type, &description)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		if description != nil {
+			schema.Description = *description
+		}
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, nil
+}
+
+// DeleteFieldSchema lets the field have any type again. Values events already have are left alone.
+func (handler *EventsHandler) DeleteFieldSchema(ctx context.Context, name string) error {
+	fn := "DeleteFieldSchema"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		res, err := txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", fieldSchemasTableName, fieldSchemasColName), name)
+		if err != nil {
+			return deepError.New(fn, "delete", err)
+		}
+
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return deepError.New(fn, "rows affected", err)
+		}
+		if deleted == 0 {
+			return errFieldSchemaNotFound
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityFieldSchema, name, auditActionDelete, nil, nil)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
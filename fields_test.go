@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestEventFieldsValue(t *testing.T) {
+	fn := "TestEventFieldsValue"
+
+	value, err := EventFields{}.Value()
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, nil, value, fn+": Empty fields not stored as NULL")
+
+	value, err = EventFields{"mood": "good", "energy": 7.5}.Value()
+	util.Test.HandleIfTestError(t, err, fn)
+
+	fields := EventFields{}
+	util.Test.HandleIfTestError(t, fields.Scan([]byte(value.(string))), fn)
+	util.Test.AssertEquals(t, EventFields{"mood": "good", "energy": 7.5}, fields, fn+": Fields not read back")
+
+	util.Test.HandleIfTestError(t, fields.Scan(nil), fn)
+	util.Test.AssertEquals(t, EventFields(nil), fields, fn+": NULL not read as no fields")
+}
+
+func TestEventFields(t *testing.T) {
+	fn := "TestEventFields"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeCreateEvent, CreateEventHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeCreateFieldSchema, CreateFieldSchemaHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetFieldSchemas, GetFieldSchemasHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeDeleteFieldSchema, DeleteFieldSchemaHandler(env)).Methods(http.MethodDelete)
+
+	schemaTests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"number", `{"name": "distance", "type": "number"}`, http.StatusOK},
+		{"timestamp", `{"name": "due", "type": "timestamp"}`, http.StatusOK},
+		{"same name again", `{"name": "distance", "type": "string"}`, http.StatusBadRequest},
+		{"unknown type", `{"name": "mood", "type": "date"}`, http.StatusBadRequest},
+		{"bad name", `{"name": "my field", "type": "string"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range schemaTests {
+		rr := serveTest(t, fn, router, http.MethodPost, routeCreateFieldSchema, test.body)
+		util.Test.AssertEquals(t, test.expected, rr.Code, fn+": Wrong Status Code for schema "+test.name)
+	}
+
+	eventTests := []struct {
+		name     string
+		fields   string
+		expected int
+	}{
+		{"typed", `{"distance": 5, "due": "2020-01-06T07:00:00+05:30", "outdoors": true}`, http.StatusOK},
+		{"other values", `{"distance": 10, "outdoors": false, "mood": "tired"}`, http.StatusOK},
+		{"wrong type", `{"distance": "far"}`, http.StatusBadRequest},
+		{"bad timestamp", `{"due": "tomorrow"}`, http.StatusBadRequest},
+		{"object", `{"route": {"from": "home"}}`, http.StatusBadRequest},
+		{"bad name", `{"my field": 1}`, http.StatusBadRequest},
+	}
+
+	for _, test := range eventTests {
+		body := fmt.Sprintf(`{"title": "run", "type": {"value": "start"}, "fields": %s}`, test.fields)
+		rr := serveTest(t, fn, router, http.MethodPost, routeCreateEvent, body)
+		util.Test.AssertEquals(t, test.expected, rr.Code, fn+": Wrong Status Code for event "+test.name)
+	}
+
+	events := struct {
+		Data EventsResponse `json:"data"`
+	}{}
+	rr := serveTest(t, fn, router, http.MethodGet, routeGetEvents+"?field=outdoors:true", "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &events), fn)
+	util.Test.AssertEquals(t, 1, len(events.Data.Events), fn+": Wrong events for a boolean field")
+	util.Test.AssertEquals(t, "2020-01-06T01:30:00Z", events.Data.Events[0].Fields["due"], fn+": Timestamp not in UTC")
+
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEvents+"?field=distance:10&field=mood:tired", "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &events), fn)
+	util.Test.AssertEquals(t, 1, len(events.Data.Events), fn+": Wrong events for two fields")
+
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEvents+"?field=mood", "")
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Field filter without a value accepted")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeDeleteFieldSchemaF, "distance"), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Schema not deleted")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeCreateEvent, `{"title": "run", "type": {"value": "start"}, "fields": {"distance": "far"}}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Field still typed after its schema was deleted")
+
+	schemas := struct {
+		Data FieldSchemasResponse `json:"data"`
+	}{}
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetFieldSchemas, "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &schemas), fn)
+	util.Test.AssertEquals(t, 1, len(schemas.Data.Fields), fn+": Wrong schemas")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeDeleteFieldSchemaF, "distance"), "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Unknown schema deleted")
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityGoal = "goal"
+
+	// goalMetricDuration adds up the time from the start events of a goal to their ends, in seconds
+	goalMetricDuration = "duration"
+	// goalMetricCount counts the events of a goal
+	goalMetricCount = "count"
+
+	goalPeriodDay   = "day"
+	goalPeriodWeek  = "week"
+	goalPeriodMonth = "month"
+
+	defaultGoalPeriods = 8
+	maxGoalPeriods     = 366
+
+	// a session is looked for this long before the first period, so that one running into it is counted,
+	// and one without an end yet is counted for at most this long
+	maxSessionLength = 24 * time.Hour
+)
+
+var (
+	queryGetGoals = fmt.Sprintf(`
+		SELECT G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s
+		FROM %s G
+		WHERE G.%s = '%s'
+		ORDER BY G.%s`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		goalsColID, goalsColName, goalsColTargetTag, goalsColTargetType, goalsColMetric, goalsColTarget, goalsColPeriod, goalsColTimeZone,
+		goalsTableName,
+		colStatus, statusActive,
+		colDbID)
+
+	queryGetGoal = fmt.Sprintf(`
+		SELECT G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s, G.%s
+		FROM %s G
+		WHERE G.%s = ? AND G.%s = '%s'`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		goalsColID, goalsColName, goalsColTargetTag, goalsColTargetType, goalsColMetric, goalsColTarget, goalsColPeriod, goalsColTimeZone,
+		goalsTableName,
+		goalsColID, colStatus, statusActive)
+
+	errGoalNotFound = newNotFoundError("Goal with ID not found")
+)
+
+// Goal is the Db model for a target to hit every period, like 10 hours a week of events tagged deep_work
+type Goal struct {
+	DbRecord
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Tag or EventType is what the goal is about, only one of them
+	Tag       string `json:"tag,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+	Metric    string `json:"metric"`
+	// Target is the seconds, for a duration goal, or the events, for a count goal, to get to in a period
+	Target float64 `json:"target"`
+	Period string  `json:"period"`
+	// TimeZone is where periods begin and end, UTC if empty
+	TimeZone string `json:"time_zone"`
+}
+
+// GoalIDResponse represents the response to send to client, in case of a create goal
+type GoalIDResponse struct {
+	GoalID string `json:"goalID"`
+}
+
+// GoalResponse represents the response to send to client, in case of a get goal
+type GoalResponse struct {
+	Goal *Goal `json:"goal"`
+}
+
+// GoalsResponse represents the response to send to client, in case of a get goals
+type GoalsResponse struct {
+	Goals []*Goal `json:"goals"`
+}
+
+// GoalPeriod is how far a goal got in one period, from Start inclusive to End exclusive
+type GoalPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Value float64   `json:"value"`
+	Met   bool      `json:"met"`
+	// Current is the period now is in, still going
+	Current bool `json:"current"`
+}
+
+// GoalProgress represents the response to send to client, in case of a get goal progress. Periods are oldest first,
+// ending with the current one. Streaks are runs of periods met among them, the current one only breaking a streak
+// once it is over.
+type GoalProgress struct {
+	Goal          *Goal         `json:"goal"`
+	Periods       []*GoalPeriod `json:"periods"`
+	CurrentStreak int           `json:"current_streak"`
+	LongestStreak int           `json:"longest_streak"`
+}
+
+// goalSession is the time from a start event to its end
+type goalSession struct {
+	start time.Time
+	end   time.Time
+}
+
+func validateGoal(goal *Goal) error {
+	switch {
+	case goal.Name == "":
+		return errors.New("name is required")
+	case (goal.Tag == "") == (goal.EventType == ""):
+		return errors.New("either tag or event_type is required, not both")
+	case goal.Metric != goalMetricDuration && goal.Metric != goalMetricCount:
+		return fmt.Errorf("metric should be %s or %s", goalMetricDuration, goalMetricCount)
+	case goal.Metric == goalMetricDuration && goal.EventType != "" && goal.EventType != eventTypeStart:
+		return fmt.Errorf("a duration goal is about start events, so event_type can only be %s", eventTypeStart)
+	case goal.Target <= 0:
+		return errors.New("target should be more than 0")
+	case goal.Period != goalPeriodDay && goal.Period != goalPeriodWeek && goal.Period != goalPeriodMonth:
+		return fmt.Errorf("period should be %s, %s or %s", goalPeriodDay, goalPeriodWeek, goalPeriodMonth)
+	}
+
+	if goal.TimeZone != "" {
+		_, err := loadLocation(goal.TimeZone)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// location is where the periods of the goal begin and end
+func (goal *Goal) location() *time.Location {
+	if goal.TimeZone != "" {
+		location, err := loadLocation(goal.TimeZone)
+		if err == nil {
+			return location
+		}
+	}
+
+	return time.UTC
+}
+
+// periodStart is the start of the period t is in, in the location of t. Weeks start on monday.
+func periodStart(t time.Time, period string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case goalPeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case goalPeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+
+	return day
+}
+
+// addPeriods moves a period start by n periods, going by the calendar so that days stay days across DST changes
+func addPeriods(start time.Time, period string, n int) time.Time {
+	switch period {
+	case goalPeriodWeek:
+		return start.AddDate(0, 0, 7*n)
+	case goalPeriodMonth:
+		return start.AddDate(0, n, 0)
+	}
+
+	return start.AddDate(0, 0, n)
+}
+
+// computeGoalProgress works out how far a goal got in the last periods, up to the current one now is in
+func computeGoalProgress(ctx context.Context, handler IEventsHandler, goal *Goal, periods int, now time.Time) (*GoalProgress, error) {
+	fn := "computeGoalProgress"
+
+	current := periodStart(now.In(goal.location()), goal.Period)
+	first := addPeriods(current, goal.Period, 1-periods)
+
+	progress := &GoalProgress{Goal: goal, Periods: make([]*GoalPeriod, 0)}
+	for start := first; !start.After(current); start = addPeriods(start, goal.Period, 1) {
+		progress.Periods = append(progress.Periods, &GoalPeriod{
+			Start:   start,
+			End:     addPeriods(start, goal.Period, 1),
+			Current: start.Equal(current),
+		})
+	}
+
+	if goal.Metric == goalMetricCount {
+		events, err := handler.FindEvents(ctx, goalFilter(goal, first))
+		if err != nil {
+			return nil, deepError.New(fn, "find events", err)
+		}
+		for _, event := range events {
+			for _, period := range progress.Periods {
+				if !event.UserCreatedAt.Before(period.Start) && event.UserCreatedAt.Before(period.End) {
+					period.Value++
+				}
+			}
+		}
+	} else {
+		sessions, err := findGoalSessions(ctx, handler, goal, first.Add(-maxSessionLength), now)
+		if err != nil {
+			return nil, deepError.New(fn, "find sessions", err)
+		}
+		for _, session := range sessions {
+			for _, period := range progress.Periods {
+				start, end := session.start, session.end
+				if start.Before(period.Start) {
+					start = period.Start
+				}
+				if end.After(period.End) {
+					end = period.End
+				}
+				if end.After(start) {
+					period.Value += end.Sub(start).Seconds()
+				}
+			}
+		}
+	}
+
+	run := 0
+	for _, period := range progress.Periods {
+		period.Met = period.Value >= goal.Target
+		switch {
+		case period.Met:
+			run++
+		case !period.Current:
+			run = 0
+		}
+		if run > progress.LongestStreak {
+			progress.LongestStreak = run
+		}
+	}
+	progress.CurrentStreak = run
+
+	return progress, nil
+}
+
+// goalFilter finds the events of a goal from a time on
+func goalFilter(goal *Goal, from time.Time) EventFilter {
+	filter := EventFilter{Type: goal.EventType, From: from}
+	if goal.Tag != "" {
+		filter.Tags = []string{goal.Tag}
+	}
+	if goal.Metric == goalMetricDuration {
+		filter.Type = eventTypeStart
+	}
+
+	return filter
+}
+
+// findGoalSessions pairs the start events of a goal with their end, the one closing it if linked or else the first
+// end after it with the same title, and the tag of the goal if it has one, that doesn't close another start and isn't
+// taken by an earlier start. A start without an end yet runs till now, for at most maxSessionLength.
+func findGoalSessions(ctx context.Context, handler IEventsHandler, goal *Goal, from, now time.Time) ([]*goalSession, error) {
+	fn := "findGoalSessions"
+
+	starts, err := handler.FindEvents(ctx, goalFilter(goal, from))
+	if err != nil {
+		return nil, deepError.New(fn, "find start events", err)
+	}
+	endFilter := EventFilter{Type: eventTypeEnd, From: from}
+	if goal.Tag != "" {
+		endFilter.Tags = []string{goal.Tag}
+	}
+	ends, err := handler.FindEvents(ctx, endFilter)
+	if err != nil {
+		return nil, deepError.New(fn, "find end events", err)
+	}
+	sort.SliceStable(starts, func(i, j int) bool {
+		return starts[i].UserCreatedAt.Before(starts[j].UserCreatedAt)
+	})
+	sort.SliceStable(ends, func(i, j int) bool {
+		return ends[i].UserCreatedAt.Before(ends[j].UserCreatedAt)
+	})
+
+	endTimes := make(map[string]time.Time)
+	// ends closing a start are only ever its end
+	taken := make(map[string]bool)
+	for _, end := range ends {
+		endTimes[end.ID] = end.UserCreatedAt
+		for _, link := range end.Links {
+			if link.Type == linkTypeCloses && link.FromEventID == end.ID {
+				taken[end.ID] = true
+			}
+		}
+	}
+
+	sessions := make([]*goalSession, 0)
+	for _, start := range starts {
+		session := &goalSession{start: start.UserCreatedAt}
+		for _, link := range start.Links {
+			if link.Type == linkTypeCloses && link.ToEventID == start.ID {
+				session.end = endTimes[link.FromEventID]
+			}
+		}
+
+		if session.end.IsZero() {
+			next := sort.Search(len(ends), func(i int) bool {
+				return !ends[i].UserCreatedAt.Before(start.UserCreatedAt)
+			})
+			for ; next < len(ends); next++ {
+				end := ends[next]
+				if !taken[end.ID] && end.Title == start.Title {
+					taken[end.ID] = true
+					session.end = end.UserCreatedAt
+					break
+				}
+			}
+		}
+		if session.end.IsZero() {
+			session.end = now
+			if limit := session.start.Add(maxSessionLength); session.end.After(limit) {
+				session.end = limit
+			}
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// CreateGoal adds a goal
+func (handler *EventsHandler) CreateGoal(ctx context.Context, goal *Goal) (string, error) {
+	fn := "CreateGoal"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	goal.ID = uuid.New().String()
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			goalsTableName, goalsColID, goalsColName, goalsColTargetTag, goalsColTargetType, goalsColMetric, goalsColTarget,
+			goalsColPeriod, goalsColTimeZone)
+
+		res, err := txStuff.exec(ctx, query, goal.ID, goal.Name, goal.Tag, goal.EventType, goal.Metric, goal.Target, goal.Period, goal.TimeZone)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+		goal.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityGoal, goal.ID, auditActionCreate, nil, goal)
+	})
+	if err != nil {
+		logger.Error("create goal failed", errorAttr(err))
+		return "", deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("goal created", "goal_id", goal.ID)
+	return goal.ID, nil
+}
+
+// GetGoals gets all the goals which are not deleted
+func (handler *EventsHandler) GetGoals(ctx context.Context) ([]*Goal, error) {
+	fn := "GetGoals"
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, queryGetGoals)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	goals := make([]*Goal, 0)
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		goals = append(goals, goal)
+	}
+
+	return goals, nil
+}
+
+// GetGoal gets a goal, nil if there is no such goal
+func (handler *EventsHandler) GetGoal(ctx context.Context, goalID string) (*Goal, error) {
+	fn := "GetGoal"
+
+	goal, err := handler.dbStuff.findGoal(ctx, goalID, false)
+	if err != nil {
+		return nil, deepError.New(fn, "find goal", err)
+	}
+
+	return goal, nil
+}
+
+// DeleteGoal soft deletes a goal
+func (handler *EventsHandler) DeleteGoal(ctx context.Context, goalID string) error {
+	fn := "DeleteGoal"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		goal, err := txStuff.findGoal(ctx, goalID, true)
+		if err != nil {
+			return deepError.New(fn, "find goal", err)
+		}
+		if goal == nil {
+			return errGoalNotFound
+		}
+
+		_, err = txStuff.exec(ctx, fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", goalsTableName, colStatus, colDbID),
+			statusDeleted, goal.DbID)
+		if err != nil {
+			return deepError.New(fn, "update", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityGoal, goal.ID, auditActionDelete, goal, nil)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
+
+func scanGoal(rows *sql.Rows) (*Goal, error) {
+	goal := &Goal{}
+	err := rows.Scan(&goal.DbID, &goal.CreatedAt, &goal.UpdatedAt, &goal.Status,
+		&goal.ID, &goal.Name, &goal.Tag, &goal.EventType, &goal.Metric, &goal.Target, &goal.Period, &goal.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+// findGoal finds an active goal, nil if there is none. With forUpdate, its row stays locked till the transaction ends.
+func (dbStuff *dbStuff) findGoal(ctx context.Context, goalID string, forUpdate bool) (*Goal, error) {
+	fn := "findGoal"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	query := queryGetGoal
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := dbStuff.query(ctx, query, goalID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	goal, err := scanGoal(rows)
+	if err != nil {
+		return nil, deepError.New(fn, "scan", err)
+	}
+
+	return goal, nil
+}
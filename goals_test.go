@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestValidateGoal(t *testing.T) {
+	fn := "TestValidateGoal"
+
+	valid := Goal{Name: "deep work", Tag: "deep_work", Metric: goalMetricDuration, Target: 36000, Period: goalPeriodWeek, TimeZone: "Asia/Kolkata"}
+	util.Test.HandleIfTestError(t, validateGoal(&valid), fn)
+
+	tests := []struct {
+		name   string
+		change func(goal *Goal)
+	}{
+		{"no name", func(goal *Goal) { goal.Name = "" }},
+		{"no target", func(goal *Goal) { goal.Tag = "" }},
+		{"tag and type", func(goal *Goal) { goal.EventType = eventTypeStart }},
+		{"duration of distractions", func(goal *Goal) { goal.Tag, goal.EventType = "", "distraction" }},
+		{"metric", func(goal *Goal) { goal.Metric = "sum" }},
+		{"zero target", func(goal *Goal) { goal.Target = 0 }},
+		{"period", func(goal *Goal) { goal.Period = "year" }},
+		{"time zone", func(goal *Goal) { goal.TimeZone = "Mars/Olympus" }},
+	}
+
+	for _, test := range tests {
+		goal := valid
+		test.change(&goal)
+		util.Test.AssertEquals(t, true, validateGoal(&goal) != nil, fn+": Goal accepted with "+test.name)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	fn := "TestPeriodStart"
+
+	at := time.Date(2020, 3, 8, 15, 4, 5, 0, time.UTC) // a sunday
+	util.Test.AssertEquals(t, time.Date(2020, 3, 8, 0, 0, 0, 0, time.UTC), periodStart(at, goalPeriodDay), fn+": Wrong day")
+	util.Test.AssertEquals(t, time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), periodStart(at, goalPeriodWeek), fn+": Wrong week")
+	util.Test.AssertEquals(t, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), periodStart(at, goalPeriodMonth), fn+": Wrong month")
+	util.Test.AssertEquals(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), addPeriods(periodStart(at, goalPeriodMonth), goalPeriodMonth, -2),
+		fn+": Wrong month before")
+}
+
+func TestComputeGoalProgress(t *testing.T) {
+	fn := "TestComputeGoalProgress"
+	ctx := context.Background()
+
+	handler := &TestEventHandler{}
+	add := func(eventType, title, tag string, at time.Time) string {
+		event := &Event{Title: title, UserCreatedAt: at, Type: &EventType{Value: eventType}}
+		if tag != "" {
+			event.Tags = []*EventTag{{Value: tag}}
+		}
+		eventID, err := handler.CreateEvent(ctx, event)
+		util.Test.HandleIfTestError(t, err, fn)
+		return eventID
+	}
+	at := func(day, hour, minute int) time.Time { return time.Date(2020, 1, day, hour, minute, 0, 0, time.UTC) }
+
+	// a week before, under target, where an end of another title or without the tag doesn't end the session
+	add(eventTypeStart, "write", "deep_work", at(1, 10, 0))
+	add(eventTypeEnd, "read", "deep_work", at(1, 10, 10))
+	add(eventTypeEnd, "write", "", at(1, 10, 20))
+	add(eventTypeEnd, "write", "deep_work", at(1, 11, 0))
+	// last week, over target, with the last session running past midnight into this week in Kolkata
+	add(eventTypeStart, "write", "deep_work", at(7, 10, 0))
+	add(eventTypeEnd, "write", "deep_work", at(7, 12, 30))
+	add(eventTypeStart, "write", "deep_work", at(12, 17, 30))
+	add(eventTypeEnd, "write", "deep_work", at(12, 19, 30))
+	// this week, where the end closing the session isn't the first one after it, and of the last two sessions only
+	// the first gets the end after both, the second still going
+	startID := add(eventTypeStart, "write", "deep_work", at(14, 10, 0))
+	add(eventTypeStart, "write", "", at(14, 10, 5))
+	add(eventTypeEnd, "write", "deep_work", at(14, 10, 15))
+	endID := add(eventTypeEnd, "write", "deep_work", at(14, 10, 30))
+	_, err := handler.CreateEventLink(ctx, endID, linkTypeCloses, startID)
+	util.Test.HandleIfTestError(t, err, fn)
+	add(eventTypeStart, "write", "deep_work", at(15, 11, 0))
+	add(eventTypeStart, "write", "deep_work", at(15, 11, 30))
+	add(eventTypeEnd, "write", "deep_work", at(15, 11, 45))
+
+	now := at(15, 12, 0)
+	goal := &Goal{Name: "deep work", Tag: "deep_work", Metric: goalMetricDuration, Target: 2 * 3600, Period: goalPeriodWeek, TimeZone: "Asia/Kolkata"}
+	progress, err := computeGoalProgress(ctx, handler, goal, 3, now)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	kolkata, err := loadLocation("Asia/Kolkata")
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 3, len(progress.Periods), fn+": Wrong periods")
+	util.Test.AssertEquals(t, true, progress.Periods[2].Start.Equal(time.Date(2020, 1, 13, 0, 0, 0, 0, kolkata)), fn+": Wrong current period")
+	util.Test.AssertEquals(t, true, progress.Periods[2].Current, fn+": Last period not current")
+
+	values := make([]float64, 0)
+	for _, period := range progress.Periods {
+		values = append(values, period.Value/3600)
+	}
+	util.Test.AssertEquals(t, []float64{1, 3.5, 2.75}, values, fn+": Wrong hours")
+	util.Test.AssertEquals(t, 2, progress.CurrentStreak, fn+": Wrong current streak")
+	util.Test.AssertEquals(t, 2, progress.LongestStreak, fn+": Wrong longest streak")
+
+	// a current period not met yet doesn't break the streak
+	add("meeting", "standup", "", at(12, 9, 0))
+	add("meeting", "standup", "", at(13, 9, 0))
+	add("meeting", "standup", "", at(14, 9, 0))
+	goal = &Goal{Name: "meet", EventType: "meeting", Metric: goalMetricCount, Target: 1, Period: goalPeriodDay}
+	progress, err = computeGoalProgress(ctx, handler, goal, 5, now)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	values = make([]float64, 0)
+	for _, period := range progress.Periods {
+		values = append(values, period.Value)
+	}
+	util.Test.AssertEquals(t, []float64{0, 1, 1, 1, 0}, values, fn+": Wrong counts")
+	util.Test.AssertEquals(t, 3, progress.CurrentStreak, fn+": Wrong current streak for counts")
+	util.Test.AssertEquals(t, 3, progress.LongestStreak, fn+": Wrong longest streak for counts")
+}
+
+func TestGoalRoutes(t *testing.T) {
+	fn := "TestGoalRoutes"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeCreateGoal, CreateGoalHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetGoal, GetGoalHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetGoal, DeleteGoalHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeGoalProgress, GetGoalProgressHandler(env)).Methods(http.MethodGet)
+
+	rr := serveTest(t, fn, router, http.MethodPost, routeCreateGoal, `{"name": "run", "tag": "run", "event_type": "start", "metric": "count", "target": 3, "period": "week"}`)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Goal with tag and type accepted")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeCreateGoal, `{"name": "run", "tag": "run", "metric": "count", "target": 3, "period": "week"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeGoalProgressF, "goal-1")+"?periods=4", "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for progress")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"current_streak":0`), fn+": No streak in progress")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeGoalProgressF, "goal-1")+"?periods=0", "")
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Zero periods accepted")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeGetGoalF, "goal-1"), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Goal not deleted")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeGoalProgressF, "goal-1"), "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Progress of a deleted goal")
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const maxGraphQLDepth = 10
+
+const ctxKeyEventsLoader contextKey = "eventsLoader"
+
+const graphQLSchema = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+
+	scalar Time
+
+	type Query {
+		# events that aren't deleted, ordered by their time
+		events(type: String, tags: [String!], from: Time, to: Time, search: String, limit: Int = 100): [Event!]!
+		event(id: ID!): Event
+		types: [EventType!]!
+		tags: [EventTag!]!
+	}
+
+	type Mutation {
+		createEvent(input: EventInput!): Event!
+	}
+
+	type Event {
+		id: ID!
+		title: String!
+		note: String!
+		createdAt: Time!
+		# IANA time zone the event happened in, empty if unknown
+		timeZone: String!
+		# seconds east of UTC of the local time the event happened at
+		utcOffset: Int!
+		type: EventType
+		tags: [EventTag!]!
+		# for a start event, the seconds till the first end event after it, null while there is none
+		durationSeconds: Float
+	}
+
+	type EventType {
+		value: String!
+	}
+
+	type EventTag {
+		value: String!
+	}
+
+	input EventInput {
+		title: String!
+		note: String
+		createdAt: Time
+		timeZone: String
+		type: String!
+		tags: [String!]
+	}
+`
+
+// GraphQLHandler serves queries and mutations on events, with a fresh eventsLoader for every request
+func GraphQLHandler(env *env) http.Handler {
+	schema := graphql.MustParseSchema(graphQLSchema, &graphQLResolver{env: env},
+		graphql.UseFieldResolvers(),
+		graphql.MaxDepth(maxGraphQLDepth),
+	)
+	handler := &relay.Handler{Schema: schema}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKeyEventsLoader, newEventsLoader(env.EventsHandler))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// eventsLoader batches what is computed for many events of a single request. The events listed are
+// registered with it, and the first event asking for its duration has them computed for all of them at once.
+type eventsLoader struct {
+	handler IEventsHandler
+
+	mu        sync.Mutex
+	events    []*Event
+	durations map[string]*float64
+	loaded    bool
+	err       error
+}
+
+func newEventsLoader(handler IEventsHandler) *eventsLoader {
+	return &eventsLoader{handler: handler}
+}
+
+func eventsLoaderFromContext(ctx context.Context) *eventsLoader {
+	loader, ok := ctx.Value(ctxKeyEventsLoader).(*eventsLoader)
+	if !ok {
+		return nil
+	}
+
+	return loader
+}
+
+// prime registers events whose durations may be asked for
+func (loader *eventsLoader) prime(events []*Event) {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	loader.events = append(loader.events, events...)
+	loader.loaded = false
+}
+
+// duration returns the seconds from a start event till the first end event after it
+func (loader *eventsLoader) duration(ctx context.Context, event *Event) (*float64, error) {
+	fn := "duration"
+
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	if !loader.loaded {
+		loader.durations, loader.err = loadDurations(ctx, loader.handler, loader.events)
+		loader.loaded = true
+	}
+	if loader.err != nil {
+		return nil, deepError.New(fn, "load durations", loader.err)
+	}
+
+	return loader.durations[event.ID], nil
+}
+
+// loadDurations pairs every start event with the first end event after it, with one query for all of them
+func loadDurations(ctx context.Context, handler IEventsHandler, events []*Event) (map[string]*float64, error) {
+	fn := "loadDurations"
+
+	durations := make(map[string]*float64)
+	var earliest time.Time
+	for _, event := range events {
+		if isEventType(event, eventTypeStart) && (earliest.IsZero() || event.UserCreatedAt.Before(earliest)) {
+			earliest = event.UserCreatedAt
+		}
+	}
+	if earliest.IsZero() {
+		return durations, nil
+	}
+
+	ends, err := handler.FindEvents(ctx, EventFilter{Type: eventTypeEnd, From: earliest})
+	if err != nil {
+		return nil, deepError.New(fn, "find end events", err)
+	}
+	sort.SliceStable(ends, func(i, j int) bool {
+		return ends[i].UserCreatedAt.Before(ends[j].UserCreatedAt)
+	})
+
+	for _, event := range events {
+		if !isEventType(event, eventTypeStart) {
+			continue
+		}
+
+		next := sort.Search(len(ends), func(i int) bool {
+			return !ends[i].UserCreatedAt.Before(event.UserCreatedAt)
+		})
+		if next < len(ends) {
+			seconds := ends[next].UserCreatedAt.Sub(event.UserCreatedAt).Seconds()
+			durations[event.ID] = &seconds
+		}
+	}
+
+	return durations, nil
+}
+
+func isEventType(event *Event, value string) bool {
+	return event.Type != nil && event.Type.Value == value
+}
+
+type graphQLResolver struct {
+	env *env
+}
+
+func (resolver *graphQLResolver) Events(ctx context.Context, args struct {
+	Type   *string
+	Tags   *[]string
+	From   *graphql.Time
+	To     *graphql.Time
+	Search *string
+	Limit  int32
+}) ([]*eventResolver, error) {
+	if args.Limit <= 0 || args.Limit > maxFindEventsLimit {
+		return nil, newBadRequestError(fmt.Sprintf("limit should be between 1 and %d", maxFindEventsLimit))
+	}
+
+	filter := EventFilter{Limit: int(args.Limit)}
+	if args.Type != nil {
+		filter.Type = *args.Type
+	}
+	if args.Tags != nil {
+		filter.Tags = *args.Tags
+	}
+	if args.From != nil {
+		filter.From = args.From.Time
+	}
+	if args.To != nil {
+		filter.To = args.To.Time
+	}
+	if args.Search != nil {
+		filter.Search = *args.Search
+	}
+
+	events, err := resolver.env.EventsHandler.FindEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventResolvers(ctx, events), nil
+}
+
+func (resolver *graphQLResolver) Event(ctx context.Context, args struct{ ID graphql.ID }) (*eventResolver, error) {
+	event, err := resolver.env.EventsHandler.GetEvent(ctx, string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, nil
+	}
+
+	return newEventResolvers(ctx, []*Event{event})[0], nil
+}
+
+func (resolver *graphQLResolver) Types(ctx context.Context) ([]*EventType, error) {
+	return resolver.env.EventsHandler.GetEventTypes(ctx)
+}
+
+func (resolver *graphQLResolver) Tags(ctx context.Context) ([]*EventTag, error) {
+	return resolver.env.EventsHandler.GetEventTags(ctx)
+}
+
+// EventInput is the input for creating an event
+type EventInput struct {
+	Title     string
+	Note      *string
+	CreatedAt *graphql.Time
+	TimeZone  *string
+	Type      string
+	Tags      *[]string
+}
+
+func (resolver *graphQLResolver) CreateEvent(ctx context.Context, args struct{ Input EventInput }) (*eventResolver, error) {
+	event := &Event{
+		Title:         args.Input.Title,
+		UserCreatedAt: time.Now().UTC(),
+		Type:          &EventType{Value: args.Input.Type},
+		Tags:          make([]*EventTag, 0),
+	}
+	if args.Input.Note != nil {
+		event.Note = *args.Input.Note
+	}
+	if args.Input.CreatedAt != nil {
+		event.UserCreatedAt = args.Input.CreatedAt.Time
+	}
+	if args.Input.TimeZone != nil {
+		event.TimeZone = *args.Input.TimeZone
+	}
+	if args.Input.Tags != nil {
+		for _, tag := range *args.Input.Tags {
+			event.Tags = append(event.Tags, &EventTag{Value: tag})
+		}
+	}
+
+	err := validateEvent(event)
+	if err != nil {
+		return nil, newBadRequestError(err.Error())
+	}
+
+	_, err = resolver.env.EventsHandler.CreateEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventResolvers(ctx, []*Event{event})[0], nil
+}
+
+type eventResolver struct {
+	event *Event
+}
+
+func newEventResolvers(ctx context.Context, events []*Event) []*eventResolver {
+	if loader := eventsLoaderFromContext(ctx); loader != nil {
+		loader.prime(events)
+	}
+
+	resolvers := make([]*eventResolver, 0)
+	for _, event := range events {
+		resolvers = append(resolvers, &eventResolver{event: event})
+	}
+
+	return resolvers
+}
+
+func (resolver *eventResolver) ID() graphql.ID {
+	return graphql.ID(resolver.event.ID)
+}
+
+func (resolver *eventResolver) Title() string {
+	return resolver.event.Title
+}
+
+func (resolver *eventResolver) Note() string {
+	return resolver.event.Note
+}
+
+func (resolver *eventResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: resolver.event.UserCreatedAt}
+}
+
+func (resolver *eventResolver) TimeZone() string {
+	return resolver.event.TimeZone
+}
+
+func (resolver *eventResolver) UTCOffset() int32 {
+	return int32(resolver.event.UTCOffset)
+}
+
+func (resolver *eventResolver) Type() *EventType {
+	return resolver.event.Type
+}
+
+func (resolver *eventResolver) Tags() []*EventTag {
+	if resolver.event.Tags == nil {
+		return make([]*EventTag, 0)
+	}
+
+	return resolver.event.Tags
+}
+
+func (resolver *eventResolver) DurationSeconds(ctx context.Context) (*float64, error) {
+	if !isEventType(resolver.event, eventTypeStart) {
+		return nil, nil
+	}
+
+	loader := eventsLoaderFromContext(ctx)
+	if loader == nil {
+		loader = newEventsLoader(nil)
+		loader.prime([]*Event{resolver.event})
+	}
+
+	return loader.duration(ctx, resolver.event)
+}
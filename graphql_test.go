@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func doGraphQL(t *testing.T, handler http.Handler, query string, variables map[string]interface{}) string {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	util.Test.HandleIfTestError(t, err, "doGraphQL")
+
+	req, err := http.NewRequest(http.MethodPost, routeGraphQL, strings.NewReader(string(body)))
+	util.Test.HandleIfTestError(t, err, "doGraphQL")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, "doGraphQL: Wrong Status Code")
+
+	return rr.Body.String()
+}
+
+func TestGraphQLEvents(t *testing.T) {
+	fn := "TestGraphQLEvents"
+
+	eventsHandler := &TestEventHandler{}
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, evt := range []*Event{
+		{Title: "work", UserCreatedAt: start, Type: &EventType{Value: eventTypeStart}, Tags: []*EventTag{{Value: "office"}}},
+		{Title: "work done", UserCreatedAt: start.Add(90 * time.Minute), Type: &EventType{Value: eventTypeEnd}},
+		{Title: "run", UserCreatedAt: start.Add(2 * time.Hour), Type: &EventType{Value: eventTypeStart}, Tags: []*EventTag{{Value: "health"}}},
+	} {
+		_, err := eventsHandler.CreateEvent(context.Background(), evt)
+		util.Test.HandleIfTestError(t, err, fn)
+	}
+
+	handler := GraphQLHandler(&env{EventsHandler: eventsHandler})
+
+	query := `
+		query($type: String) {
+			events(type: $type) { id title createdAt type { value } tags { value } durationSeconds }
+		}`
+	expected := `
+		{
+			"data": {
+				"events": [
+					{"id": "1", "title": "work", "createdAt": "2020-01-01T10:00:00Z", "type": {"value": "start"}, "tags": [{"value": "office"}], "durationSeconds": 5400},
+					{"id": "3", "title": "run", "createdAt": "2020-01-01T12:00:00Z", "type": {"value": "start"}, "tags": [{"value": "health"}], "durationSeconds": null}
+				]
+			}
+		}`
+	util.Test.AssertJSONEquals(t, expected, doGraphQL(t, handler, query, map[string]interface{}{"type": "start"}), fn+": Wrong events")
+
+	query = `{ events(tags: ["health"]) { title } }`
+	expected = `{"data": {"events": [{"title": "run"}]}}`
+	util.Test.AssertJSONEquals(t, expected, doGraphQL(t, handler, query, nil), fn+": Wrong events by tag")
+
+	query = `{ events(limit: 5000) { title } }`
+	body := doGraphQL(t, handler, query, nil)
+	util.Test.AssertEquals(t, true, strings.Contains(body, "limit should be between 1 and 1000"), fn+": Limit not enforced")
+}
+
+func TestGraphQLCreateEvent(t *testing.T) {
+	fn := "TestGraphQLCreateEvent"
+
+	eventsHandler := &TestEventHandler{}
+	handler := GraphQLHandler(&env{EventsHandler: eventsHandler})
+
+	query := `
+		mutation($input: EventInput!) {
+			createEvent(input: $input) { id title note timeZone utcOffset type { value } tags { value } }
+		}`
+	input := map[string]interface{}{
+		"title":     "lunch",
+		"note":      "with team",
+		"createdAt": "2020-01-01T13:00:00Z",
+		"timeZone":  "Asia/Kolkata",
+		"type":      eventTypeStart,
+		"tags":      []string{"food"},
+	}
+	expected := `
+		{
+			"data": {
+				"createEvent": {"id": "1", "title": "lunch", "note": "with team", "timeZone": "Asia/Kolkata", "utcOffset": 19800, "type": {"value": "start"}, "tags": [{"value": "food"}]}
+			}
+		}`
+	util.Test.AssertJSONEquals(t, expected, doGraphQL(t, handler, query, map[string]interface{}{"input": input}), fn+": Wrong event")
+	util.Test.AssertEquals(t, 1, len(eventsHandler.events), fn+": Event not created")
+
+	input["type"] = ""
+	body := doGraphQL(t, handler, query, map[string]interface{}{"input": input})
+	util.Test.AssertEquals(t, true, strings.Contains(body, "event type is required"), fn+": Invalid event created")
+	util.Test.AssertEquals(t, 1, len(eventsHandler.events), fn+": Invalid event created")
+}
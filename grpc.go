@@ -0,0 +1,531 @@
+package main
+
+//go:generate protoc -I proto --go_out=eventtrackerpb --go_opt=paths=source_relative --go-grpc_out=eventtrackerpb --go-grpc_opt=paths=source_relative eventtracker.proto
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/EventTracker-api/eventtrackerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// metadataRequestID is the grpc counterpart of the request id header, metadata keys being lower case
+var metadataRequestID = strings.ToLower(headerRequestID)
+
+// grpcServer serves the EventTracker service on top of the same IEventsHandler as the REST routes
+type grpcServer struct {
+	eventtrackerpb.UnimplementedEventTrackerServer
+	env *env
+}
+
+// newGRPCServer creates a grpc server with the service registered, and interceptors doing for every call
+// what the http middleware does for every request
+func newGRPCServer(env *env, requestTimeout time.Duration) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{grpcUnaryInterceptor(env.Logger, requestTimeout)}
+	stream := []grpc.StreamServerInterceptor{grpcStreamInterceptor(env.Logger, requestTimeout)}
+	if env.Authenticator != nil {
+		unary = append(unary, grpcAuthUnaryInterceptor(env.Authenticator))
+		stream = append(stream, grpcAuthStreamInterceptor(env.Authenticator))
+	}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	eventtrackerpb.RegisterEventTrackerServer(srv, &grpcServer{env: env})
+
+	return srv
+}
+
+// runGRPCServer serves on listener till ctx is done, then stops gracefully, cutting off calls still
+// running after shutdownTimeout
+func runGRPCServer(ctx context.Context, srv *grpc.Server, listener net.Listener, shutdownTimeout time.Duration, logger *slog.Logger) {
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting grpc server", "url", listener.Addr().String())
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			logger.Error("grpc server stopped with error", errorAttr(err))
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		logger.Error("grpc server shutdown incomplete, cancelling calls")
+		srv.Stop()
+	}
+}
+
+// grpcContext sets up the context of a call like RequestIDMiddleware and ActorMiddleware do for a request
+func grpcContext(ctx context.Context, logger *slog.Logger) context.Context {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(metadataRequestID); len(values) > 0 {
+			requestID = strings.TrimSpace(values[0])
+		}
+	}
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = uuid.New().String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, requestID))
+
+	actor := actorAnonymous
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		actor = actorAnonymous + "@" + host
+	}
+
+	ctx = context.WithValue(ctx, ctxKeyRequestID, requestID)
+	ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("request_id", requestID))
+	ctx = context.WithValue(ctx, ctxKeyActor, actor)
+
+	return ctx
+}
+
+func grpcUnaryInterceptor(logger *slog.Logger, timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = grpcContext(ctx, logger)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		resp, err := handler(ctx, req)
+		err = grpcError(ctx, err)
+
+		loggerFromContext(ctx).Info("call served", "method", info.FullMethod, "code", status.Code(err).String(), "duration_ms", time.Since(start).Milliseconds())
+		return resp, err
+	}
+}
+
+// grpcServerStream lets a stream handler see the context set up for it
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *grpcServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func grpcStreamInterceptor(logger *slog.Logger, timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := grpcContext(ss.Context(), logger)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		err := handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+		err = grpcError(ctx, err)
+
+		loggerFromContext(ctx).Info("call served", "method", info.FullMethod, "code", status.Code(err).String(), "duration_ms", time.Since(start).Milliseconds())
+		return err
+	}
+}
+
+// grpcAuthenticate is AuthMiddleware for a call, with the token in the authorization metadata
+func grpcAuthenticate(ctx context.Context, authenticator Authenticator) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("authorization")) == 0 {
+		return ctx, nil
+	}
+
+	token, ok := strings.CutPrefix(md.Get("authorization")[0], "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return ctx, nil
+	}
+
+	username, err := authenticator.Authenticate(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return ctx, err
+	}
+	if username == "" {
+		return ctx, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	return context.WithValue(ctx, ctxKeyActor, username), nil
+}
+
+func grpcAuthUnaryInterceptor(authenticator Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := grpcAuthenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func grpcAuthStreamInterceptor(authenticator Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthenticate(ss.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// grpcError maps errors like handleHTTPError does, to grpc status codes
+func grpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	logger := loggerFromContext(ctx)
+	reqErr, isReqErr := asRequestError(err)
+	switch {
+	case isReqErr:
+		code := codes.InvalidArgument
+		switch reqErr.Code {
+		case errCodeNotFound:
+			code = codes.NotFound
+		case errCodeForbidden:
+			code = codes.PermissionDenied
+		}
+		return status.Error(code, reqErr.Message)
+
+	case causedBy(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		logger.Warn("call cancelled", errorAttr(err))
+		return status.Error(codes.Canceled, "call cancelled")
+
+	case causedBy(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		logger.Error("call timed out", errorAttr(err))
+		return status.Error(codes.DeadlineExceeded, "call timed out")
+	}
+
+	logger.Error("grpc error occurred", errorAttr(err))
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (server *grpcServer) ListEvents(req *eventtrackerpb.ListEventsRequest, stream eventtrackerpb.EventTracker_ListEventsServer) error {
+	events, err := server.env.EventsHandler.GetAllEvents(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		err = stream.Send(eventToProto(event))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (server *grpcServer) GetEvent(ctx context.Context, req *eventtrackerpb.GetEventRequest) (*eventtrackerpb.Event, error) {
+	event, err := server.env.EventsHandler.GetEvent(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, newNotFoundError("Event with ID not found")
+	}
+
+	return eventToProto(event), nil
+}
+
+func (server *grpcServer) CreateEvent(ctx context.Context, req *eventtrackerpb.CreateEventRequest) (*eventtrackerpb.CreateEventResponse, error) {
+	if req.GetEvent() == nil {
+		return nil, newBadRequestError("event is required")
+	}
+
+	event := eventFromProto(req.GetEvent())
+	err := validateEvent(event)
+	if err != nil {
+		return nil, newBadRequestError(err.Error())
+	}
+
+	eventID, err := server.env.EventsHandler.CreateEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventtrackerpb.CreateEventResponse{Id: eventID}, nil
+}
+
+func (server *grpcServer) GetEventHistory(ctx context.Context, req *eventtrackerpb.GetEventHistoryRequest) (*eventtrackerpb.GetEventHistoryResponse, error) {
+	history, err := server.env.EventsHandler.GetEventHistory(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, newNotFoundError("Event with ID not found")
+	}
+
+	resp := &eventtrackerpb.GetEventHistoryResponse{}
+	for _, entry := range history {
+		protoEntry, err := auditEntryToProto(entry)
+		if err != nil {
+			return nil, err
+		}
+		resp.History = append(resp.History, protoEntry)
+	}
+
+	return resp, nil
+}
+
+func (server *grpcServer) ListEventRevisions(ctx context.Context, req *eventtrackerpb.ListEventRevisionsRequest) (*eventtrackerpb.ListEventRevisionsResponse, error) {
+	revisions, err := server.env.EventsHandler.GetEventRevisions(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if revisions == nil {
+		return nil, newNotFoundError("Event with ID not found")
+	}
+
+	resp := &eventtrackerpb.ListEventRevisionsResponse{}
+	for _, revision := range revisions {
+		resp.Revisions = append(resp.Revisions, revisionToProto(revision))
+	}
+
+	return resp, nil
+}
+
+func (server *grpcServer) GetEventRevision(ctx context.Context, req *eventtrackerpb.GetEventRevisionRequest) (*eventtrackerpb.EventRevision, error) {
+	revision, err := server.env.EventsHandler.GetEventRevision(ctx, req.GetId(), int(req.GetRevision()))
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, newNotFoundError("Revision not found")
+	}
+
+	return revisionToProto(revision), nil
+}
+
+func (server *grpcServer) RestoreEventRevision(ctx context.Context, req *eventtrackerpb.RestoreEventRevisionRequest) (*eventtrackerpb.Event, error) {
+	event, err := server.env.EventsHandler.RestoreEventRevision(ctx, req.GetId(), int(req.GetRevision()))
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, newNotFoundError("Revision not found")
+	}
+
+	return eventToProto(event), nil
+}
+
+func (server *grpcServer) GetChanges(ctx context.Context, req *eventtrackerpb.GetChangesRequest) (*eventtrackerpb.GetChangesResponse, error) {
+	since, err := parseSyncToken(req.GetSince())
+	if err != nil {
+		return nil, newBadRequestError(err.Error())
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultSyncLimit
+	}
+	if limit < 0 || limit > maxSyncLimit {
+		return nil, newBadRequestError("limit should be between 1 and " + strconv.Itoa(maxSyncLimit))
+	}
+
+	changes, err := server.env.EventsHandler.GetChanges(ctx, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &eventtrackerpb.GetChangesResponse{Next: changes.Next, HasMore: changes.HasMore}
+	for _, event := range changes.Created {
+		resp.Created = append(resp.Created, syncEventToProto(event))
+	}
+	for _, event := range changes.Updated {
+		resp.Updated = append(resp.Updated, syncEventToProto(event))
+	}
+	for _, tombstone := range changes.Deleted {
+		resp.Deleted = append(resp.Deleted, &eventtrackerpb.Tombstone{Id: tombstone.ID, DeletedAt: timestamppb.New(tombstone.DeletedAt)})
+	}
+
+	return resp, nil
+}
+
+func (server *grpcServer) PushChanges(ctx context.Context, req *eventtrackerpb.PushChangesRequest) (*eventtrackerpb.PushChangesResponse, error) {
+	changes := make([]*EventChange, 0)
+	for index, protoChange := range req.GetChanges() {
+		change := &EventChange{Deleted: protoChange.GetDeleted()}
+		if protoChange.GetEvent() != nil {
+			change.Event = eventFromProto(protoChange.GetEvent())
+		}
+		if protoChange.GetChangedAt() != nil {
+			change.ChangedAt = protoChange.GetChangedAt().AsTime()
+		}
+
+		err := validateEventChange(change)
+		if err != nil {
+			return nil, newBadRequestError("change " + strconv.Itoa(index) + ": " + err.Error())
+		}
+		changes = append(changes, change)
+	}
+
+	result, err := server.env.EventsHandler.PushChanges(ctx, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &eventtrackerpb.PushChangesResponse{Applied: result.Applied}
+	for _, conflict := range result.Conflicts {
+		resp.Conflicts = append(resp.Conflicts, &eventtrackerpb.SyncConflict{
+			EventId: conflict.EventID,
+			Reason:  conflict.Reason,
+			Server:  syncEventToProto(conflict.Server),
+		})
+	}
+
+	return resp, nil
+}
+
+func (server *grpcServer) ApplyRetention(ctx context.Context, req *eventtrackerpb.ApplyRetentionRequest) (*eventtrackerpb.RetentionReport, error) {
+	if actorFromContext(ctx) != actorAdmin {
+		return nil, newForbiddenError("only the admin user can apply retention")
+	}
+
+	report, err := applyRetention(ctx, server.env.EventsHandler, server.env.Blobs, server.env.Retention, req.GetDryRun())
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventtrackerpb.RetentionReport{
+		DryRun:         report.DryRun,
+		PurgedEvents:   report.PurgedEvents,
+		PurgedTags:     report.PurgedTags,
+		ArchivedEvents: report.ArchivedEvents,
+	}, nil
+}
+
+func eventToProto(event *Event) *eventtrackerpb.Event {
+	if event == nil {
+		return nil
+	}
+
+	protoEvent := &eventtrackerpb.Event{
+		Id:        event.ID,
+		Title:     event.Title,
+		Note:      event.Note,
+		CreatedAt: timestamppb.New(event.UserCreatedAt),
+	}
+	if event.Type != nil {
+		protoEvent.Type = &eventtrackerpb.EventType{Value: event.Type.Value}
+	}
+	for _, tag := range event.Tags {
+		protoEvent.Tags = append(protoEvent.Tags, &eventtrackerpb.EventTag{Value: tag.Value})
+	}
+
+	return protoEvent
+}
+
+func eventFromProto(protoEvent *eventtrackerpb.Event) *Event {
+	event := &Event{
+		ID:    protoEvent.GetId(),
+		Title: protoEvent.GetTitle(),
+		Note:  protoEvent.GetNote(),
+		Tags:  make([]*EventTag, 0),
+	}
+	if protoEvent.GetCreatedAt() != nil {
+		event.UserCreatedAt = protoEvent.GetCreatedAt().AsTime()
+	}
+	if protoEvent.GetType() != nil {
+		event.Type = &EventType{Value: protoEvent.GetType().GetValue()}
+	}
+	for _, tag := range protoEvent.GetTags() {
+		event.Tags = append(event.Tags, &EventTag{Value: tag.GetValue()})
+	}
+
+	return event
+}
+
+func syncEventToProto(event *SyncEvent) *eventtrackerpb.SyncEvent {
+	if event == nil {
+		return nil
+	}
+
+	return &eventtrackerpb.SyncEvent{
+		Event:     eventToProto(event.Event),
+		UpdatedAt: timestamppb.New(event.UpdatedAt),
+		Deleted:   event.Deleted,
+	}
+}
+
+func revisionToProto(revision *EventRevision) *eventtrackerpb.EventRevision {
+	snapshot := revision.Event
+	return &eventtrackerpb.EventRevision{
+		Revision: int32(revision.Revision),
+		Event: &eventtrackerpb.EventSnapshot{
+			Title:     snapshot.Title,
+			Note:      snapshot.Note,
+			CreatedAt: timestamppb.New(snapshot.UserCreatedAt),
+			Type:      snapshot.Type,
+			Tags:      snapshot.Tags,
+			Status:    snapshot.Status,
+		},
+		Actor:     revision.Actor,
+		RevisedAt: timestamppb.New(revision.RevisedAt),
+	}
+}
+
+func auditEntryToProto(entry *AuditEntry) (*eventtrackerpb.AuditEntry, error) {
+	protoEntry := &eventtrackerpb.AuditEntry{
+		EntityType: entry.EntityType,
+		EntityId:   entry.EntityID,
+		Action:     entry.Action,
+		Actor:      entry.Actor,
+		RequestId:  entry.RequestID,
+		Changes:    make(map[string]*eventtrackerpb.AuditChange),
+		At:         timestamppb.New(entry.At),
+	}
+
+	for field, change := range entry.Changes {
+		before, err := structpb.NewValue(change.Before)
+		if err != nil {
+			return nil, err
+		}
+
+		after, err := structpb.NewValue(change.After)
+		if err != nil {
+			return nil, err
+		}
+
+		protoEntry.Changes[field] = &eventtrackerpb.AuditChange{Before: before, After: after}
+	}
+
+	return protoEntry, nil
+}
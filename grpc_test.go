@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jforcode/EventTracker-api/eventtrackerpb"
+	"github.com/jforcode/Go-Util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTestGRPCClient(t *testing.T, env *env) eventtrackerpb.EventTrackerClient {
+	listener := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(env, time.Second)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	util.Test.HandleIfTestError(t, err, "newTestGRPCClient")
+	t.Cleanup(func() { conn.Close() })
+
+	return eventtrackerpb.NewEventTrackerClient(conn)
+}
+
+func TestGRPCEvents(t *testing.T) {
+	fn := "TestGRPCEvents"
+
+	client := newTestGRPCClient(t, &env{
+		EventsHandler: &TestEventHandler{},
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	ctx := context.Background()
+
+	event := eventToProto(GetTestEvent())
+	created, err := client.CreateEvent(ctx, &eventtrackerpb.CreateEventRequest{Event: event})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	got, err := client.GetEvent(ctx, &eventtrackerpb.GetEventRequest{Id: created.GetId()})
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, event.GetTitle(), got.GetTitle(), fn+": Wrong title")
+	util.Test.AssertEquals(t, event.GetCreatedAt().AsTime(), got.GetCreatedAt().AsTime(), fn+": Wrong created at")
+	util.Test.AssertEquals(t, 2, len(got.GetTags()), fn+": Wrong tags")
+
+	stream, err := client.ListEvents(ctx, &eventtrackerpb.ListEventsRequest{})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	count := 0
+	for {
+		_, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		util.Test.HandleIfTestError(t, err, fn)
+		count++
+	}
+	util.Test.AssertEquals(t, 1, count, fn+": Wrong number of streamed events")
+
+	_, err = client.CreateEvent(ctx, &eventtrackerpb.CreateEventRequest{Event: &eventtrackerpb.Event{Title: "No type"}})
+	util.Test.AssertEquals(t, codes.InvalidArgument, status.Code(err), fn+": Wrong code for missing type")
+
+	_, err = client.GetEventRevision(ctx, &eventtrackerpb.GetEventRevisionRequest{Id: created.GetId(), Revision: 10})
+	util.Test.AssertEquals(t, codes.NotFound, status.Code(err), fn+": Wrong code for unknown revision")
+
+	push, err := client.PushChanges(ctx, &eventtrackerpb.PushChangesRequest{Changes: []*eventtrackerpb.EventChange{
+		{Event: &eventtrackerpb.Event{Id: created.GetId()}, Deleted: true, ChangedAt: timestamppb.Now()},
+	}})
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []string{created.GetId()}, push.GetApplied(), fn+": Change not applied")
+
+	_, err = client.ApplyRetention(ctx, &eventtrackerpb.ApplyRetentionRequest{DryRun: true})
+	util.Test.AssertEquals(t, codes.PermissionDenied, status.Code(err), fn+": Wrong code for retention without the admin user")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	dependencyStatusUp   = "up"
+	dependencyStatusDown = "down"
+
+	readinessStatusReady    = "ready"
+	readinessStatusNotReady = "not_ready"
+
+	defaultDependencyCheckTimeout = 2 * time.Second
+)
+
+var (
+	queryGetSchemaVersion = fmt.Sprintf(`
+		SELECT %s, %s
+		FROM %s`,
+		schemaVersionColVer, schemaVersionColDirty,
+		schemaVersionTableName)
+
+	errNotReady = errors.New("service not ready")
+)
+
+// DependencyChecker checks if a dependency of the api is usable
+type DependencyChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// DependencyStatus is the result of checking a single dependency
+type DependencyStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReadinessResponse represents the response to send to client, in case of a readiness check
+type ReadinessResponse struct {
+	Status       string              `json:"status"`
+	Dependencies []*DependencyStatus `json:"dependencies"`
+}
+
+// checkDependencies runs all checks, each bounded by timeout, and reports if all of them passed
+func checkDependencies(ctx context.Context, checkers []DependencyChecker, timeout time.Duration) *ReadinessResponse {
+	resp := &ReadinessResponse{
+		Status:       readinessStatusReady,
+		Dependencies: make([]*DependencyStatus, 0),
+	}
+
+	for _, checker := range checkers {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := checker.Check(checkCtx)
+		cancel()
+
+		status := &DependencyStatus{
+			Name:      checker.Name(),
+			Status:    dependencyStatusUp,
+			LatencyMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			status.Status = dependencyStatusDown
+			status.Error = err.Error()
+			resp.Status = readinessStatusNotReady
+		}
+
+		resp.Dependencies = append(resp.Dependencies, status)
+	}
+
+	return resp
+}
+
+type dbPingChecker struct {
+	db *sql.DB
+}
+
+func (checker *dbPingChecker) Name() string {
+	return "mysql"
+}
+
+func (checker *dbPingChecker) Check(ctx context.Context) error {
+	return checker.db.PingContext(ctx)
+}
+
+// schemaVersionChecker verifies that the db has all the migrations the binary was built with
+type schemaVersionChecker struct {
+	db              *sql.DB
+	expectedVersion int64
+}
+
+func (checker *schemaVersionChecker) Name() string {
+	return "schema"
+}
+
+func (checker *schemaVersionChecker) Check(ctx context.Context) error {
+	fn := "schemaVersionChecker.Check"
+
+	var version int64
+	var dirty bool
+	err := checker.db.QueryRowContext(ctx, queryGetSchemaVersion).Scan(&version, &dirty)
+	if err != nil {
+		return deepError.New(fn, "query", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+
+	if version != checker.expectedVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version, checker.expectedVersion)
+	}
+
+	return nil
+}
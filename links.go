@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityEventLink = "event_link"
+
+	// linkTypeCloses links an end event to the start event it closes, one to one
+	linkTypeCloses = "closes"
+	// linkTypeInterrupts links something, like a distraction, to the event it interrupted
+	linkTypeInterrupts = "interrupts"
+	// linkTypeRelates links any two events, like a follow up to a meeting
+	linkTypeRelates = "relates"
+)
+
+var (
+	linkTypes = []string{linkTypeCloses, linkTypeInterrupts, linkTypeRelates}
+
+	// links are only shown while both their events are active
+	queryGetEventLinks = fmt.Sprintf(`
+		SELECT L.%s, L.%s, L.%s, L.%s, L.%s, L.%s, L.%s, L.%s, F.%s, T.%s
+		FROM %s L
+		JOIN %s F ON F.%s = L.%s
+		JOIN %s T ON T.%s = L.%s
+		WHERE (L.%s IN (%s) OR L.%s IN (%s)) AND F.%s = '%s' AND T.%s = '%s'
+		ORDER BY L.%s`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus, eventLinksColID, eventLinksColType, eventLinksColFromEventID, eventLinksColToEventID,
+		eventsColID, eventsColID,
+		eventLinksTableName,
+		eventsTableName, colDbID, eventLinksColFromEventID,
+		eventsTableName, colDbID, eventLinksColToEventID,
+		eventLinksColFromEventID, "%[1]s", eventLinksColToEventID, "%[1]s", colStatus, statusActive, colStatus, statusActive,
+		colDbID)
+
+	queryGetEventLink = fmt.Sprintf(`
+		SELECT L.%s, L.%s, L.%s, L.%s, L.%s, L.%s, F.%s, T.%s, L.%s, L.%s
+		FROM %s L
+		JOIN %s F ON F.%s = L.%s
+		JOIN %s T ON T.%s = L.%s
+		WHERE L.%s = ?`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus, eventLinksColID, eventLinksColType, eventsColID, eventsColID,
+		eventLinksColFromEventID, eventLinksColToEventID,
+		eventLinksTableName,
+		eventsTableName, colDbID, eventLinksColFromEventID,
+		eventsTableName, colDbID, eventLinksColToEventID,
+		eventLinksColID)
+
+	queryLockEvent = fmt.Sprintf(`
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE E.%s = ? AND E.%s = '%s'
+		FOR UPDATE`,
+		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
+		eventsTableName,
+		eventsColID, colStatus, statusActive)
+
+	// counts the closes links of an event, either way. Those with a deleted event count too, as restoring it
+	// brings the link back.
+	queryCountClosesLinks = fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %s L
+		WHERE (L.%s = ? OR L.%s = ?) AND L.%s = '%s'`,
+		eventLinksTableName,
+		eventLinksColFromEventID, eventLinksColToEventID, eventLinksColType, linkTypeCloses)
+
+	// the events linked to an event either way, whatever their status
+	queryGetLinkedEventDbIDs = fmt.Sprintf(`
+		SELECT IF(L.%s = ?, L.%s, L.%s)
+		FROM %s L
+		WHERE L.%s = ? OR L.%s = ?`,
+		eventLinksColFromEventID, eventLinksColToEventID, eventLinksColFromEventID,
+		eventLinksTableName,
+		eventLinksColFromEventID, eventLinksColToEventID)
+
+	errLinkEventNotFound = newNotFoundError("Event with ID not found")
+	errLinkNotFound      = newNotFoundError("Link with ID not found")
+	errLinkExists        = newBadRequestError("events already have a link of this type")
+	errAlreadyClosed     = newBadRequestError("start event is already closed, or end event already closes one")
+)
+
+// EventLink is the Db model for a typed relationship from one event to another, like an end closing a start
+type EventLink struct {
+	DbRecord
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	FromEventID string `json:"from_event_id"`
+	ToEventID   string `json:"to_event_id"`
+
+	fromDbID int64
+	toDbID   int64
+}
+
+// EventLinkRequest is a link to make from an event to another
+type EventLinkRequest struct {
+	Type string `json:"type"`
+	To   string `json:"to"`
+}
+
+// EventLinkResponse represents the response to send to client, in case of a create link
+type EventLinkResponse struct {
+	Link *EventLink `json:"link"`
+}
+
+// EventLinksResponse represents the response to send to client, in case of a get links
+type EventLinksResponse struct {
+	Links []*EventLink `json:"links"`
+}
+
+func validateEventLink(link *EventLinkRequest) error {
+	if link.To == "" {
+		return errors.New("to is required")
+	}
+
+	for _, linkType := range linkTypes {
+		if link.Type == linkType {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("type should be %s, %s or %s", linkTypeCloses, linkTypeInterrupts, linkTypeRelates)
+}
+
+// checkLinkEvents validates the events of a link of linkType, once both are known to exist
+func checkLinkEvents(linkType string, from, to *Event) error {
+	if from.ID == to.ID {
+		return newBadRequestError("an event can't be linked to itself")
+	}
+	if linkType != linkTypeCloses {
+		return nil
+	}
+
+	if !isEventType(from, eventTypeEnd) || !isEventType(to, eventTypeStart) {
+		return newBadRequestError("only an end event can close a start event")
+	}
+	if from.UserCreatedAt.Before(to.UserCreatedAt) {
+		return newBadRequestError("an end event can't close a start event after it")
+	}
+
+	return nil
+}
+
+// CreateEventLink links an event to another. Both have to be active, and a start event can only be closed by one end
+// event, which can only close one start.
+func (handler *EventsHandler) CreateEventLink(ctx context.Context, fromEventID, linkType, toEventID string) (*EventLink, error) {
+	fn := "CreateEventLink"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", fromEventID)
+
+	link := &EventLink{ID: uuid.New().String(), Type: linkType, FromEventID: fromEventID, ToEventID: toEventID}
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		// both rows stay locked till commit, so that two ends can't close the same start at once
+		from, err := txStuff.lockEvent(ctx, fromEventID)
+		if err != nil {
+			return deepError.New(fn, "lock from event", err)
+		}
+		to, err := txStuff.lockEvent(ctx, toEventID)
+		if err != nil {
+			return deepError.New(fn, "lock to event", err)
+		}
+		if from == nil || to == nil {
+			return errLinkEventNotFound
+		}
+
+		err = loadEventDetails(ctx, txStuff, from)
+		if err != nil {
+			return deepError.New(fn, "load from event details", err)
+		}
+		err = loadEventDetails(ctx, txStuff, to)
+		if err != nil {
+			return deepError.New(fn, "load to event details", err)
+		}
+
+		err = checkLinkEvents(linkType, from, to)
+		if err != nil {
+			return err
+		}
+
+		if linkType == linkTypeCloses {
+			for _, event := range []*Event{from, to} {
+				closes, err := txStuff.count(ctx, queryCountClosesLinks, event.DbID, event.DbID)
+				if err != nil {
+					return deepError.New(fn, "count closes links", err)
+				}
+				if closes > 0 {
+					return errAlreadyClosed
+				}
+			}
+		}
+
+		err = txStuff.insertEventLink(ctx, link, from.DbID, to.DbID)
+		if err != nil {
+			return deepError.New(fn, "insert link", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logFailure(logger, "create link failed", err)
+		return nil, deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("link created", "link_id", link.ID, "type", linkType)
+	return link, nil
+}
+
+// GetEventLinks gets the links from and to an event, nil if there is no such event
+func (handler *EventsHandler) GetEventLinks(ctx context.Context, eventID string) ([]*EventLink, error) {
+	fn := "GetEventLinks"
+
+	event, err := handler.dbStuff.findEventByID(ctx, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "find event", err)
+	}
+	if event == nil || event.Status != statusActive {
+		return nil, nil
+	}
+
+	links, err := handler.dbStuff.findEventLinksByEventDbIDs(ctx, []int64{event.DbID})
+	if err != nil {
+		return nil, deepError.New(fn, "find links", err)
+	}
+	if links[event.DbID] == nil {
+		return make([]*EventLink, 0), nil
+	}
+
+	return links[event.DbID], nil
+}
+
+// DeleteEventLink removes a link from or to the event
+func (handler *EventsHandler) DeleteEventLink(ctx context.Context, eventID, linkID string) error {
+	fn := "DeleteEventLink"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		link, err := txStuff.findEventLink(ctx, linkID)
+		if err != nil {
+			return deepError.New(fn, "find link", err)
+		}
+		if link == nil || (link.FromEventID != eventID && link.ToEventID != eventID) {
+			return errLinkNotFound
+		}
+
+		_, err = txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventLinksTableName, colDbID), link.DbID)
+		if err != nil {
+			return deepError.New(fn, "delete", err)
+		}
+
+		err = txStuff.touchLinkedEvents(ctx, link)
+		if err != nil {
+			return deepError.New(fn, "touch linked events", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityEventLink, link.ID, auditActionDelete, link, nil)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
+
+// insertEventLink adds and audits a link between two events, failing with errLinkExists if there already is one
+// of its type between them
+func (dbStuff *dbStuff) insertEventLink(ctx context.Context, link *EventLink, fromDbID, toDbID int64) error {
+	fn := "insertEventLink"
+
+	res, err := dbStuff.exec(ctx,
+		fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)",
+			eventLinksTableName, eventLinksColID, eventLinksColType, eventLinksColFromEventID, eventLinksColToEventID),
+		link.ID, link.Type, fromDbID, toDbID)
+	if err != nil {
+		return deepError.New(fn, "insert", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return deepError.New(fn, "rows affected", err)
+	}
+	if inserted == 0 {
+		return errLinkExists
+	}
+	link.DbID, err = getDbID(res)
+	if err != nil {
+		return deepError.New(fn, "get db id", err)
+	}
+	link.fromDbID, link.toDbID = fromDbID, toDbID
+
+	err = dbStuff.touchLinkedEvents(ctx, link)
+	if err != nil {
+		return deepError.New(fn, "touch linked events", err)
+	}
+
+	return recordAudit(ctx, dbStuff, auditEntityEventLink, link.ID, auditActionCreate, nil, link)
+}
+
+// touchLinkedEvents bumps both events of a link, as links are part of what is sent for an event
+func (dbStuff *dbStuff) touchLinkedEvents(ctx context.Context, link *EventLink) error {
+	return dbStuff.touchEvents(ctx, link.fromDbID, link.toDbID)
+}
+
+// touchEventsLinkedTo bumps the events linked to an event being deleted, restored or removed. Links only show while
+// both their events are active, so the links of those events change along with it.
+func (dbStuff *dbStuff) touchEventsLinkedTo(ctx context.Context, eventDbID int64) error {
+	fn := "touchEventsLinkedTo"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetLinkedEventDbIDs, eventDbID, eventDbID, eventDbID)
+	if err != nil {
+		return deepError.New(fn, "query", err)
+	}
+
+	linkedDbIDs := make([]int64, 0)
+	for rows.Next() {
+		var linkedDbID int64
+		err = rows.Scan(&linkedDbID)
+		if err != nil {
+			rows.Close()
+			return deepError.New(fn, "scan", err)
+		}
+		linkedDbIDs = append(linkedDbIDs, linkedDbID)
+	}
+	rows.Close()
+
+	return dbStuff.touchEvents(ctx, linkedDbIDs...)
+}
+
+// lockEvent finds an active event and locks its row till the transaction ends, nil if there is none
+func (dbStuff *dbStuff) lockEvent(ctx context.Context, eventID string) (*Event, error) {
+	fn := "lockEvent"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryLockEvent, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	event := &Event{Type: &EventType{}}
+	err = rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+		&event.TimeZone, &event.UTCOffset, &event.Fields)
+	if err != nil {
+		return nil, deepError.New(fn, "scan", err)
+	}
+
+	return event, nil
+}
+
+// findEventLink finds a link by its id, nil if there is none
+func (dbStuff *dbStuff) findEventLink(ctx context.Context, linkID string) (*EventLink, error) {
+	fn := "findEventLink"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetEventLink, linkID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	link := &EventLink{}
+	err = rows.Scan(&link.DbID, &link.CreatedAt, &link.UpdatedAt, &link.Status, &link.ID, &link.Type, &link.FromEventID, &link.ToEventID,
+		&link.fromDbID, &link.toDbID)
+	if err != nil {
+		return nil, deepError.New(fn, "scan", err)
+	}
+
+	return link, nil
+}
+
+// findEventLinksByEventDbIDs finds the links from and to many events in one query. Keyed by the db id of the event,
+// so a link between two of the events is under both.
+func (dbStuff *dbStuff) findEventLinksByEventDbIDs(ctx context.Context, eventDbIDs []int64) (map[int64][]*EventLink, error) {
+	fn := "findEventLinksByEventDbIDs"
+
+	eventLinks := make(map[int64][]*EventLink)
+	if len(eventDbIDs) == 0 {
+		return eventLinks, nil
+	}
+
+	args := make([]interface{}, 0)
+	for _, id := range eventDbIDs {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, fmt.Sprintf(queryGetEventLinks, inPlaceholders(len(eventDbIDs))), args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	wanted := make(map[int64]bool)
+	for _, id := range eventDbIDs {
+		wanted[id] = true
+	}
+
+	for rows.Next() {
+		link := &EventLink{}
+		err = rows.Scan(&link.DbID, &link.CreatedAt, &link.UpdatedAt, &link.Status, &link.ID, &link.Type, &link.fromDbID, &link.toDbID,
+			&link.FromEventID, &link.ToEventID)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+
+		if wanted[link.fromDbID] {
+			eventLinks[link.fromDbID] = append(eventLinks[link.fromDbID], link)
+		}
+		if wanted[link.toDbID] {
+			eventLinks[link.toDbID] = append(eventLinks[link.toDbID], link)
+		}
+	}
+
+	return eventLinks, nil
+}
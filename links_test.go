@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestEventLinks(t *testing.T) {
+	fn := "TestEventLinks"
+
+	handler := &TestEventHandler{}
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: handler,
+	}
+
+	router.HandleFunc(routeGetEvent, GetEventHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventLinks, GetEventLinksHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventLinks, CreateEventLinkHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeEventLink, DeleteEventLinkHandler(env)).Methods(http.MethodDelete)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, event := range []*Event{
+		{Title: "work", UserCreatedAt: start, Type: &EventType{Value: eventTypeStart}},
+		{Title: "work", UserCreatedAt: start.Add(time.Hour), Type: &EventType{Value: eventTypeEnd}},
+		{Title: "work", UserCreatedAt: start.Add(2 * time.Hour), Type: &EventType{Value: eventTypeEnd}},
+		{Title: "phone", UserCreatedAt: start.Add(30 * time.Minute), Type: &EventType{Value: "distraction"}},
+	} {
+		_, err := handler.CreateEvent(context.Background(), event)
+		util.Test.HandleIfTestError(t, err, fn)
+	}
+
+	tests := []struct {
+		name     string
+		eventID  string
+		body     string
+		expected int
+	}{
+		{"closes", "2", `{"type": "closes", "to": "1"}`, http.StatusOK},
+		{"second end closing the start", "3", `{"type": "closes", "to": "1"}`, http.StatusBadRequest},
+		{"start closing an end", "1", `{"type": "closes", "to": "3"}`, http.StatusBadRequest},
+		{"interrupts", "4", `{"type": "interrupts", "to": "1"}`, http.StatusOK},
+		{"same link again", "4", `{"type": "interrupts", "to": "1"}`, http.StatusBadRequest},
+		{"unknown type", "4", `{"type": "blocks", "to": "1"}`, http.StatusBadRequest},
+		{"itself", "4", `{"type": "relates", "to": "4"}`, http.StatusBadRequest},
+		{"unknown event", "4", `{"type": "relates", "to": "10"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rr := serveTest(t, fn, router, http.MethodPost, fmt.Sprintf(routeEventLinksF, test.eventID), test.body)
+		util.Test.AssertEquals(t, test.expected, rr.Code, fn+": Wrong Status Code for "+test.name)
+	}
+
+	event := struct {
+		Data EventResponse `json:"data"`
+	}{}
+	rr := serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeGetEventF, "1"), "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &event), fn)
+	util.Test.AssertEquals(t, 2, len(event.Data.Event.Links), fn+": Links not in the event")
+	util.Test.AssertEquals(t, "2", event.Data.Event.Links[0].FromEventID, fn+": Wrong linked event")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeEventLinkF, "1", event.Data.Event.Links[0].ID), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Link not deleted")
+
+	rr = serveTest(t, fn, router, http.MethodPost, fmt.Sprintf(routeEventLinksF, "3"), `{"type": "closes", "to": "1"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Start not closable after its link was deleted")
+
+	links := struct {
+		Data EventLinksResponse `json:"data"`
+	}{}
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeEventLinksF, "2"), "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &links), fn)
+	util.Test.AssertEquals(t, 0, len(links.Data.Links), fn+": Deleted link listed")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeEventLinkF, "2", "link-10"), "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Unknown link deleted")
+}
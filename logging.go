@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	headerRequestID = "X-Request-ID"
+
+	// incoming request ids longer than this are not trusted and a new one is generated
+	maxRequestIDLength = 128
+)
+
+type contextKey string
+
+const (
+	ctxKeyRequestID contextKey = "requestID"
+	ctxKeyLogger    contextKey = "logger"
+)
+
+// newLogger creates a json logger writing to w at the given level (debug, info, warn, error).
+// Unknown levels default to info.
+func newLogger(w io.Writer, level string) *slog.Logger {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl}))
+}
+
+// requestIDFromContext returns the request id stored in the context, or empty if none.
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(ctxKeyRequestID).(string)
+	return requestID
+}
+
+// loggerFromContext returns the request scoped logger, falling back to the default logger.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(ctxKeyLogger).(*slog.Logger)
+	if !ok || logger == nil {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// errorAttr converts an error to a structured field, with the chain of wrapped errors if any
+func errorAttr(err error) slog.Attr {
+	if err == nil {
+		return slog.String("error", "")
+	}
+
+	chain := make([]string, 0)
+	for cause := errorCause(err); cause != nil; cause = errorCause(cause) {
+		chain = append(chain, cause.Error())
+	}
+
+	return slog.Group("error",
+		slog.String("message", err.Error()),
+		slog.Any("chain", chain),
+	)
+}
+
+// logFailure logs a failed operation, only at info level if it failed for a RequestError, which is the client's to fix
+func logFailure(logger *slog.Logger, msg string, err error) {
+	if _, ok := asRequestError(err); ok {
+		logger.Info(msg, errorAttr(err))
+		return
+	}
+
+	logger.Error(msg, errorAttr(err))
+}
+
+// errorCause is the error err wraps, nil if none. DeepError has no Unwrap, so its Cause is taken directly.
+func errorCause(err error) error {
+	if deep, ok := err.(*deepError.DeepError); ok {
+		return deep.Cause
+	}
+
+	return errors.Unwrap(err)
+}
+
+// causedBy is errors.Is, also looking through the causes of DeepErrors, like a query timeout wrapped on its way up
+func causedBy(err, target error) bool {
+	for ; err != nil; err = errorCause(err) {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequestIDMiddleware assigns a request id to every request, or propagates the one sent by the client.
+// The id is sent back in the response header and a logger tagged with it is put in the request context.
+func RequestIDMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := strings.TrimSpace(r.Header.Get(headerRequestID))
+			if requestID == "" || len(requestID) > maxRequestIDLength {
+				requestID = uuid.New().String()
+			}
+
+			w.Header().Set(headerRequestID, requestID)
+
+			ctx := context.WithValue(r.Context(), ctxKeyRequestID, requestID)
+			ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("request_id", requestID))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// statusRecorder captures the status code written by a handler, for logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// AccessLogMiddleware logs every request once it is served. Has to run inside RequestIDMiddleware.
+func AccessLogMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			loggerFromContext(r.Context()).Info("request served",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"remote_addr", r.RemoteAddr,
+			)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jforcode/Go-DeepError"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestErrorAttr(t *testing.T) {
+	fn := "TestErrorAttr"
+
+	err := deepError.New("GetEvent", "find event", deepError.New("findEventByID", "query", errors.New("connection refused")))
+
+	buf := &bytes.Buffer{}
+	newLogger(buf, "info").Error("get event failed", errorAttr(err))
+
+	logged := struct {
+		Error struct {
+			Message string   `json:"message"`
+			Chain   []string `json:"chain"`
+		} `json:"error"`
+	}{}
+	util.Test.HandleIfTestError(t, json.Unmarshal(buf.Bytes(), &logged), fn)
+	util.Test.AssertEquals(t, err.Error(), logged.Error.Message, fn+": Wrong message")
+	util.Test.AssertEquals(t, 2, len(logged.Error.Chain), fn+": Wrong chain length")
+	util.Test.AssertEquals(t, "connection refused", logged.Error.Chain[1], fn+": Root cause not logged")
+}
@@ -1,32 +1,123 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jforcode/Go-Util"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	"github.com/magiconair/properties"
 )
 
 const (
-	paramEventID = "{eventID}"
+	paramEventID      = "{eventID}"
+	paramRevision     = "{revision:[0-9]+}"
+	paramTemplateID   = "{templateID}"
+	paramLinkID       = "{linkID}"
+	paramFieldName    = "{fieldName}"
+	paramAttachmentID = "{attachmentID}"
+	paramGoalID       = "{goalID}"
 )
 
 const (
-	routeGetHealth   = "/health"
-	routeGetEvents   = "/events"
-	routeGetEvent    = "/events/" + paramEventID
-	routeGetEventF   = "/events/%s"
-	routeCreateEvent = "/event"
+	routeGetHealth         = "/health"
+	routeGetLive           = "/health/live"
+	routeGetReady          = "/health/ready"
+	routeGetEvents         = "/events"
+	routeGetEvent          = "/events/" + paramEventID
+	routeGetEventStats     = "/events/stats"
+	routeGetEventF         = "/events/%s"
+	routeGetHistory        = "/events/" + paramEventID + "/history"
+	routeGetHistoryF       = "/events/%s/history"
+	routeGetRevisions      = "/events/" + paramEventID + "/revisions"
+	routeGetRevisionsF     = "/events/%s/revisions"
+	routeGetRevision       = "/events/" + paramEventID + "/revisions/" + paramRevision
+	routeGetRevisionF      = "/events/%s/revisions/%d"
+	routeRestoreRevision   = "/events/" + paramEventID + "/revisions/" + paramRevision + "/restore"
+	routeRestoreRevisionF  = "/events/%s/revisions/%d/restore"
+	routeEventLinks        = "/events/" + paramEventID + "/links"
+	routeEventLinksF       = "/events/%s/links"
+	routeEventLink         = "/events/" + paramEventID + "/links/" + paramLinkID
+	routeEventLinkF        = "/events/%s/links/%s"
+	routeEventAttachments  = "/events/" + paramEventID + "/attachments"
+	routeEventAttachmentsF = "/events/%s/attachments"
+	routeEventAttachment   = "/events/" + paramEventID + "/attachments/" + paramAttachmentID
+	routeEventAttachmentF  = "/events/%s/attachments/%s"
+	routeCreateEvent       = "/event"
+	routeSync              = "/sync"
+	routeGraphQL           = "/graphql"
+
+	routeCreateFieldSchema  = "/field"
+	routeGetFieldSchemas    = "/fields"
+	routeDeleteFieldSchema  = "/fields/" + paramFieldName
+	routeDeleteFieldSchemaF = "/fields/%s"
+
+	routeCreateTemplate       = "/template"
+	routeGetTemplates         = "/templates"
+	routeGetTemplate          = "/templates/" + paramTemplateID
+	routeGetTemplateF         = "/templates/%s"
+	routeTemplateExceptions   = "/templates/" + paramTemplateID + "/exceptions"
+	routeTemplateExceptionsF  = "/templates/%s/exceptions"
+	routeMaterializeTemplates = "/templates/materialize"
+
+	routeCreateGoal    = "/goal"
+	routeGetGoals      = "/goals"
+	routeGetGoal       = "/goals/" + paramGoalID
+	routeGetGoalF      = "/goals/%s"
+	routeGoalProgress  = "/goals/" + paramGoalID + "/progress"
+	routeGoalProgressF = "/goals/%s/progress"
+
+	routeStartTimer   = "/timer/start"
+	routePauseTimer   = "/timer/pause"
+	routeResumeTimer  = "/timer/resume"
+	routeStopTimer    = "/timer/stop"
+	routeCurrentTimer = "/timer/current"
+
+	routeAdminRetention = "/admin/retention"
+	routeGetDebugVars   = "/debug/vars"
+)
+
+// error codes sent back in ResponseError
+const (
+	errCodeInternal     = 0
+	errCodeTimeout      = 1
+	errCodeNotReady     = 2
+	errCodeBadRequest   = 3
+	errCodeNotFound     = 4
+	errCodeRateLimited  = 5
+	errCodeTooLarge     = 6
+	errCodeUnauthorized = 7
+	errCodeForbidden    = 8
+)
+
+const (
+	defaultRequestTimeout  = 30 * time.Second
+	defaultQueryTimeout    = 10 * time.Second
+	defaultReadTimeout     = 15 * time.Second
+	defaultWriteTimeout    = 45 * time.Second
+	defaultIdleTimeout     = 60 * time.Second
+	defaultMaxHeaderBytes  = 1 << 20
+	defaultShutdownTimeout = 20 * time.Second
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 5 * time.Minute
+	defaultConnMaxIdleTime = time.Minute
+	defaultRetryAttempts   = 3
+	defaultRetryBaseDelay  = 50 * time.Millisecond
+	defaultRetryMaxDelay   = time.Second
+	defaultCacheSize       = 1000
+	defaultCacheTTL        = 10 * time.Minute
 )
 
 // ResponseError is the error format in case of any error, be it internal or user-defined
@@ -39,6 +130,43 @@ func (err *ResponseError) Error() string {
 	return strconv.Itoa(err.Code) + " : " + err.Message
 }
 
+// RequestError is an error caused by the request itself rather than by the api, sent back with its own status.
+// It can be wrapped like any other error, handleHTTPError and grpcError find it among the causes.
+type RequestError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (err *RequestError) Error() string {
+	return err.Message
+}
+
+func newBadRequestError(message string) *RequestError {
+	return &RequestError{Status: http.StatusBadRequest, Code: errCodeBadRequest, Message: message}
+}
+
+func newNotFoundError(message string) *RequestError {
+	return &RequestError{Status: http.StatusNotFound, Code: errCodeNotFound, Message: message}
+}
+
+func newForbiddenError(message string) *RequestError {
+	return &RequestError{Status: http.StatusForbidden, Code: errCodeForbidden, Message: message}
+}
+
+// asRequestError finds the RequestError err was caused by, also looking through the causes of DeepErrors, so
+// that one returned from deep in a handler can be wrapped on its way up like any other error
+func asRequestError(err error) (*RequestError, bool) {
+	for ; err != nil; err = errorCause(err) {
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			return reqErr, true
+		}
+	}
+
+	return nil, false
+}
+
 // Response is the final response sent to the client
 type Response struct {
 	Success bool           `json:"success"`
@@ -62,47 +190,235 @@ type EventsResponse struct {
 }
 
 type env struct {
-	EventsHandler IEventsHandler
+	EventsHandler      IEventsHandler
+	Logger             *slog.Logger
+	ReadinessCheckers  []DependencyChecker
+	HealthCheckTimeout time.Duration
+	// CacheControl is the Cache-Control header to send, keyed by route
+	CacheControl map[string]string
+	Retention    RetentionPolicy
+	// Authenticator tells who bearer tokens belong to, nil leaves every caller anonymous
+	Authenticator Authenticator
+	// Blobs keeps the content of attachments
+	Blobs BlobStore
 }
 
 func main() {
-	p := properties.MustLoadFile("app.properties", properties.UTF8)
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		_, values, err := loadConfig(args[2:], os.LookupEnv)
+		if values != nil {
+			printConfig(os.Stdout, values)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	name := commandServe
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name != commandServe {
+		os.Exit(runCommand(name, args, os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	config, _, err := loadConfig(args, os.LookupEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger := newLogger(os.Stdout, config.LogLevel)
+	slog.SetDefault(logger)
+
+	db, err := getDb(config.DB)
+	if err != nil {
+		logger.Error("could not connect to db", errorAttr(err))
+		os.Exit(1)
+	}
+
+	schemaVersion, err := latestMigrationVersion()
+	if err != nil {
+		logger.Error("could not read migrations", errorAttr(err))
+		os.Exit(1)
+	}
 
-	url := p.GetString("url", "")
-	db, err := getDbFromProps(p)
+	blobs, err := newLocalBlobStore(config.Attachments.Dir)
 	if err != nil {
-		panic(err)
+		logger.Error("could not open attachments dir", errorAttr(err))
+		os.Exit(1)
 	}
 
 	evtHandler := &EventsHandler{}
-	evtHandler.Init(db)
+	evtHandler.Init(db, handlerOptions(config))
+	usersHandler := &UsersHandler{}
+	usersHandler.Init(db, handlerOptions(config))
 	env := &env{
-		evtHandler,
+		EventsHandler: evtHandler,
+		Logger:        logger,
+		ReadinessCheckers: []DependencyChecker{
+			&dbPingChecker{db: db},
+			&schemaVersionChecker{db: db, expectedVersion: schemaVersion},
+		},
+		HealthCheckTimeout: config.HealthCheckTimeout,
+		CacheControl: map[string]string{
+			routeGetEvents: config.CacheControlEvents,
+			routeGetEvent:  config.CacheControlEvent,
+		},
+		Retention:     config.Retention,
+		Authenticator: usersHandler,
+		Blobs:         blobs,
 	}
 
+	limiter := newRateLimiter(config.RateLimit)
+
 	router := mux.NewRouter()
-	loggedRouter := handlers.LoggingHandler(os.Stdout, router)
+	var handler http.Handler = router
+	handler = TimeoutMiddleware(config.RequestTimeout)(handler)
+	handler = AuthMiddleware(env.Authenticator)(handler)
+	handler = AuthRateLimitMiddleware(limiter)(handler)
+	handler = CORSMiddleware(config.CORS)(handler)
+	handler = ActorMiddleware()(handler)
+	handler = AccessLogMiddleware()(handler)
+	handler = RequestIDMiddleware(logger)(handler)
+
+	router.Use(RateLimitMiddleware(limiter))
+	router.Use(MaxBytesMiddleware(config.MaxBodyBytes, map[string]int64{
+		rateLimitRouteKey(http.MethodPost, routeEventAttachments): config.Attachments.MaxBytes,
+	}))
 
 	router.HandleFunc(routeGetHealth, HealthCheckHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetLive, LivenessHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetReady, ReadinessHandler(env)).Methods(http.MethodGet)
 	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetEventStats, GetEventStatsHandler(env)).Methods(http.MethodGet)
 	router.HandleFunc(routeGetEvent, GetEventHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetHistory, GetEventHistoryHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetRevisions, GetEventRevisionsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetRevision, GetEventRevisionHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeRestoreRevision, RestoreEventRevisionHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeEventLinks, GetEventLinksHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventLinks, CreateEventLinkHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeEventLink, DeleteEventLinkHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeEventAttachments, GetAttachmentsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventAttachments, UploadAttachmentsHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeEventAttachment, DownloadAttachmentHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeEventAttachment, DeleteAttachmentHandler(env)).Methods(http.MethodDelete)
 	router.HandleFunc(routeCreateEvent, CreateEventHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeCreateFieldSchema, CreateFieldSchemaHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetFieldSchemas, GetFieldSchemasHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeDeleteFieldSchema, DeleteFieldSchemaHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeSync, SyncHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeSync, PushSyncHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeAdminRetention, RetentionHandler(env)).Methods(http.MethodPost)
+	router.Handle(routeGraphQL, GraphQLHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeCreateTemplate, CreateTemplateHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetTemplates, GetTemplatesHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeMaterializeTemplates, MaterializeTemplatesHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetTemplate, GetTemplateHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetTemplate, DeleteTemplateHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeTemplateExceptions, AddTemplateExceptionHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeCreateGoal, CreateGoalHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetGoals, GetGoalsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetGoal, GetGoalHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetGoal, DeleteGoalHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeGoalProgress, GetGoalProgressHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeStartTimer, StartTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routePauseTimer, PauseTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeResumeTimer, ResumeTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeStopTimer, StopTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeCurrentTimer, GetCurrentTimerHandler(env)).Methods(http.MethodGet)
+
+	workers := NewBackgroundWorkers()
+	if config.DebugURL != "" {
+		debugRouter := mux.NewRouter()
+		debugRouter.Handle(routeGetDebugVars, DebugVarsHandler()).Methods(http.MethodGet)
+
+		debugSrv := newHTTPServer(ServerConfig{URL: config.DebugURL, ReadTimeout: config.Server.ReadTimeout,
+			WriteTimeout: config.Server.WriteTimeout, IdleTimeout: config.Server.IdleTimeout}, debugRouter)
+		workers.Go("debug", func(ctx context.Context) {
+			runDebugServer(ctx, debugSrv, logger)
+		})
+	}
+	if config.GRPCURL != "" {
+		listener, err := net.Listen("tcp", config.GRPCURL)
+		if err != nil {
+			logger.Error("could not listen for grpc", "url", config.GRPCURL, errorAttr(err))
+			os.Exit(1)
+		}
+
+		grpcSrv := newGRPCServer(env, config.RequestTimeout)
+		workers.Go("grpc", func(ctx context.Context) {
+			runGRPCServer(ctx, grpcSrv, listener, config.Server.ShutdownTimeout, logger)
+		})
+	}
+	workers.Go("rate_limit_sweep", func(ctx context.Context) {
+		ticker := time.NewTicker(rateLimitSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				limiter.sweep(rateLimitIdleTime)
+			}
+		}
+	})
+	if config.Retention.enabled() {
+		workers.Go("retention", func(ctx context.Context) {
+			runRetention(ctx, evtHandler, env.Blobs, config.Retention, config.RetentionInterval, logger)
+		})
+	}
+	workers.Go("templates", func(ctx context.Context) {
+		runTemplates(ctx, evtHandler, config.TemplatesInterval, config.TemplatesLookback, logger)
+	})
+	workers.Go("timers", func(ctx context.Context) {
+		runTimers(ctx, evtHandler, config.TimersInterval, logger)
+	})
+	srv := newHTTPServer(config.Server, handler)
+
+	err = runServer(config.Server, srv, workers, db, logger)
+	if err != nil {
+		logger.Error("server stopped with error", errorAttr(err))
+		os.Exit(1)
+	}
+}
 
-	log.Fatal(http.ListenAndServe(url, loggedRouter))
+func handlerOptions(config *Config) HandlerOptions {
+	return HandlerOptions{
+		QueryTimeout: config.QueryTimeout,
+		Retry:        config.DB.Retry,
+		CacheSize:    config.CacheSize,
+		CacheTTL:     config.CacheTTL,
+	}
 }
 
-func getDbFromProps(p *properties.Properties) (*sql.DB, error) {
-	user := p.GetString("user", "")
-	password := p.GetString("password", "")
-	host := p.GetString("host", "")
-	database := p.GetString("db", "")
+func getDb(config DBConfig) (*sql.DB, error) {
 	flags := make(map[string]string)
 	flags["parseTime"] = "true"
 
-	return util.Db.GetDb(user, password, host, database, flags)
+	db, err := util.Db.GetDb(config.User, config.Password, config.Host, config.Name, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	return db, nil
 }
 
-func handleHTTPSuccess(w http.ResponseWriter, data interface{}) {
+func handleHTTPSuccess(w http.ResponseWriter, r *http.Request, data interface{}) {
 	resp := Response{
 		Success: true,
 		Data:    data,
@@ -111,28 +427,71 @@ func handleHTTPSuccess(w http.ResponseWriter, data interface{}) {
 
 	respJSON, err := json.Marshal(resp)
 	if err != nil {
-		handleHTTPError(w, err)
+		handleHTTPError(w, r, err)
 		return
 	}
 
 	io.WriteString(w, string(respJSON))
 }
 
-func handleHTTPError(w http.ResponseWriter, err error) {
-	fmt.Printf("Http error occured: %s\n", err.Error())
+func handleHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := loggerFromContext(r.Context())
+	status := http.StatusOK
+	respErr := &ResponseError{
+		Code:    errCodeInternal,
+		Message: err.Error(),
+	}
+
+	reqErr, isReqErr := asRequestError(err)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case isReqErr:
+		logger.Info("bad request", "method", r.Method, "path", r.URL.Path, "status", reqErr.Status, errorAttr(err))
+		status = reqErr.Status
+		respErr.Code = reqErr.Code
+		respErr.Message = reqErr.Message
+
+	case errors.As(err, &maxBytesErr):
+		logger.Info("request body too large", "method", r.Method, "path", r.URL.Path, "limit", maxBytesErr.Limit)
+		status = http.StatusRequestEntityTooLarge
+		respErr.Code = errCodeTooLarge
+		respErr.Message = fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit)
+
+	case causedBy(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled):
+		// client went away, nobody to respond to
+		logger.Warn("request cancelled", "method", r.Method, "path", r.URL.Path, errorAttr(err))
+		return
+
+	case causedBy(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		logger.Error("request timed out", "method", r.Method, "path", r.URL.Path, errorAttr(err))
+		status = http.StatusGatewayTimeout
+		respErr.Code = errCodeTimeout
+		respErr.Message = "request timed out"
+
+	default:
+		logger.Error("http error occurred", "method", r.Method, "path", r.URL.Path, errorAttr(err))
+	}
+
+	handleHTTPFailure(w, status, nil, respErr)
+}
+
+// handleHTTPFailure sends an unsuccessful response with the given status, along with any data relevant to the failure
+func handleHTTPFailure(w http.ResponseWriter, status int, data interface{}, respErr *ResponseError) {
 	resp := Response{
 		Success: false,
-		Data:    nil,
-		Error: &ResponseError{
-			Code:    0,
-			Message: err.Error(),
-		},
+		Data:    data,
+		Error:   respErr,
 	}
 
 	respJSON, err := json.Marshal(resp)
 	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		io.WriteString(w, err.Error())
-	} else {
-		io.WriteString(w, string(respJSON))
+		return
+	}
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
 	}
+	io.WriteString(w, string(respJSON))
 }
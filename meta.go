@@ -13,7 +13,17 @@ const (
 	eventsColTitle     = "title"
 	eventsColNote      = "note"
 	eventsColCreatedAt = "created_at"
+	eventsColTimeZone  = "time_zone"
+	eventsColUTCOffset = "utc_offset"
+	eventsColFields    = "fields"
 	eventsColTypeID    = "type_id"
+	eventsColChangedAt = "changed_at"
+	eventsColChangeSeq = "change_seq"
+)
+
+const (
+	eventChangeSeqTableName = "event_change_seq"
+	eventChangeSeqColSeq    = "seq"
 )
 
 const (
@@ -31,3 +41,115 @@ const (
 	eventTagMapColEventID = "event_id"
 	eventTagMapColTagID   = "tag_id"
 )
+
+const (
+	schemaVersionTableName = "app_schema_version"
+	schemaVersionColVer    = "version"
+	schemaVersionColDirty  = "dirty"
+)
+
+const (
+	auditLogTableName     = "audit_log"
+	auditLogColEntityType = "entity_type"
+	auditLogColEntityID   = "entity_id"
+	auditLogColAction     = "action"
+	auditLogColActor      = "actor"
+	auditLogColRequestID  = "request_id"
+	auditLogColDiff       = "diff"
+)
+
+const (
+	eventRevisionsTableName   = "event_revisions"
+	eventRevisionsColEventID  = "event_id"
+	eventRevisionsColRevision = "revision"
+	eventRevisionsColSnapshot = "snapshot"
+	eventRevisionsColActor    = "actor"
+)
+
+const (
+	eventsArchiveTableName    = "events_archive"
+	eventsArchiveColEventID   = "event_id"
+	eventsArchiveColCreatedAt = "event_created_at"
+	eventsArchiveColSnapshot  = "snapshot"
+)
+
+const (
+	usersTableName   = "users"
+	usersColUsername = "username"
+)
+
+const (
+	apiTokensTableName    = "api_tokens"
+	apiTokensColUserID    = "user_id"
+	apiTokensColName      = "name"
+	apiTokensColTokenHash = "token_hash"
+	apiTokensColExpiresAt = "expires_at"
+)
+
+const (
+	eventTemplatesTableName     = "event_templates"
+	eventTemplatesColID         = "id"
+	eventTemplatesColTitle      = "title"
+	eventTemplatesColNote       = "note"
+	eventTemplatesColType       = "type"
+	eventTemplatesColTags       = "tags"
+	eventTemplatesColRRule      = "rrule"
+	eventTemplatesColStartsAt   = "starts_at"
+	eventTemplatesColExceptions = "exceptions"
+	eventTemplatesColTimeZone   = "time_zone"
+)
+
+const (
+	templateOccurrencesTableName     = "template_occurrences"
+	templateOccurrencesColTemplateID = "template_id"
+	templateOccurrencesColOccursAt   = "occurs_at"
+	templateOccurrencesColEventID    = "event_id"
+)
+
+const (
+	eventLinksTableName      = "event_links"
+	eventLinksColID          = "id"
+	eventLinksColType        = "link_type"
+	eventLinksColFromEventID = "from_event_id"
+	eventLinksColToEventID   = "to_event_id"
+)
+
+const (
+	fieldSchemasTableName      = "event_field_schemas"
+	fieldSchemasColName        = "name"
+	fieldSchemasColType        = "field_type"
+	fieldSchemasColDescription = "description"
+)
+
+const (
+	eventAttachmentsTableName      = "event_attachments"
+	eventAttachmentsColID          = "id"
+	eventAttachmentsColEventID     = "event_id"
+	eventAttachmentsColFileName    = "file_name"
+	eventAttachmentsColContentType = "content_type"
+	eventAttachmentsColSize        = "size"
+	eventAttachmentsColSHA256      = "sha256"
+)
+
+const (
+	goalsTableName     = "goals"
+	goalsColID         = "id"
+	goalsColName       = "name"
+	goalsColTargetTag  = "target_tag"
+	goalsColTargetType = "target_type"
+	goalsColMetric     = "metric"
+	goalsColTarget     = "target"
+	goalsColPeriod     = "period"
+	goalsColTimeZone   = "time_zone"
+)
+
+const (
+	timersTableName          = "timers"
+	timersColID              = "id"
+	timersColOwner           = "owner"
+	timersColStartEventID    = "start_event_id"
+	timersColStartedAt       = "started_at"
+	timersColPausedAt        = "paused_at"
+	timersColPausedMs        = "paused_ms"
+	timersColPomodoroMinutes = "pomodoro_minutes"
+)
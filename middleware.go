@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TimeoutMiddleware puts a deadline on the request context, so every db call made for the request
+// is cancelled once it passes. A client disconnecting cancels the context as well.
+func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// MaxBytesMiddleware caps the size of request bodies. Reading past the limit fails with *http.MaxBytesError,
+// which handleHTTPError sends back as a 413. routeLimits, keyed like POST /route, take the place of limit for single
+// routes, which needs the middleware on the router to know the route.
+func MaxBytesMiddleware(limit int64, routeLimits map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 && len(routeLimits) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			routeLimit := limit
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					if override, ok := routeLimits[rateLimitRouteKey(r.Method, template)]; ok {
+						routeLimit = override
+					}
+				}
+			}
+
+			if r.Body != nil && routeLimit > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, routeLimit)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
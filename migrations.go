@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+// the version each migration takes the db to is recorded in the app_schema_version table by runMigrations,
+// so the db can be checked against the migrations the binary was built with.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationsDir       = "migrations"
+	migrationUpSuffix   = "_up.sql"
+	migrationDownSuffix = "_down.sql"
+)
+
+// latestMigrationVersion is the highest migration version available in the migrations folder
+func latestMigrationVersion() (int64, error) {
+	fn := "latestMigrationVersion"
+
+	entries, err := migrationFiles.ReadDir(migrationsDir)
+	if err != nil {
+		return -1, deepError.New(fn, "read dir", err)
+	}
+
+	var latest int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), migrationUpSuffix) {
+			continue
+		}
+
+		version, err := migrationVersion(entry.Name())
+		if err != nil {
+			return -1, deepError.New(fn, "migration version", err)
+		}
+
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// migrationVersion parses the version from a file name like 2_update_timestamp_up.sql
+func migrationVersion(fileName string) (int64, error) {
+	prefix := strings.SplitN(path.Base(fileName), "_", 2)[0]
+	return strconv.ParseInt(prefix, 10, 64)
+}
+
+// migrationStep is a single migration file to run, and the schema version the db is at after it
+type migrationStep struct {
+	file    string
+	version int64
+}
+
+// migrationPlan lists the migrations taking the db from version current to target, up or down
+func migrationPlan(current, target int64) ([]migrationStep, error) {
+	fn := "migrationPlan"
+
+	entries, err := migrationFiles.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, deepError.New(fn, "read dir", err)
+	}
+
+	upFiles := make(map[int64]string)
+	downFiles := make(map[int64]string)
+	for _, entry := range entries {
+		version, err := migrationVersion(entry.Name())
+		if err != nil {
+			return nil, deepError.New(fn, "migration version", err)
+		}
+
+		switch {
+		case strings.HasSuffix(entry.Name(), migrationUpSuffix):
+			upFiles[version] = path.Join(migrationsDir, entry.Name())
+		case strings.HasSuffix(entry.Name(), migrationDownSuffix):
+			downFiles[version] = path.Join(migrationsDir, entry.Name())
+		}
+	}
+
+	steps := make([]migrationStep, 0)
+	for version := current + 1; version <= target; version++ {
+		file, ok := upFiles[version]
+		if !ok {
+			return nil, fmt.Errorf("no up migration for version %d", version)
+		}
+		steps = append(steps, migrationStep{file: file, version: version})
+	}
+	for version := current; version > target; version-- {
+		file, ok := downFiles[version]
+		if !ok {
+			return nil, fmt.Errorf("no down migration for version %d", version)
+		}
+		steps = append(steps, migrationStep{file: file, version: version - 1})
+	}
+
+	return steps, nil
+}
+
+// splitStatements splits a migration file into the statements in it, as the driver runs one at a time
+func splitStatements(content string) []string {
+	statements := make([]string, 0)
+	for _, statement := range strings.Split(content, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+// currentSchemaVersion reads the version the db is at. The version table only came in with migration 3,
+// so without it the db is either empty or at 2.
+func currentSchemaVersion(ctx context.Context, db *sql.DB) (int64, bool, error) {
+	fn := "currentSchemaVersion"
+
+	hasVersions, err := tableExists(ctx, db, schemaVersionTableName)
+	if err != nil {
+		return -1, false, deepError.New(fn, "check versions table", err)
+	}
+	if !hasVersions {
+		hasEvents, err := tableExists(ctx, db, eventsTableName)
+		if err != nil {
+			return -1, false, deepError.New(fn, "check events table", err)
+		}
+		if hasEvents {
+			return 2, false, nil
+		}
+		return 0, false, nil
+	}
+
+	var version int64
+	var dirty bool
+	err = db.QueryRowContext(ctx, queryGetSchemaVersion).Scan(&version, &dirty)
+	if err != nil {
+		return -1, false, deepError.New(fn, "query", err)
+	}
+
+	return version, dirty, nil
+}
+
+func tableExists(ctx context.Context, db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+		table).Scan(&count)
+
+	return count > 0, err
+}
+
+// setSchemaVersion records the version the db is at, if it has a version table yet. A migration is run dirty, and
+// MySQL can't roll back schema changes, so one failing halfway leaves the db dirty, to be fixed by hand.
+func setSchemaVersion(ctx context.Context, db *sql.DB, version int64, dirty bool) error {
+	exists, err := tableExists(ctx, db, schemaVersionTableName)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = ?, %s = ?", schemaVersionTableName, schemaVersionColVer, schemaVersionColDirty),
+		version, dirty)
+	return err
+}
+
+// runMigrations takes the db to the target version, running each migration a statement at a time
+func runMigrations(ctx context.Context, db *sql.DB, target int64, logger *slog.Logger) error {
+	fn := "runMigrations"
+
+	current, dirty, err := currentSchemaVersion(ctx, db)
+	if err != nil {
+		return deepError.New(fn, "current version", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty, fix the db by hand and set dirty to false first", current)
+	}
+
+	steps, err := migrationPlan(current, target)
+	if err != nil {
+		return deepError.New(fn, "plan", err)
+	}
+	if len(steps) == 0 {
+		logger.Info("schema is up to date", "version", current)
+		return nil
+	}
+
+	at := current
+	for _, step := range steps {
+		content, err := migrationFiles.ReadFile(step.file)
+		if err != nil {
+			return deepError.New(fn, "read "+step.file, err)
+		}
+
+		err = setSchemaVersion(ctx, db, at, true)
+		if err != nil {
+			return deepError.New(fn, "mark dirty", err)
+		}
+
+		logger.Info("running migration", "file", step.file)
+		for _, statement := range splitStatements(string(content)) {
+			_, err = db.ExecContext(ctx, statement)
+			if err != nil {
+				return deepError.New(fn, "run "+step.file, err)
+			}
+		}
+
+		err = setSchemaVersion(ctx, db, step.version, false)
+		if err != nil {
+			return deepError.New(fn, "record version", err)
+		}
+		at = step.version
+	}
+
+	logger.Info("schema migrated", "from", current, "to", target)
+	return nil
+}
@@ -0,0 +1,15 @@
+CREATE TABLE event_links (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    id VARCHAR(100) NOT NULL,
+    link_type VARCHAR(50) NOT NULL,
+    from_event_id INT NOT NULL,
+    to_event_id INT NOT NULL,
+    UNIQUE KEY uk_event_link_id (id),
+    UNIQUE KEY uk_event_link (from_event_id, to_event_id, link_type),
+    KEY idx_event_links_to_event_id (to_event_id),
+    CONSTRAINT fk_event_links_from_event_id FOREIGN KEY (from_event_id) REFERENCES events(_id),
+    CONSTRAINT fk_event_links_to_event_id FOREIGN KEY (to_event_id) REFERENCES events(_id)
+);
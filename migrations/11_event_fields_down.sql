@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS event_field_schemas;
+
+ALTER TABLE events
+    DROP COLUMN fields;
@@ -0,0 +1,13 @@
+ALTER TABLE events
+    ADD COLUMN fields JSON NULL AFTER utc_offset;
+
+CREATE TABLE event_field_schemas (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    name VARCHAR(64) NOT NULL,
+    field_type VARCHAR(20) NOT NULL,
+    description TEXT,
+    UNIQUE KEY uk_event_field_schema_name (name)
+);
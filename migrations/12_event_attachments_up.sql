@@ -0,0 +1,16 @@
+CREATE TABLE event_attachments (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    id VARCHAR(100) NOT NULL,
+    event_id INT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    UNIQUE KEY uk_event_attachment_id (id),
+    KEY idx_event_attachments_event_id (event_id),
+    KEY idx_event_attachments_sha256 (sha256),
+    CONSTRAINT fk_event_attachments_event_id FOREIGN KEY (event_id) REFERENCES events(_id)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE goals (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    id VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    target_tag VARCHAR(255) NOT NULL DEFAULT '',
+    target_type VARCHAR(255) NOT NULL DEFAULT '',
+    metric VARCHAR(20) NOT NULL,
+    target DOUBLE NOT NULL,
+    period VARCHAR(20) NOT NULL,
+    time_zone VARCHAR(64) NOT NULL DEFAULT '',
+    UNIQUE KEY uk_goal_id (id)
+);
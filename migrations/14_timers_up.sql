@@ -0,0 +1,15 @@
+CREATE TABLE timers (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    id VARCHAR(100) NOT NULL,
+    owner VARCHAR(255) NOT NULL,
+    start_event_id VARCHAR(100) NOT NULL,
+    started_at DATETIME(6) NOT NULL,
+    paused_at DATETIME(6) NULL,
+    paused_ms BIGINT NOT NULL DEFAULT 0,
+    pomodoro_minutes INT NOT NULL DEFAULT 0,
+    UNIQUE KEY uk_timer_id (id),
+    UNIQUE KEY uk_timer_owner (owner)
+);
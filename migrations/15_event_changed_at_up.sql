@@ -0,0 +1,4 @@
+ALTER TABLE events
+    ADD COLUMN changed_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) AFTER fields;
+
+UPDATE events SET changed_at = _updated_at, _updated_at = _updated_at;
@@ -0,0 +1,5 @@
+ALTER TABLE events
+    DROP KEY idx_events_change_seq,
+    DROP COLUMN change_seq;
+
+DROP TABLE IF EXISTS event_change_seq;
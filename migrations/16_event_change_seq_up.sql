@@ -0,0 +1,10 @@
+CREATE TABLE event_change_seq (
+    _id INT PRIMARY KEY,
+    seq BIGINT NOT NULL
+);
+
+INSERT INTO event_change_seq (_id, seq) VALUES (1, 0);
+
+ALTER TABLE events
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0 AFTER changed_at,
+    ADD KEY idx_events_change_seq (change_seq, _id);
@@ -0,0 +1,2 @@
+ALTER TABLE event_templates
+    DROP COLUMN time_zone;
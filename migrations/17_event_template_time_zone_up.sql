@@ -0,0 +1,2 @@
+ALTER TABLE event_templates
+    ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '' AFTER starts_at;
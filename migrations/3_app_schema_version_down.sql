@@ -0,0 +1 @@
+DROP TABLE IF EXISTS app_schema_version;
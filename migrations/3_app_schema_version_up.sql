@@ -0,0 +1,6 @@
+CREATE TABLE app_schema_version (
+    version BIGINT NOT NULL,
+    dirty BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO app_schema_version (version, dirty) VALUES (3, FALSE);
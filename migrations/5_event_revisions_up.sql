@@ -0,0 +1,14 @@
+CREATE TABLE event_revisions (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    event_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    snapshot JSON NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    UNIQUE KEY uk_event_revision (event_id, revision)
+);
+
+ALTER TABLE event_revisions
+ADD CONSTRAINT fk_revision_event_id FOREIGN KEY(event_id) REFERENCES events(_id);
@@ -0,0 +1,10 @@
+CREATE TABLE events_archive (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    event_id VARCHAR(100) NOT NULL,
+    event_created_at DATETIME(6) NOT NULL,
+    snapshot JSON NOT NULL,
+    UNIQUE KEY uk_archived_event (event_id)
+);
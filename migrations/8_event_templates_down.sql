@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS template_occurrences;
+DROP TABLE IF EXISTS event_templates;
@@ -0,0 +1,27 @@
+CREATE TABLE event_templates (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    id VARCHAR(100) NOT NULL,
+    title TEXT NOT NULL,
+    note TEXT,
+    type VARCHAR(255) NOT NULL,
+    tags JSON NOT NULL,
+    rrule VARCHAR(1000) NOT NULL,
+    starts_at DATETIME(6) NOT NULL,
+    exceptions JSON NOT NULL,
+    UNIQUE KEY uk_event_template_id (id)
+);
+
+CREATE TABLE template_occurrences (
+    _id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    _created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    _updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    _status VARCHAR(100) DEFAULT 'active',
+    template_id BIGINT NOT NULL,
+    occurs_at DATETIME(6) NOT NULL,
+    event_id VARCHAR(100) NOT NULL,
+    UNIQUE KEY uk_template_occurrence (template_id, occurs_at),
+    CONSTRAINT fk_template_occurrences_template_id FOREIGN KEY (template_id) REFERENCES event_templates(_id)
+);
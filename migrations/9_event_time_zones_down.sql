@@ -0,0 +1,3 @@
+ALTER TABLE events
+    DROP COLUMN utc_offset,
+    DROP COLUMN time_zone;
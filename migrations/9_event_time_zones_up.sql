@@ -0,0 +1,3 @@
+ALTER TABLE events
+    ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '' AFTER created_at,
+    ADD COLUMN utc_offset INT NOT NULL DEFAULT 0 AFTER time_zone;
@@ -23,14 +23,20 @@ type DbRecord struct {
 }
 
 // Event is the Db model to represent the event in a person's life.
+// UserCreatedAt is kept in UTC, with TimeZone, the IANA zone if the client sent one, and UTCOffset, in seconds,
+// telling what the local time was. Links, from and to the event, and custom Fields are left out when there are none.
 type Event struct {
 	DbRecord
-	ID            string      `json:"id"`
-	Title         string      `json:"title"`
-	Note          string      `json:"note"`
-	UserCreatedAt time.Time   `json:"created_at"`
-	Type          *EventType  `json:"type"`
-	Tags          []*EventTag `json:"tags"`
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Note          string       `json:"note"`
+	UserCreatedAt time.Time    `json:"created_at"`
+	TimeZone      string       `json:"time_zone"`
+	UTCOffset     int          `json:"utc_offset"`
+	Type          *EventType   `json:"type"`
+	Tags          []*EventTag  `json:"tags"`
+	Links         []*EventLink `json:"links,omitempty"`
+	Fields        EventFields  `json:"fields,omitempty"`
 }
 
 // EventType is the Db model for the type of the event. Can be start/end/distraction or anything else.
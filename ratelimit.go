@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultRateLimit       = "10:20"
+	defaultRouteRateLimits = "POST " + routeCreateEvent + "=2:10,POST " + routeSync + "=1:5"
+	defaultMaxBodyBytes    = 1 << 20
+
+	// buckets idle for this long are full again, or close to it, so are dropped to save memory
+	rateLimitIdleTime      = 10 * time.Minute
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimit is a token bucket, refilled at Rate tokens a second up to Burst tokens. A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// parseRateLimit reads a limit written as rate:burst, like 10:20. Just 0 turns limiting off.
+func parseRateLimit(raw string) (RateLimit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "0" {
+		return RateLimit{}, nil
+	}
+
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("expected rate:burst, got %q", raw)
+	}
+
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate %q", parts[0])
+	}
+
+	burst, err := strconv.Atoi(parts[1])
+	if err != nil || burst <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid burst %q", parts[1])
+	}
+
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// parseRouteRateLimits reads limits for single routes, written as METHOD /route=rate:burst, separated by commas.
+// Routes are as registered, so /events/{eventID} and not /events/1.
+func parseRouteRateLimits(raw string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, rawLimit, ok := strings.Cut(entry, "=")
+		fields := strings.Fields(route)
+		if !ok || len(fields) != 2 {
+			return nil, fmt.Errorf("expected METHOD /route=rate:burst, got %q", entry)
+		}
+
+		limit, err := parseRateLimit(rawLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		limits[rateLimitRouteKey(fields[0], fields[1])] = limit
+	}
+
+	return limits, nil
+}
+
+func rateLimitRouteKey(method, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
+
+// RateLimitConfig is the limit for every route, and the routes limited differently
+type RateLimitConfig struct {
+	Default RateLimit
+	Routes  map[string]RateLimit
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client and route. Safe for concurrent use.
+type rateLimiter struct {
+	mu      sync.Mutex
+	config  RateLimitConfig
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func (limiter *rateLimiter) limitFor(method, route string) RateLimit {
+	if limit, ok := limiter.config.Routes[rateLimitRouteKey(method, route)]; ok {
+		return limit
+	}
+
+	return limiter.config.Default
+}
+
+// allow takes a token from the bucket for key. If there is none, it returns how long until there is.
+func (limiter *rateLimiter) allow(key string, limit RateLimit) (bool, time.Duration) {
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		limiter.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := (1 - bucket.tokens) / limit.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// sweep drops the buckets not used for idle
+func (limiter *rateLimiter) sweep(idle time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.last) > idle {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// rateLimitClientKey identifies who is making the request, by the user their bearer token was checked for, else
+// their address. It goes by the actor AuthMiddleware set, so a made up token doesn't get a bucket of its own.
+func rateLimitClientKey(r *http.Request) string {
+	if actor := actorFromContext(r.Context()); !isAnonymousActor(actor) {
+		return "user:" + actor
+	}
+
+	return rateLimitAddressKey(r)
+}
+
+func rateLimitAddressKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// RateLimitMiddleware throttles each client on each route, responding with 429 and a Retry-After once
+// they run out of tokens. Has to be used on the router, so that the matched route is known.
+func RateLimitMiddleware(limiter *rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			client := rateLimitClientKey(r)
+			allowed, retryAfter := limiter.allow(client+" "+rateLimitRouteKey(r.Method, route), limiter.limitFor(r.Method, route))
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rateLimited(w, r, client, route, retryAfter)
+		})
+	}
+}
+
+// AuthRateLimitMiddleware throttles, by address, requests with a bearer token before the token is looked up,
+// so that made up tokens can't keep the db busy. It goes ahead of AuthMiddleware, at the default limit.
+func AuthRateLimitMiddleware(limiter *rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearerToken(r) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := rateLimitAddressKey(r)
+			allowed, retryAfter := limiter.allow(client+" auth", limiter.config.Default)
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rateLimited(w, r, client, r.URL.Path, retryAfter)
+		})
+	}
+}
+
+// rateLimited responds with 429, telling the client when to try again
+func rateLimited(w http.ResponseWriter, r *http.Request, client, route string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	loggerFromContext(r.Context()).Warn("rate limited", "client", client, "method", r.Method, "route", route, "retry_after", seconds)
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	handleHTTPFailure(w, http.StatusTooManyRequests, nil, &ResponseError{
+		Code:    errCodeRateLimited,
+		Message: fmt.Sprintf("too many requests, retry after %d seconds", seconds),
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestTokenBucket(t *testing.T) {
+	fn := "TestTokenBucket"
+	limiter := newRateLimiter(RateLimitConfig{})
+	limit := RateLimit{Rate: 1, Burst: 2}
+
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.allow("a", limit)
+		util.Test.AssertEquals(t, true, allowed, fn+": Burst not allowed")
+	}
+
+	allowed, retryAfter := limiter.allow("a", limit)
+	util.Test.AssertEquals(t, false, allowed, fn+": Allowed past burst")
+	util.Test.AssertEquals(t, time.Second, retryAfter, fn+": Wrong retry after")
+
+	allowed, _ = limiter.allow("b", limit)
+	util.Test.AssertEquals(t, true, allowed, fn+": Other key limited")
+
+	now = now.Add(time.Second)
+	allowed, _ = limiter.allow("a", limit)
+	util.Test.AssertEquals(t, true, allowed, fn+": Not refilled")
+
+	now = now.Add(rateLimitIdleTime + time.Second)
+	limiter.sweep(rateLimitIdleTime)
+	util.Test.AssertEquals(t, 0, len(limiter.buckets), fn+": Idle buckets not swept")
+}
+
+func TestParseRouteRateLimits(t *testing.T) {
+	fn := "TestParseRouteRateLimits"
+
+	limits, err := parseRouteRateLimits("post /event=2:10, GET /events/{eventID}=0.5:1")
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, RateLimit{Rate: 2, Burst: 10}, limits["POST /event"], fn+": Wrong limit for create")
+	util.Test.AssertEquals(t, RateLimit{Rate: 0.5, Burst: 1}, limits["GET /events/{eventID}"], fn+": Wrong limit for get")
+
+	for _, raw := range []string{"POST /event", "/event=2:10", "POST /event=2", "POST /event=-1:10"} {
+		_, err = parseRouteRateLimits(raw)
+		util.Test.AssertEquals(t, true, err != nil, fn+": Expected error for "+raw)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	fn := "TestRateLimitMiddleware"
+
+	limiter := newRateLimiter(RateLimitConfig{
+		Default: RateLimit{Rate: 100, Burst: 100},
+		Routes:  map[string]RateLimit{"GET " + routeGetEvent: {Rate: 0.5, Burst: 1}},
+	})
+
+	router := mux.NewRouter()
+	router.Use(RateLimitMiddleware(limiter))
+	router.HandleFunc(routeGetEvent, func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	codes := make([]int, 0)
+	for _, eventID := range []string{"1", "2"} {
+		req := httptest.NewRequest(http.MethodGet, "/events/"+eventID, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+
+		if rr.Code == http.StatusTooManyRequests {
+			util.Test.AssertEquals(t, "2", rr.Header().Get("Retry-After"), fn+": Wrong Retry-After")
+		}
+	}
+	util.Test.AssertEquals(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes, fn+": Route limit not applied across event ids")
+
+	req := httptest.NewRequest(http.MethodGet, "/events/3", nil)
+	req.Header.Set("Authorization", "Bearer made-up")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusTooManyRequests, rr.Code, fn+": Unchecked token got a bucket of its own")
+
+	req = httptest.NewRequest(http.MethodGet, "/events/3", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyActor, "bob"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Other user limited")
+}
+
+// countingAuthenticator knows no token, and counts how many it was asked about
+type countingAuthenticator struct {
+	lookups int
+}
+
+func (authenticator *countingAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	authenticator.lookups++
+	return "", nil
+}
+
+func TestAuthRateLimitMiddleware(t *testing.T) {
+	fn := "TestAuthRateLimitMiddleware"
+
+	limiter := newRateLimiter(RateLimitConfig{Default: RateLimit{Rate: 0.5, Burst: 1}})
+	authenticator := &countingAuthenticator{}
+	handler := AuthRateLimitMiddleware(limiter)(AuthMiddleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	codes := make([]int, 0)
+	for _, token := range []string{"made-up-1", "made-up-2"} {
+		req := httptest.NewRequest(http.MethodGet, routeGetEvents, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+	util.Test.AssertEquals(t, []int{http.StatusUnauthorized, http.StatusTooManyRequests}, codes, fn+": Token lookups not limited")
+	util.Test.AssertEquals(t, 1, authenticator.lookups, fn+": Wrong number of lookups")
+
+	req := httptest.NewRequest(http.MethodGet, routeGetEvents, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Request without a token limited")
+}
+
+func TestMaxBytesMiddleware(t *testing.T) {
+	fn := "TestMaxBytesMiddleware"
+
+	router := mux.NewRouter()
+	env := &env{EventsHandler: &TestEventHandler{}}
+	router.HandleFunc(routeCreateEvent, CreateEventHandler(env)).Methods(http.MethodPost)
+	handler := MaxBytesMiddleware(16, nil)(router)
+
+	req := httptest.NewRequest(http.MethodPost, routeCreateEvent, strings.NewReader(GetTestEventJSON("")))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusRequestEntityTooLarge, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), "larger than 16 bytes"), fn+": Wrong message")
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditActionPurge   = "purge"
+	auditActionArchive = "archive"
+
+	actorRetention = "retention"
+
+	defaultRetentionInterval         = 24 * time.Hour
+	defaultRetentionDeletedDays      = 30
+	defaultRetentionBatchSize        = 100
+	defaultRetentionPurgeOrphanTags  = true
+	defaultRetentionPurgeOrphanBlobs = true
+)
+
+var (
+	retentionDeletedCondition = fmt.Sprintf(
+		"E.%s = '%s' AND E.%s < CURRENT_TIMESTAMP(6) - INTERVAL ? DAY",
+		colStatus, statusDeleted, colUpdatedAt)
+
+	// user times are stored in UTC
+	retentionArchiveCondition = fmt.Sprintf(
+		"E.%s = '%s' AND E.%s < UTC_TIMESTAMP(6) - INTERVAL ? YEAR",
+		colStatus, statusActive, eventsColCreatedAt)
+
+	// a tag is only left alone for a day after being created, so that one just created by a transaction which
+	// is yet to map it isn't taken for an orphan
+	retentionOrphanTagCondition = fmt.Sprintf(
+		"NOT EXISTS (SELECT 1 FROM %s M WHERE M.%s = T.%s) AND T.%s < CURRENT_TIMESTAMP(6) - INTERVAL 1 DAY",
+		eventTagMapTableName, eventTagMapColTagID, colDbID, colCreatedAt)
+)
+
+// RetentionPolicy says what data is removed for good, and when. Zero values turn a rule off.
+type RetentionPolicy struct {
+	// DeletedEventsAfterDays is how long soft deleted events are kept around as tombstones for syncing clients
+	DeletedEventsAfterDays int
+	// ArchiveAfterYears is how old, by their user time, events get before being moved to the archive
+	ArchiveAfterYears int
+	PurgeOrphanTags   bool
+	// PurgeOrphanBlobs removes attachment content no attachment refers to anymore, like that of purged events
+	PurgeOrphanBlobs bool
+	// BatchSize is how many rows are looked at in one go
+	BatchSize int
+}
+
+// enabled tells if any rule is on
+func (policy RetentionPolicy) enabled() bool {
+	return policy.DeletedEventsAfterDays > 0 || policy.ArchiveAfterYears > 0 || policy.PurgeOrphanTags || policy.PurgeOrphanBlobs
+}
+
+// RetentionReport represents the response to send to client, in case of a retention run.
+// On a dry run, it is what would have been removed.
+type RetentionReport struct {
+	DryRun         bool  `json:"dry_run"`
+	PurgedEvents   int64 `json:"purged_events"`
+	PurgedTags     int64 `json:"purged_tags"`
+	PurgedBlobs    int64 `json:"purged_blobs"`
+	ArchivedEvents int64 `json:"archived_events"`
+}
+
+// applyRetention applies the policy to the db, then removes the blobs left without attachments if there is a blob store
+func applyRetention(ctx context.Context, handler IEventsHandler, blobs BlobStore, policy RetentionPolicy, dryRun bool) (*RetentionReport, error) {
+	fn := "applyRetention"
+
+	report, err := handler.ApplyRetention(ctx, policy, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.PurgeOrphanBlobs && blobs != nil {
+		report.PurgedBlobs, err = purgeOrphanBlobs(ctx, handler, blobs, policy.BatchSize, dryRun)
+		if err != nil {
+			return nil, deepError.New(fn, "purge orphan blobs", err)
+		}
+	}
+
+	return report, nil
+}
+
+// runRetention applies the policy every interval, till ctx is done
+func runRetention(ctx context.Context, handler IEventsHandler, blobs BlobStore, policy RetentionPolicy, interval time.Duration, logger *slog.Logger) {
+	ctx = context.WithValue(ctx, ctxKeyActor, actorRetention)
+	ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("worker", "retention"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := applyRetention(ctx, handler, blobs, policy, false)
+		if err != nil {
+			logger.Error("retention run failed", errorAttr(err))
+			continue
+		}
+
+		logger.Info("retention run done", "purged_events", report.PurgedEvents, "purged_tags", report.PurgedTags, "purged_blobs", report.PurgedBlobs, "archived_events", report.ArchivedEvents)
+	}
+}
+
+// ApplyRetention removes what the policy says is no longer needed, each event or tag in its own transaction.
+// With dryRun, nothing is removed and the report counts what would be.
+func (handler *EventsHandler) ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error) {
+	fn := "ApplyRetention"
+	logger := loggerFromContext(ctx).With("fn", fn, "dry_run", dryRun)
+
+	report := &RetentionReport{DryRun: dryRun}
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = defaultRetentionBatchSize
+	}
+
+	var err error
+	if policy.DeletedEventsAfterDays > 0 {
+		report.PurgedEvents, err = handler.removeEvents(ctx, retentionDeletedCondition, policy.DeletedEventsAfterDays, auditActionPurge, policy.BatchSize, dryRun)
+		if err != nil {
+			logger.Error("purge deleted events failed", errorAttr(err))
+			return nil, deepError.New(fn, "purge deleted events", err)
+		}
+	}
+
+	if policy.ArchiveAfterYears > 0 {
+		report.ArchivedEvents, err = handler.removeEvents(ctx, retentionArchiveCondition, policy.ArchiveAfterYears, auditActionArchive, policy.BatchSize, dryRun)
+		if err != nil {
+			logger.Error("archive events failed", errorAttr(err))
+			return nil, deepError.New(fn, "archive events", err)
+		}
+	}
+
+	if policy.PurgeOrphanTags {
+		report.PurgedTags, err = handler.purgeOrphanTags(ctx, policy.BatchSize, dryRun)
+		if err != nil {
+			logger.Error("purge orphan tags failed", errorAttr(err))
+			return nil, deepError.New(fn, "purge orphan tags", err)
+		}
+	}
+
+	return report, nil
+}
+
+// removeEvents removes, in batches, the events matching condition, archiving them first if action is archive
+func (handler *EventsHandler) removeEvents(ctx context.Context, condition string, age int, action string, batchSize int, dryRun bool) (int64, error) {
+	fn := "removeEvents"
+
+	if dryRun {
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s E WHERE %s", eventsTableName, condition)
+		return handler.dbStuff.count(ctx, query, age)
+	}
+
+	var removed int64
+	for {
+		eventIDs, err := handler.dbStuff.findEventIDs(ctx, condition, age, batchSize)
+		if err != nil {
+			return removed, deepError.New(fn, "find event ids", err)
+		}
+
+		for _, eventID := range eventIDs {
+			var eventRemoved bool
+			err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+				var err error
+				eventRemoved, err = removeEvent(ctx, txStuff, eventID, condition, age, action)
+				return err
+			})
+			if err != nil {
+				return removed, deepError.New(fn, "remove event "+eventID, err)
+			}
+			if eventRemoved {
+				removed++
+			}
+		}
+
+		if len(eventIDs) < batchSize {
+			return removed, nil
+		}
+	}
+}
+
+// removeEvent removes a single event with all that refers to it, except its audit log. The blobs of its attachments
+// are left for purgeOrphanBlobs.
+// The event is locked and checked against condition again, as it could have been restored or edited meanwhile.
+// It tells if the event was removed, it isn't when it no longer matches.
+func removeEvent(ctx context.Context, txStuff *dbStuff, eventID, condition string, age int, action string) (bool, error) {
+	fn := "removeEvent"
+
+	event, err := txStuff.lockEventIfMatches(ctx, eventID, condition, age)
+	if err != nil {
+		return false, deepError.New(fn, "lock event", err)
+	}
+	if event == nil {
+		return false, nil
+	}
+
+	err = loadEventDetails(ctx, txStuff, event)
+	if err != nil {
+		return false, deepError.New(fn, "load event details", err)
+	}
+
+	if action == auditActionArchive {
+		err = txStuff.insertArchivedEvent(ctx, event)
+		if err != nil {
+			return false, deepError.New(fn, "insert archived event", err)
+		}
+	}
+
+	err = txStuff.deleteEventTagMappings(ctx, event.DbID)
+	if err != nil {
+		return false, deepError.New(fn, "delete event tag mappings", err)
+	}
+
+	// before its links go, while the events on their other end can still be found
+	err = txStuff.touchEventsLinkedTo(ctx, event.DbID)
+	if err != nil {
+		return false, deepError.New(fn, "touch linked events", err)
+	}
+
+	for _, query := range []string{
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventRevisionsTableName, eventRevisionsColEventID),
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventLinksTableName, eventLinksColFromEventID),
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventLinksTableName, eventLinksColToEventID),
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventAttachmentsTableName, eventAttachmentsColEventID),
+	} {
+		_, err = txStuff.exec(ctx, query, event.DbID)
+		if err != nil {
+			return false, deepError.New(fn, "delete", err)
+		}
+	}
+
+	res, err := txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventsTableName, colDbID), event.DbID)
+	if err != nil {
+		return false, deepError.New(fn, "delete event", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, deepError.New(fn, "rows affected", err)
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+
+	err = recordAudit(ctx, txStuff, auditEntityEvent, event.ID, action, newEventSnapshot(event), nil)
+	if err != nil {
+		return false, deepError.New(fn, "record audit", err)
+	}
+
+	return true, nil
+}
+
+// purgeOrphanTags removes, in batches, tags no event is tagged with
+func (handler *EventsHandler) purgeOrphanTags(ctx context.Context, batchSize int, dryRun bool) (int64, error) {
+	fn := "purgeOrphanTags"
+
+	if dryRun {
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s T WHERE %s", eventTagsTableName, retentionOrphanTagCondition)
+		return handler.dbStuff.count(ctx, query)
+	}
+
+	var purged int64
+	for {
+		tagIDs, err := handler.dbStuff.findOrphanTagIDs(ctx, batchSize)
+		if err != nil {
+			return purged, deepError.New(fn, "find orphan tag ids", err)
+		}
+
+		for _, tagID := range tagIDs {
+			var tagPurged bool
+			err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+				var err error
+				tagPurged, err = purgeOrphanTag(ctx, txStuff, tagID)
+				return err
+			})
+			if err != nil {
+				return purged, deepError.New(fn, "purge tag", err)
+			}
+			if tagPurged {
+				purged++
+			}
+		}
+
+		if len(tagIDs) < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purgeOrphanTag removes a tag if no event is tagged with it yet, and tells if it did
+func purgeOrphanTag(ctx context.Context, txStuff *dbStuff, tagID int64) (bool, error) {
+	fn := "purgeOrphanTag"
+
+	query := fmt.Sprintf(`
+		SELECT T.%s, T.%s, T.%s, T.%s, T.%s
+		FROM %s T
+		WHERE T.%s = ? AND %s
+		FOR UPDATE`,
+		colDbID, eventTagsColValue, colCreatedAt, colUpdatedAt, colStatus,
+		eventTagsTableName,
+		colDbID, retentionOrphanTagCondition)
+
+	rows, err := txStuff.query(ctx, query, tagID)
+	if err != nil {
+		return false, deepError.New(fn, "query", err)
+	}
+
+	var eventTag *EventTag
+	if rows.Next() {
+		eventTag = &EventTag{}
+		err = rows.Scan(&eventTag.DbID, &eventTag.Value, &eventTag.CreatedAt, &eventTag.UpdatedAt, &eventTag.Status)
+	}
+	rows.Close()
+	if err != nil {
+		return false, deepError.New(fn, "scan", err)
+	}
+	if eventTag == nil {
+		return false, nil
+	}
+
+	res, err := txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", eventTagsTableName, colDbID), tagID)
+	if err != nil {
+		return false, deepError.New(fn, "delete", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, deepError.New(fn, "rows affected", err)
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+	txStuff.invalidateEventTag(eventTag)
+
+	err = recordAudit(ctx, txStuff, auditEntityEventTag, eventTag.Value, auditActionPurge, eventTag, nil)
+	if err != nil {
+		return false, deepError.New(fn, "record audit", err)
+	}
+
+	return true, nil
+}
+
+func (dbStuff *dbStuff) count(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	fn := "count"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, args...)
+	if err != nil {
+		return -1, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	var count int64
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return -1, deepError.New(fn, "scan", err)
+		}
+	}
+
+	return count, nil
+}
+
+func (dbStuff *dbStuff) findEventIDs(ctx context.Context, condition string, age, limit int) ([]string, error) {
+	fn := "findEventIDs"
+
+	query := fmt.Sprintf(
+		"SELECT E.%s FROM %s E WHERE %s ORDER BY E.%s LIMIT ?",
+		eventsColID, eventsTableName, condition, colDbID)
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, age, limit)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	eventIDs := make([]string, 0)
+	for rows.Next() {
+		var eventID string
+		err = rows.Scan(&eventID)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		eventIDs = append(eventIDs, eventID)
+	}
+
+	return eventIDs, nil
+}
+
+func (dbStuff *dbStuff) findOrphanTagIDs(ctx context.Context, limit int) ([]int64, error) {
+	fn := "findOrphanTagIDs"
+
+	query := fmt.Sprintf(
+		"SELECT T.%s FROM %s T WHERE %s ORDER BY T.%s LIMIT ?",
+		colDbID, eventTagsTableName, retentionOrphanTagCondition, colDbID)
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, limit)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	tagIDs := make([]int64, 0)
+	for rows.Next() {
+		var tagID int64
+		err = rows.Scan(&tagID)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	return tagIDs, nil
+}
+
+// lockEventIfMatches reads an event for update, only if it still matches condition
+func (dbStuff *dbStuff) lockEventIfMatches(ctx context.Context, eventID, condition string, age int) (*Event, error) {
+	fn := "lockEventIfMatches"
+
+	query := fmt.Sprintf(`
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE E.%s = ? AND %s
+		FOR UPDATE`,
+		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields,
+		eventsTableName,
+		eventsColID, condition)
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, eventID, age)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		event := &Event{Type: &EventType{}}
+		err = rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+			&event.TimeZone, &event.UTCOffset, &event.Fields)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		return event, nil
+	}
+
+	return nil, nil
+}
+
+func (dbStuff *dbStuff) insertArchivedEvent(ctx context.Context, event *Event) error {
+	fn := "insertArchivedEvent"
+
+	snapshot, err := json.Marshal(newEventSnapshot(event))
+	if err != nil {
+		return deepError.New(fn, "marshal snapshot", err)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)",
+		eventsArchiveTableName, eventsArchiveColEventID, eventsArchiveColCreatedAt, eventsArchiveColSnapshot)
+
+	_, err = dbStuff.exec(ctx, query, event.ID, event.UserCreatedAt, string(snapshot))
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return nil
+}
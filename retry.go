@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysql error numbers worth retrying, the whole transaction is rolled back by mysql for these
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// mysqlErrNoReferencedRow is a foreign key pointing to a row which is gone. Only the statement fails.
+const mysqlErrNoReferencedRow = 1452
+
+// RetryPolicy decides how many times and how far apart a failed db operation is tried again
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Do runs op until it succeeds, fails with a non transient error, runs out of attempts or ctx is done.
+func (policy RetryPolicy) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	return policy.do(ctx, op, isTransientError)
+}
+
+func (policy RetryPolicy) do(ctx context.Context, op func(ctx context.Context) error, retryable func(error) bool) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = op(ctx)
+		if err == nil || !retryable(err) || attempt+1 >= policy.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is exponential in the attempt, capped at MaxDelay, with jitter so that
+// clients failing together don't retry together
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay << uint(attempt)
+	if delay <= 0 || (policy.MaxDelay > 0 && delay > policy.MaxDelay) {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isTransientError is true for errors which could go away by just trying again,
+// like a dropped connection or a deadlock.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if isLockError(err) {
+		return true
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// isLockError is true if mysql gave up on the statement because of a deadlock or lock wait timeout
+func isLockError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	return false
+}
+
+// isForeignKeyError is true if a row was refused for referring to one which doesn't exist, looking through the
+// causes of DeepErrors
+func isForeignKeyError(err error) bool {
+	for ; err != nil; err = errorCause(err) {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			return mysqlErr.Number == mysqlErrNoReferencedRow
+		}
+	}
+
+	return false
+}
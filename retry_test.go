@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jforcode/Go-DeepError"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestRetryTransientErrors(t *testing.T) {
+	fn := "TestRetryTransientErrors"
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return &mysql.MySQLError{Number: mysqlErrDeadlock, Message: "Deadlock found"}
+		}
+		return nil
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 3, attempts, fn+": Deadlock not retried")
+
+	attempts = 0
+	err = policy.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return driver.ErrBadConn
+	})
+	util.Test.AssertEquals(t, driver.ErrBadConn, err, fn+": Wrong error after giving up")
+	util.Test.AssertEquals(t, 3, attempts, fn+": Bad connection not retried till max attempts")
+}
+
+func TestIsForeignKeyError(t *testing.T) {
+	fn := "TestIsForeignKeyError"
+
+	fkErr := &mysql.MySQLError{Number: mysqlErrNoReferencedRow, Message: "Cannot add or update a child row"}
+	util.Test.AssertEquals(t, true, isForeignKeyError(deepError.New("insert", "exec", fkErr)), fn+": Wrapped error not found")
+	util.Test.AssertEquals(t, false, isForeignKeyError(&mysql.MySQLError{Number: 1062}), fn+": Duplicate taken for a foreign key error")
+	util.Test.AssertEquals(t, false, isForeignKeyError(nil), fn+": Nil taken for a foreign key error")
+}
+
+func TestRetryPermanentErrors(t *testing.T) {
+	fn := "TestRetryPermanentErrors"
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	permanentErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	attempts := 0
+	err := policy.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return permanentErr
+	})
+
+	util.Test.AssertEquals(t, true, errors.Is(err, permanentErr), fn+": Wrong error")
+	util.Test.AssertEquals(t, 1, attempts, fn+": Permanent error retried")
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+var (
+	queryGetEventRevisions = fmt.Sprintf(`
+		SELECT R.%s, R.%s, R.%s, R.%s
+		FROM %s R
+		JOIN %s E ON E.%s = R.%s
+		WHERE E.%s = ?
+		ORDER BY R.%s`,
+		eventRevisionsColRevision, eventRevisionsColSnapshot, eventRevisionsColActor, colCreatedAt,
+		eventRevisionsTableName,
+		eventsTableName, colDbID, eventRevisionsColEventID,
+		eventsColID,
+		eventRevisionsColRevision)
+
+	queryGetEventRevision = fmt.Sprintf(`
+		SELECT R.%s, R.%s, R.%s, R.%s
+		FROM %s R
+		JOIN %s E ON E.%s = R.%s
+		WHERE E.%s = ? AND R.%s = ?`,
+		eventRevisionsColRevision, eventRevisionsColSnapshot, eventRevisionsColActor, colCreatedAt,
+		eventRevisionsTableName,
+		eventsTableName, colDbID, eventRevisionsColEventID,
+		eventsColID, eventRevisionsColRevision)
+
+	queryGetLatestRevision = fmt.Sprintf(`
+		SELECT COALESCE(MAX(R.%s), 0)
+		FROM %s R
+		WHERE R.%s = ?`,
+		eventRevisionsColRevision,
+		eventRevisionsTableName,
+		eventRevisionsColEventID)
+
+	errRevisionIsDeletion = newBadRequestError("Revision is a deletion, restore an earlier one")
+)
+
+// EventRevision is the Db model for a full copy of an event as it was after one of its writes
+type EventRevision struct {
+	Revision  int            `json:"revision"`
+	Event     *EventSnapshot `json:"event"`
+	Actor     string         `json:"actor"`
+	RevisedAt time.Time      `json:"revised_at"`
+}
+
+// EventRevisionsResponse represents the response to send to client, in case of a get event revisions
+type EventRevisionsResponse struct {
+	Revisions []*EventRevision `json:"revisions"`
+}
+
+// EventRevisionResponse represents the response to send to client, in case of a get event revision
+type EventRevisionResponse struct {
+	Revision *EventRevision `json:"revision"`
+}
+
+// recordRevision stores the event as it is after a write, as its next revision. Events written before revisions
+// were kept get their previous state stored first, so that it can be restored too.
+// Has to be called after the event row is written in the same transaction, which keeps it locked till commit.
+func recordRevision(ctx context.Context, txStuff *dbStuff, before, after *Event) error {
+	fn := "recordRevision"
+
+	latest, err := txStuff.findLatestRevision(ctx, after.DbID)
+	if err != nil {
+		return deepError.New(fn, "find latest revision", err)
+	}
+
+	if latest == 0 && before != nil {
+		latest++
+		err = txStuff.insertEventRevision(ctx, after.DbID, latest, newEventSnapshot(before))
+		if err != nil {
+			return deepError.New(fn, "insert previous revision", err)
+		}
+	}
+
+	err = txStuff.insertEventRevision(ctx, after.DbID, latest+1, newEventSnapshot(after))
+	if err != nil {
+		return deepError.New(fn, "insert revision", err)
+	}
+
+	return nil
+}
+
+func (dbStuff *dbStuff) findLatestRevision(ctx context.Context, eventDbID int64) (int, error) {
+	fn := "findLatestRevision"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetLatestRevision, eventDbID)
+	if err != nil {
+		return -1, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	latest := 0
+	if rows.Next() {
+		err = rows.Scan(&latest)
+		if err != nil {
+			return -1, deepError.New(fn, "scan", err)
+		}
+	}
+
+	return latest, nil
+}
+
+func (dbStuff *dbStuff) insertEventRevision(ctx context.Context, eventDbID int64, revision int, snapshot *EventSnapshot) error {
+	fn := "insertEventRevision"
+
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return deepError.New(fn, "marshal snapshot", err)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)",
+		eventRevisionsTableName, eventRevisionsColEventID, eventRevisionsColRevision, eventRevisionsColSnapshot, eventRevisionsColActor)
+
+	_, err = dbStuff.exec(ctx, query, eventDbID, revision, string(snapshotJSON), actorFromContext(ctx))
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return nil
+}
+
+func (dbStuff *dbStuff) findEventRevisions(ctx context.Context, eventID string) ([]*EventRevision, error) {
+	fn := "findEventRevisions"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetEventRevisions, eventID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]*EventRevision, 0)
+	for rows.Next() {
+		revision, err := scanEventRevision(rows)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (dbStuff *dbStuff) findEventRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error) {
+	fn := "findEventRevision"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetEventRevision, eventID, revision)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		eventRevision, err := scanEventRevision(rows)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		return eventRevision, nil
+	}
+
+	return nil, nil
+}
+
+func scanEventRevision(rows *sql.Rows) (*EventRevision, error) {
+	revision := &EventRevision{Event: &EventSnapshot{}}
+	var snapshot []byte
+
+	err := rows.Scan(&revision.Revision, &snapshot, &revision.Actor, &revision.RevisedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(snapshot, revision.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// GetEventRevisions returns all revisions of an event, oldest first. Returns nil if the event never existed.
+func (handler *EventsHandler) GetEventRevisions(ctx context.Context, eventID string) ([]*EventRevision, error) {
+	fn := "GetEventRevisions"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", eventID)
+
+	event, err := handler.dbStuff.findEventByID(ctx, eventID)
+	if err != nil {
+		logger.Error("find event failed", errorAttr(err))
+		return nil, deepError.New(fn, "find event", err)
+	}
+	if event == nil {
+		return nil, nil
+	}
+
+	revisions, err := handler.dbStuff.findEventRevisions(ctx, eventID)
+	if err != nil {
+		logger.Error("find revisions failed", errorAttr(err))
+		return nil, deepError.New(fn, "find revisions", err)
+	}
+
+	return revisions, nil
+}
+
+// GetEventRevision returns a single revision of an event, nil if there is no such revision
+func (handler *EventsHandler) GetEventRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error) {
+	fn := "GetEventRevision"
+
+	eventRevision, err := handler.dbStuff.findEventRevision(ctx, eventID, revision)
+	if err != nil {
+		loggerFromContext(ctx).Error("find revision failed", "fn", fn, "event_id", eventID, "revision", revision, errorAttr(err))
+		return nil, deepError.New(fn, "find revision", err)
+	}
+
+	return eventRevision, nil
+}
+
+// RestoreEventRevision writes the event as it was in the given revision, which becomes its newest revision.
+// A deleted event is brought back this way. Returns nil if there is no such revision.
+func (handler *EventsHandler) RestoreEventRevision(ctx context.Context, eventID string, revision int) (*Event, error) {
+	fn := "RestoreEventRevision"
+	logger := loggerFromContext(ctx).With("fn", fn, "event_id", eventID, "revision", revision)
+
+	// revisions never change once written, so it is safe to read outside the transaction
+	eventRevision, err := handler.dbStuff.findEventRevision(ctx, eventID, revision)
+	if err != nil {
+		logger.Error("find revision failed", errorAttr(err))
+		return nil, deepError.New(fn, "find revision", err)
+	}
+	if eventRevision == nil {
+		return nil, nil
+	}
+	if eventRevision.Event.Status == statusDeleted {
+		return nil, errRevisionIsDeletion
+	}
+
+	restored := eventFromSnapshot(eventID, eventRevision.Event)
+	err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		before, err := txStuff.findEventByID(ctx, eventID)
+		if err != nil {
+			return deepError.New(fn, "find event", err)
+		}
+
+		err = loadEventDetails(ctx, txStuff, before)
+		if err != nil {
+			return deepError.New(fn, "load event details", err)
+		}
+
+		restored.DbRecord = before.DbRecord
+		err = updateEventWithTags(ctx, txStuff, auditActionRestore, before, restored)
+		if err != nil {
+			return deepError.New(fn, "update event", err)
+		}
+
+		if before.Status == statusDeleted {
+			err = txStuff.touchEventsLinkedTo(ctx, before.DbID)
+			if err != nil {
+				return deepError.New(fn, "touch linked events", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error("restore failed", errorAttr(err))
+		return nil, deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("event restored")
+	return restored, nil
+}
+
+func eventFromSnapshot(eventID string, snapshot *EventSnapshot) *Event {
+	event := &Event{
+		ID:            eventID,
+		Title:         snapshot.Title,
+		Note:          snapshot.Note,
+		UserCreatedAt: snapshot.UserCreatedAt,
+		TimeZone:      snapshot.TimeZone,
+		UTCOffset:     snapshot.UTCOffset,
+		Type:          &EventType{Value: snapshot.Type},
+		Tags:          make([]*EventTag, 0),
+		Fields:        snapshot.Fields,
+	}
+
+	for _, tag := range snapshot.Tags {
+		event.Tags = append(event.Tags, &EventTag{Value: tag})
+	}
+
+	return event
+}
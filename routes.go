@@ -3,8 +3,13 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -12,21 +17,132 @@ import (
 // HealthCheckHandler is an api route to just check the health of the api.
 func HealthCheckHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleHTTPSuccess(w, "Alive!")
+		handleHTTPSuccess(w, r, "Alive!")
+	}
+}
+
+// LivenessHandler reports that the process is up and serving, without looking at any dependency
+func LivenessHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleHTTPSuccess(w, r, "Alive!")
+	}
+}
+
+// ReadinessHandler checks all dependencies, like the db and its schema version, and reports their status.
+// Responds with 503 if any of them is down, so that no traffic is sent to this instance.
+func ReadinessHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := checkDependencies(r.Context(), env.ReadinessCheckers, env.HealthCheckTimeout)
+		if readiness.Status != readinessStatusReady {
+			down := make([]string, 0)
+			for _, dependency := range readiness.Dependencies {
+				if dependency.Status != dependencyStatusUp {
+					down = append(down, dependency.Name+": "+dependency.Error)
+				}
+			}
+			loggerFromContext(r.Context()).Warn("not ready", "down", down)
+			handleHTTPFailure(w, http.StatusServiceUnavailable, readiness, &ResponseError{
+				Code:    errCodeNotReady,
+				Message: errNotReady.Error(),
+			})
+			return
+		}
+
+		handleHTTPSuccess(w, r, readiness)
 	}
 }
 
 // GetEventsHandler is a route to return all the events available.
+// With any of type, tag (repeated for more), from, to, q or limit, only the matching events are returned,
+// ordered by their time.
 // NOTE: unpaginated. unauthenticated
 func GetEventsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		events, err := env.EventsHandler.GetAllEvents()
+		query := r.URL.Query()
+		filter, filtered, err := parseEventFilter(query)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+		location, err := parseTimeZone(query.Get("tz"))
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		var events []*Event
+		if filtered {
+			events, err = env.EventsHandler.FindEvents(r.Context(), filter)
+		} else {
+			events, err = env.EventsHandler.GetAllEvents(r.Context())
+		}
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		// events dropped from the list since leave the latest change at least as late, where the events left may not be
+		lastChange, err := env.EventsHandler.GetLastChangeTime(r.Context())
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		lastModified := eventsLastModified(events)
+		if lastChange.After(lastModified) {
+			lastModified = lastChange
+		}
+
+		if checkNotModified(w, r, env.CacheControl[routeGetEvents], eventsETag(events, query.Get("tz")), lastModified) {
+			return
+		}
+		if query.Get("tz") != "" {
+			events = eventsInLocation(events, location)
+		}
+
+		handleHTTPSuccess(w, r, EventsResponse{Events: events})
+	}
+}
+
+// GetEventStatsHandler is a route to count events by the hour, weekday or date they happened at. It takes the
+// filters of the event list, and tz, a time zone to bucket all events in, by default each event's own local time.
+func GetEventStatsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, _, err := parseEventFilter(query)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+		filter.Limit = 0
+
+		timeZone := query.Get("tz")
+		if timeZone == tzEvent {
+			timeZone = ""
+		}
+		location, err := parseTimeZone(timeZone)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		bucket := query.Get("bucket")
+		if bucket == "" {
+			bucket = bucketHour
+		}
+
+		events, err := env.EventsHandler.FindEvents(r.Context(), filter)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		buckets, err := bucketEvents(events, bucket, location)
 		if err != nil {
-			handleHTTPError(w, err)
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
 			return
 		}
 
-		handleHTTPSuccess(w, EventsResponse{Events: events})
+		handleHTTPSuccess(w, r, EventStatsResponse{Bucket: bucket, TimeZone: timeZone, Buckets: buckets})
 	}
 }
 
@@ -37,17 +153,113 @@ func GetEventHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		eventID := vars["eventID"]
 
-		event, err := env.EventsHandler.GetEvent(eventID)
+		event, err := env.EventsHandler.GetEvent(r.Context(), eventID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if event == nil {
+			handleHTTPError(w, r, errors.New("Event with ID not found"))
+			return
+		}
+
+		events := []*Event{event}
+		if checkNotModified(w, r, env.CacheControl[routeGetEvent], eventsETag(events, ""), eventsLastModified(events)) {
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventResponse{Event: event})
+	}
+}
+
+// GetEventHistoryHandler is a route to return every recorded change to an event, including its deletion
+func GetEventHistoryHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+
+		history, err := env.EventsHandler.GetEventHistory(r.Context(), eventID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if history == nil {
+			handleHTTPError(w, r, newNotFoundError("Event with ID not found"))
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventHistoryResponse{History: history})
+	}
+}
+
+// GetEventRevisionsHandler is a route to return every stored version of an event, oldest first
+func GetEventRevisionsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+
+		revisions, err := env.EventsHandler.GetEventRevisions(r.Context(), eventID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if revisions == nil {
+			handleHTTPError(w, r, newNotFoundError("Event with ID not found"))
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventRevisionsResponse{Revisions: revisions})
+	}
+}
+
+// GetEventRevisionHandler is a route to return a single stored version of an event
+func GetEventRevisionHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+		revision, err := strconv.Atoi(vars["revision"])
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError("Invalid revision"))
+			return
+		}
+
+		eventRevision, err := env.EventsHandler.GetEventRevision(r.Context(), eventID, revision)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if eventRevision == nil {
+			handleHTTPError(w, r, newNotFoundError("Revision not found"))
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventRevisionResponse{Revision: eventRevision})
+	}
+}
+
+// RestoreEventRevisionHandler is a route to bring an event back to how it was in a revision.
+// The restore is itself stored as a new revision, so it can be undone too.
+func RestoreEventRevisionHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+		revision, err := strconv.Atoi(vars["revision"])
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError("Invalid revision"))
+			return
+		}
+
+		event, err := env.EventsHandler.RestoreEventRevision(r.Context(), eventID, revision)
 		if err != nil {
-			handleHTTPError(w, err)
+			handleHTTPError(w, r, err)
 			return
 		}
 		if event == nil {
-			handleHTTPError(w, errors.New("Event with ID not found"))
+			handleHTTPError(w, r, newNotFoundError("Revision not found"))
 			return
 		}
 
-		handleHTTPSuccess(w, EventResponse{Event: event})
+		handleHTTPSuccess(w, r, EventResponse{Event: event})
 	}
 }
 
@@ -58,22 +270,864 @@ func CreateEventHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
 		var event = &Event{}
 		post, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			handleHTTPError(w, err)
+			handleHTTPError(w, r, err)
 			return
 		}
 
 		err = json.Unmarshal(post, event)
 		if err != nil {
-			handleHTTPError(w, err)
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = validateEvent(event)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+		err = validateEventFields(r.Context(), env.EventsHandler, event)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		eventID, err := env.EventsHandler.CreateEvent(r.Context(), event)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventIDResponse{EventID: eventID})
+	}
+}
+
+// SyncHandler is a route to return the changes to events since the given token, for offline clients to catch up.
+// Clients keep calling it with the returned token until has_more is false.
+func SyncHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		since, err := parseSyncToken(query.Get("since"))
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		limit := defaultSyncLimit
+		if rawLimit := query.Get("limit"); rawLimit != "" {
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil || limit <= 0 || limit > maxSyncLimit {
+				handleHTTPError(w, r, newBadRequestError("limit should be between 1 and "+strconv.Itoa(maxSyncLimit)))
+				return
+			}
+		}
+
+		changes, err := env.EventsHandler.GetChanges(r.Context(), since, limit)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, changes)
+	}
+}
+
+// PushSyncHandler is a route for offline clients to send the changes they made.
+// Changes older than what the server has are not applied, and are reported back as conflicts.
+func PushSyncHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var push = &PushRequest{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, push)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		for index, change := range push.Changes {
+			err = validateEventChange(change)
+			if err != nil {
+				handleHTTPError(w, r, newBadRequestError("change "+strconv.Itoa(index)+": "+err.Error()))
+				return
+			}
+			if change.Deleted {
+				continue
+			}
+			err = validateEventFields(r.Context(), env.EventsHandler, change.Event)
+			if err != nil {
+				handleHTTPError(w, r, err)
+				return
+			}
+		}
+
+		result, err := env.EventsHandler.PushChanges(r.Context(), push.Changes)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, result)
+	}
+}
+
+// parseEventFilter reads the filter for the event list, and whether any was asked for
+func parseEventFilter(query url.Values) (EventFilter, bool, error) {
+	filter := EventFilter{
+		Type:   query.Get("type"),
+		Tags:   query["tag"],
+		Search: query.Get("q"),
+	}
+
+	var err error
+	if rawFrom := query.Get("from"); rawFrom != "" {
+		filter.From, err = time.Parse(time.RFC3339, rawFrom)
+		if err != nil {
+			return filter, false, errors.New("from should be an RFC 3339 time")
+		}
+	}
+	if rawTo := query.Get("to"); rawTo != "" {
+		filter.To, err = time.Parse(time.RFC3339, rawTo)
+		if err != nil {
+			return filter, false, errors.New("to should be an RFC 3339 time")
+		}
+	}
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		filter.Limit, err = strconv.Atoi(rawLimit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxFindEventsLimit {
+			return filter, false, errors.New("limit should be between 1 and " + strconv.Itoa(maxFindEventsLimit))
+		}
+	}
+	filter.Fields, err = parseFieldFilters(query["field"])
+	if err != nil {
+		return filter, false, err
+	}
+
+	filtered := filter.Type != "" || len(filter.Tags) > 0 || !filter.From.IsZero() || !filter.To.IsZero() ||
+		filter.Search != "" || filter.Limit > 0 || len(filter.Fields) > 0
+
+	return filter, filtered, nil
+}
+
+func validateEventChange(change *EventChange) error {
+	switch {
+	case change == nil || change.Event == nil:
+		return errors.New("event is required")
+	case change.ChangedAt.IsZero():
+		return errors.New("changed_at is required")
+	case change.Deleted && change.Event.ID == "":
+		return errors.New("event id is required to delete")
+	case change.Deleted:
+		return nil
+	}
+
+	return validateEvent(change.Event)
+}
+
+func validateEvent(event *Event) error {
+	if event.Type == nil || event.Type.Value == "" {
+		return errors.New("event type is required")
+	}
+
+	return validateTimeZone(event)
+}
+
+// RetentionHandler is an admin route to apply the retention policy right away, only for the admin user.
+// With dry_run=true, it only reports what would be removed.
+func RetentionHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if actorFromContext(r.Context()) != actorAdmin {
+			handleHTTPError(w, r, newForbiddenError("only the admin user can apply retention"))
+			return
+		}
+
+		dryRun := false
+		if rawDryRun := r.URL.Query().Get("dry_run"); rawDryRun != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(rawDryRun)
+			if err != nil {
+				handleHTTPError(w, r, newBadRequestError("dry_run should be true or false"))
+				return
+			}
+		}
+
+		report, err := applyRetention(r.Context(), env.EventsHandler, env.Blobs, env.Retention, dryRun)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, report)
+	}
+}
+
+// CreateTemplateHandler is a route to add a recurring event template
+func CreateTemplateHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var template = &EventTemplate{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, template)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = validateTemplate(template)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		templateID, err := env.EventsHandler.CreateTemplate(r.Context(), template)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TemplateIDResponse{TemplateID: templateID})
+	}
+}
+
+// GetTemplatesHandler is a route to return all the templates
+func GetTemplatesHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := env.EventsHandler.GetTemplates(r.Context())
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TemplatesResponse{Templates: templates})
+	}
+}
+
+// GetTemplateHandler is a route to return a single template
+func GetTemplateHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		templateID := vars["templateID"]
+
+		template, err := env.EventsHandler.GetTemplate(r.Context(), templateID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if template == nil {
+			handleHTTPError(w, r, errTemplateNotFound)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TemplateResponse{Template: template})
+	}
+}
+
+// DeleteTemplateHandler is a route to stop a template from being materialized. Its events are kept.
+func DeleteTemplateHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		templateID := vars["templateID"]
+
+		err := env.EventsHandler.DeleteTemplate(r.Context(), templateID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TemplateIDResponse{TemplateID: templateID})
+	}
+}
+
+// AddTemplateExceptionHandler is a route to skip a single occurrence of a template.
+// If the occurrence was already materialized, its event is deleted.
+func AddTemplateExceptionHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		templateID := vars["templateID"]
+
+		var exception = &TemplateExceptionRequest{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
 			return
 		}
 
-		eventID, err := env.EventsHandler.CreateEvent(event)
+		err = json.Unmarshal(post, exception)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+		if exception.At.IsZero() {
+			handleHTTPError(w, r, newBadRequestError("at is required"))
+			return
+		}
+
+		template, err := env.EventsHandler.AddTemplateException(r.Context(), templateID, exception.At)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TemplateResponse{Template: template})
+	}
+}
+
+// MaterializeTemplatesHandler is a route to create the events of all templates between from and to right away,
+// instead of waiting for the scheduler. Occurrences already materialized are left alone.
+func MaterializeTemplatesHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		from, err := time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError("from should be an RFC 3339 time"))
+			return
+		}
+		to, err := time.Parse(time.RFC3339, query.Get("to"))
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError("to should be an RFC 3339 time"))
+			return
+		}
+
+		report, err := env.EventsHandler.MaterializeTemplates(r.Context(), from, to)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, report)
+	}
+}
+
+// CreateGoalHandler is a route to add a goal
+func CreateGoalHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var goal = &Goal{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, goal)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = validateGoal(goal)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		goalID, err := env.EventsHandler.CreateGoal(r.Context(), goal)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, GoalIDResponse{GoalID: goalID})
+	}
+}
+
+// GetGoalsHandler is a route to return all the goals
+func GetGoalsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		goals, err := env.EventsHandler.GetGoals(r.Context())
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, GoalsResponse{Goals: goals})
+	}
+}
+
+// GetGoalHandler is a route to return a single goal
+func GetGoalHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		goalID := vars["goalID"]
+
+		goal, err := env.EventsHandler.GetGoal(r.Context(), goalID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if goal == nil {
+			handleHTTPError(w, r, errGoalNotFound)
+			return
+		}
+
+		handleHTTPSuccess(w, r, GoalResponse{Goal: goal})
+	}
+}
+
+// DeleteGoalHandler is a route to remove a goal. Its events are left alone.
+func DeleteGoalHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		goalID := vars["goalID"]
+
+		err := env.EventsHandler.DeleteGoal(r.Context(), goalID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, GoalIDResponse{GoalID: goalID})
+	}
+}
+
+// GetGoalProgressHandler is a route to return how far a goal got in the current period and the ones before it,
+// periods of them in all
+func GetGoalProgressHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		goalID := vars["goalID"]
+
+		periods := defaultGoalPeriods
+		if rawPeriods := r.URL.Query().Get("periods"); rawPeriods != "" {
+			var err error
+			periods, err = strconv.Atoi(rawPeriods)
+			if err != nil || periods <= 0 || periods > maxGoalPeriods {
+				handleHTTPError(w, r, newBadRequestError("periods should be between 1 and "+strconv.Itoa(maxGoalPeriods)))
+				return
+			}
+		}
+
+		goal, err := env.EventsHandler.GetGoal(r.Context(), goalID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if goal == nil {
+			handleHTTPError(w, r, errGoalNotFound)
+			return
+		}
+
+		progress, err := computeGoalProgress(r.Context(), env.EventsHandler, goal, periods, time.Now())
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, progress)
+	}
+}
+
+// endDueTimer stops the pomodoro of the caller if it is over, so that timer routes don't wait for the worker
+func endDueTimer(r *http.Request, env *env) (string, error) {
+	owner := timerOwner(r.Context())
+	_, err := env.EventsHandler.EndDueTimers(r.Context(), owner, time.Now())
+	return owner, err
+}
+
+// StartTimerHandler is a route to start the timer of the caller, creating its start event
+func StartTimerHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request = &TimerStartRequest{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, request)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		now := time.Now()
+		event, err := newTimerStartEvent(request, now)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		owner, err := endDueTimer(r, env)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		timer := &Timer{Owner: owner, PomodoroMinutes: request.PomodoroMinutes}
+		err = env.EventsHandler.StartTimer(r.Context(), timer, event)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TimerResponse{Timer: timer.withProgress(now)})
+	}
+}
+
+// PauseTimerHandler is a route to pause the timer of the caller
+func PauseTimerHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, err := endDueTimer(r, env)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		now := time.Now()
+		timer, err := env.EventsHandler.PauseTimer(r.Context(), owner, now)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TimerResponse{Timer: timer.withProgress(now)})
+	}
+}
+
+// ResumeTimerHandler is a route to resume the paused timer of the caller
+func ResumeTimerHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, err := endDueTimer(r, env)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		now := time.Now()
+		timer, err := env.EventsHandler.ResumeTimer(r.Context(), owner, now)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TimerResponse{Timer: timer.withProgress(now)})
+	}
+}
+
+// StopTimerHandler is a route to stop the timer of the caller, creating its end event
+func StopTimerHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, err := endDueTimer(r, env)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		now := time.Now()
+		timer, err := env.EventsHandler.StopTimer(r.Context(), owner, now)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TimerResponse{Timer: timer.withProgress(now)})
+	}
+}
+
+// GetCurrentTimerHandler is a route to return the timer of the caller, null if none is running
+func GetCurrentTimerHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, err := endDueTimer(r, env)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		timer, err := env.EventsHandler.GetTimer(r.Context(), owner)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, TimerResponse{Timer: timer.withProgress(time.Now())})
+	}
+}
+
+// CreateEventLinkHandler is a route to link an event to another, like an end event to the start it closes
+func CreateEventLinkHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+
+		var linkRequest = &EventLinkRequest{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, linkRequest)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = validateEventLink(linkRequest)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		link, err := env.EventsHandler.CreateEventLink(r.Context(), eventID, linkRequest.Type, linkRequest.To)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventLinkResponse{Link: link})
+	}
+}
+
+// GetEventLinksHandler is a route to return the links from and to an event
+func GetEventLinksHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+
+		links, err := env.EventsHandler.GetEventLinks(r.Context(), eventID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if links == nil {
+			handleHTTPError(w, r, newNotFoundError("Event with ID not found"))
+			return
+		}
+
+		handleHTTPSuccess(w, r, EventLinksResponse{Links: links})
+	}
+}
+
+// DeleteEventLinkHandler is a route to remove a link from or to an event
+func DeleteEventLinkHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		err := env.EventsHandler.DeleteEventLink(r.Context(), vars["eventID"], vars["linkID"])
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, nil)
+	}
+}
+
+// CreateFieldSchemaHandler is a route to give a custom field the type it has to have on events
+func CreateFieldSchemaHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var schema = &FieldSchema{}
+		post, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		err = json.Unmarshal(post, schema)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = validateFieldSchema(schema)
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError(err.Error()))
+			return
+		}
+
+		err = env.EventsHandler.CreateFieldSchema(r.Context(), schema)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, FieldSchemaResponse{Field: schema})
+	}
+}
+
+// GetFieldSchemasHandler is a route to return the schemas of the custom fields
+func GetFieldSchemasHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schemas, err := env.EventsHandler.GetFieldSchemas(r.Context())
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, FieldSchemasResponse{Fields: schemas})
+	}
+}
+
+// DeleteFieldSchemaHandler is a route to let a custom field have any type again
+func DeleteFieldSchemaHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		err := env.EventsHandler.DeleteFieldSchema(r.Context(), vars["fieldName"])
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, nil)
+	}
+}
+
+// UploadAttachmentsHandler is a route to attach files to an event, sent as multipart/form-data under file, any number
+// of them. Their content is kept only once, however many times it is uploaded.
+func UploadAttachmentsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventID := vars["eventID"]
+
+		// checked before reading any file, attaching them checks again
+		existing, err := env.EventsHandler.GetAttachments(r.Context(), eventID)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if existing == nil {
+			handleHTTPError(w, r, errAttachmentEventNotFound)
+			return
+		}
+
+		reader, err := r.MultipartReader()
+		if err != nil {
+			handleHTTPError(w, r, newBadRequestError("body should be multipart/form-data"))
+			return
+		}
+
+		attachments := make([]*Attachment, 0)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				handleHTTPError(w, r, uploadError(r, err))
+				return
+			}
+			if part.FormName() != attachmentFormName {
+				continue
+			}
+
+			attachment, err := storeAttachment(r.Context(), env.Blobs, eventID, part.FileName(), part.Header.Get("Content-Type"), part)
+			if err != nil {
+				handleHTTPError(w, r, uploadError(r, err))
+				return
+			}
+			attachments = append(attachments, attachment)
+		}
+		if len(attachments) == 0 {
+			handleHTTPError(w, r, newBadRequestError("no "+attachmentFormName+" in the form"))
+			return
+		}
+
+		// only once every file is stored, so a failed upload attaches none of them
+		err = env.EventsHandler.CreateAttachments(r.Context(), eventID, attachments)
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+
+		handleHTTPSuccess(w, r, AttachmentsResponse{Attachments: attachments})
+	}
+}
+
+// uploadError is the error to send for a failed read of an upload. A body over its limit keeps failing
+// with *http.MaxBytesError, which may have been wrapped on the way.
+func uploadError(r *http.Request, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if _, readErr := r.Body.Read(make([]byte, 1)); errors.As(readErr, &maxBytesErr) {
+		return readErr
+	}
+
+	return err
+}
+
+// GetAttachmentsHandler is a route to return the attachments of an event
+func GetAttachmentsHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		attachments, err := env.EventsHandler.GetAttachments(r.Context(), vars["eventID"])
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if attachments == nil {
+			handleHTTPError(w, r, errAttachmentEventNotFound)
+			return
+		}
+
+		handleHTTPSuccess(w, r, AttachmentsResponse{Attachments: attachments})
+	}
+}
+
+// DownloadAttachmentHandler is a route to return the content of an attachment, with the content type it was uploaded with
+func DownloadAttachmentHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		attachment, err := env.EventsHandler.GetAttachment(r.Context(), vars["eventID"], vars["attachmentID"])
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		if attachment == nil {
+			handleHTTPError(w, r, errAttachmentNotFound)
+			return
+		}
+
+		content, err := env.Blobs.Open(r.Context(), attachment.SHA256)
+		if errors.Is(err, errBlobNotFound) {
+			handleHTTPError(w, r, newNotFoundError("Attachment content not found"))
+			return
+		}
+		if err != nil {
+			handleHTTPError(w, r, err)
+			return
+		}
+		defer content.Close()
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+		if attachment.FileName == "" || disposition == "" {
+			disposition = "attachment"
+		}
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", disposition)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+
+		_, err = io.Copy(w, content)
+		if err != nil {
+			// too late to send an error, the headers are out
+			loggerFromContext(r.Context()).Warn("attachment download failed", "attachment_id", attachment.ID, errorAttr(err))
+		}
+	}
+}
+
+// DeleteAttachmentHandler is a route to remove an attachment from an event
+func DeleteAttachmentHandler(env *env) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		err := env.EventsHandler.DeleteAttachment(r.Context(), vars["eventID"], vars["attachmentID"])
 		if err != nil {
-			handleHTTPError(w, err)
+			handleHTTPError(w, r, err)
 			return
 		}
 
-		handleHTTPSuccess(w, EventIDResponse{EventID: eventID})
+		handleHTTPSuccess(w, r, nil)
 	}
 }
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-DeepError"
 	"github.com/jforcode/Go-Util"
 )
 
@@ -41,7 +47,7 @@ func TestCreateEvent(t *testing.T) {
 
 	router := mux.NewRouter()
 	env := &env{
-		&TestEventHandler{},
+		EventsHandler: &TestEventHandler{},
 	}
 
 	router.HandleFunc(routeCreateEvent, CreateEventHandler(env)).Methods(http.MethodPost)
@@ -63,12 +69,12 @@ func TestGetEvent(t *testing.T) {
 
 	router := mux.NewRouter()
 	env := &env{
-		&TestEventHandler{},
+		EventsHandler: &TestEventHandler{},
 	}
 
 	router.HandleFunc(routeGetEvent, GetEventHandler(env)).Methods(http.MethodGet)
 
-	eventID, err := env.EventsHandler.CreateEvent(GetTestEvent())
+	eventID, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
 	util.Test.HandleIfTestError(t, err, fn)
 
 	url := fmt.Sprintf(routeGetEventF, eventID)
@@ -97,14 +103,14 @@ func TestGetAllEvents(t *testing.T) {
 
 	router := mux.NewRouter()
 	env := &env{
-		&TestEventHandler{},
+		EventsHandler: &TestEventHandler{},
 	}
 
 	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
 
-	eventID1, err := env.EventsHandler.CreateEvent(GetTestEvent())
+	eventID1, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
 	util.Test.HandleIfTestError(t, err, fn)
-	eventID2, err := env.EventsHandler.CreateEvent(GetTestEvent())
+	eventID2, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
 	util.Test.HandleIfTestError(t, err, fn)
 
 	req, err := http.NewRequest(http.MethodGet, routeGetEvents, nil)
@@ -130,6 +136,62 @@ func TestGetAllEvents(t *testing.T) {
 	util.Test.AssertJSONEquals(t, expected, rr.Body.String(), "Invalid Event")
 }
 
+func TestGetFilteredEvents(t *testing.T) {
+	fn := "TestGetFilteredEvents"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
+
+	_, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
+	util.Test.HandleIfTestError(t, err, fn)
+	later := GetTestEvent()
+	later.Title = "Later Event"
+	later.UserCreatedAt = later.UserCreatedAt.Add(time.Hour)
+	later.Tags = []*EventTag{&EventTag{Value: "test1"}}
+	laterID, err := env.EventsHandler.CreateEvent(context.Background(), later)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	cases := map[string][]string{
+		"?type=start&tag=test1":      {"1", laterID},
+		"?tag=test1&tag=test2":       {"1"},
+		"?from=2018-11-25T12:00:00Z": {laterID},
+		"?to=2018-11-25T12:00:00Z":   {"1"},
+		"?q=Later":                   {laterID},
+		"?limit=1":                   {"1"},
+		"?type=end":                  {},
+	}
+	for query, expectedIDs := range cases {
+		req, err := http.NewRequest(http.MethodGet, routeGetEvents+query, nil)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for "+query)
+
+		var response struct {
+			Data EventsResponse `json:"data"`
+		}
+		util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &response), fn)
+
+		ids := make([]string, 0)
+		for _, evt := range response.Data.Events {
+			ids = append(ids, evt.ID)
+		}
+		util.Test.AssertEquals(t, expectedIDs, ids, fn+": Wrong events for "+query)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, routeGetEvents+"?limit=5000", nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Limit not enforced")
+}
+
 // thought of refactoring GetTestEvent and GetTestEventJSON and putting as test data
 // will do later if required, right now not that much data to make it feasible
 
@@ -158,6 +220,8 @@ func GetTestEventJSON(eventID string) string {
 				"title": "Test Event",
 				"note": "Some Test note",
 				"created_at": "2018-11-25T11:26:08Z",
+				"time_zone": "",
+				"utc_offset": 0,
 				"type": {
 					"value": "start"
 				},
@@ -171,3 +235,558 @@ func GetTestEventJSON(eventID string) string {
 				]
 			}`
 }
+
+// serveTest sends a request with the given body through the handler and records the response
+func serveTest(t *testing.T, fn string, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	fn := "TestRequestIDMiddleware"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetHealth, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, requestIDFromContext(r.Context()))
+	})
+	handler := RequestIDMiddleware(newLogger(io.Discard, "error"))(router)
+
+	req, err := http.NewRequest(http.MethodGet, routeGetHealth, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+	req.Header.Set(headerRequestID, "client-request-id")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, "client-request-id", rr.Header().Get(headerRequestID), fn+": Request ID not propagated")
+	util.Test.AssertEquals(t, "client-request-id", rr.Body.String(), fn+": Request ID not in context")
+
+	req, err = http.NewRequest(http.MethodGet, routeGetHealth, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, true, rr.Header().Get(headerRequestID) != "", fn+": Request ID not generated")
+	util.Test.AssertEquals(t, rr.Header().Get(headerRequestID), rr.Body.String(), fn+": Generated ID not in context")
+}
+
+func TestRequestTimeout(t *testing.T) {
+	fn := "TestRequestTimeout"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetEvents, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		handleHTTPError(w, r, r.Context().Err())
+	})
+	handler := TimeoutMiddleware(time.Millisecond)(router)
+
+	req, err := http.NewRequest(http.MethodGet, routeGetEvents, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusGatewayTimeout, rr.Code, fn+": Wrong Status Code")
+
+	expected := `
+		{
+			"success": false,
+			"data": null,
+			"error": {
+				"code": 1,
+				"message": "request timed out"
+			}
+		}`
+
+	util.Test.AssertJSONEquals(t, expected, rr.Body.String(), "Timeout response failed")
+}
+
+func TestWrappedRequestError(t *testing.T) {
+	fn := "TestWrappedRequestError"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetEvent, func(w http.ResponseWriter, r *http.Request) {
+		err := deepError.New("findEvent", "transaction", deepError.New("findEvent", "lock", errLinkEventNotFound))
+		handleHTTPError(w, r, err)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/events/1", nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Wrong Status Code")
+
+	expected := fmt.Sprintf(`
+		{
+			"success": false,
+			"data": null,
+			"error": {
+				"code": %d,
+				"message": "%s"
+			}
+		}`, errCodeNotFound, errLinkEventNotFound.Message)
+
+	util.Test.AssertJSONEquals(t, expected, rr.Body.String(), "Wrapped request error not sent as is")
+}
+
+type testChecker struct {
+	name string
+	err  error
+}
+
+func (checker *testChecker) Name() string {
+	return checker.name
+}
+
+func (checker *testChecker) Check(ctx context.Context) error {
+	return checker.err
+}
+
+func TestReadiness(t *testing.T) {
+	fn := "TestReadiness"
+
+	env := &env{
+		ReadinessCheckers: []DependencyChecker{
+			&testChecker{name: "mysql"},
+			&testChecker{name: "schema"},
+		},
+		HealthCheckTimeout: time.Second,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetReady, ReadinessHandler(env)).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodGet, routeGetReady, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"status":"ready"`), fmt.Sprintf("Not ready: %+v", rr.Body))
+
+	env.ReadinessCheckers[1] = &testChecker{name: "schema", err: errors.New("schema version is 2, expected 3")}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusServiceUnavailable, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"status":"not_ready"`), fmt.Sprintf("Ready: %+v", rr.Body))
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"error":"schema version is 2, expected 3"`), fmt.Sprintf("Missing dependency error: %+v", rr.Body))
+}
+
+func TestGetEventNotModified(t *testing.T) {
+	fn := "TestGetEventNotModified"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeGetEvent, GetEventHandler(env)).Methods(http.MethodGet)
+
+	event := GetTestEvent()
+	event.UpdatedAt = time.Date(2018, 11, 25, 11, 30, 0, 0, time.UTC)
+	eventID, err := env.EventsHandler.CreateEvent(context.Background(), event)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	url := fmt.Sprintf(routeGetEventF, eventID)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	etag := rr.Header().Get("ETag")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, etag != "", fn+": Missing ETag")
+	util.Test.AssertEquals(t, "Sun, 25 Nov 2018 11:30:00 GMT", rr.Header().Get("Last-Modified"), fn+": Wrong Last-Modified")
+
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusNotModified, rr.Code, fn+": Expected not modified for same etag")
+	util.Test.AssertEquals(t, "", rr.Body.String(), fn+": Body sent with 304")
+
+	req.Header.Del("If-None-Match")
+	req.Header.Set("If-Modified-Since", "Sun, 25 Nov 2018 11:30:00 GMT")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusNotModified, rr.Code, fn+": Expected not modified since last modified")
+
+	event.UpdatedAt = event.UpdatedAt.Add(time.Minute)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Expected full response after update")
+
+	etag = rr.Header().Get("ETag")
+	otherID, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
+	util.Test.HandleIfTestError(t, err, fn)
+	_, err = env.EventsHandler.CreateEventLink(context.Background(), eventID, linkTypeRelates, otherID)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Expected full response after linking")
+}
+
+func TestGetEventsNotModified(t *testing.T) {
+	fn := "TestGetEventsNotModified"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
+
+	kept := GetTestEvent()
+	kept.UpdatedAt = time.Date(2018, 11, 25, 11, 30, 0, 0, time.UTC)
+	_, err := env.EventsHandler.CreateEvent(context.Background(), kept)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	dropped := GetTestEvent()
+	dropped.UpdatedAt = kept.UpdatedAt
+	droppedID, err := env.EventsHandler.CreateEvent(context.Background(), dropped)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	get := func(target, header, value string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		util.Test.HandleIfTestError(t, err, fn)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(routeGetEvents, "", "")
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	util.Test.AssertEquals(t, "Sun, 25 Nov 2018 11:30:00 GMT", lastModified, fn+": Wrong Last-Modified")
+
+	rr = get(routeGetEvents+"?tz=Asia/Kolkata", "If-None-Match", etag)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Etag matched for another time zone")
+
+	_, err = env.EventsHandler.PushChanges(context.Background(), []*EventChange{
+		{Event: &Event{ID: droppedID}, Deleted: true, ChangedAt: kept.UpdatedAt.Add(time.Hour)},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = get(routeGetEvents, "If-Modified-Since", lastModified)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Expected full response after a delete")
+	util.Test.AssertEquals(t, "Sun, 25 Nov 2018 12:30:00 GMT", rr.Header().Get("Last-Modified"), fn+": Last-Modified not moved by the delete")
+}
+
+func TestSync(t *testing.T) {
+	fn := "TestSync"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeSync, SyncHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeSync, PushSyncHandler(env)).Methods(http.MethodPost)
+
+	event := GetTestEvent()
+	event.CreatedAt = time.Date(2018, 11, 25, 11, 30, 0, 0, time.UTC)
+	event.UpdatedAt = event.CreatedAt
+	eventID, err := env.EventsHandler.CreateEvent(context.Background(), event)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	pull := func(since string) *SyncChanges {
+		req, err := http.NewRequest(http.MethodGet, routeSync+"?since="+since, nil)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+		resp := &struct {
+			Data *SyncChanges `json:"data"`
+		}{}
+		err = json.Unmarshal(rr.Body.Bytes(), resp)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		return resp.Data
+	}
+
+	push := func(changes string) *PushResult {
+		req, err := http.NewRequest(http.MethodPost, routeSync, strings.NewReader(changes))
+		util.Test.HandleIfTestError(t, err, fn)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+		resp := &struct {
+			Data *PushResult `json:"data"`
+		}{}
+		err = json.Unmarshal(rr.Body.Bytes(), resp)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		return resp.Data
+	}
+
+	changes := pull("")
+	util.Test.AssertEquals(t, 1, len(changes.Created), fn+": Event not in created")
+	util.Test.AssertEquals(t, eventID, changes.Created[0].ID, fn+": Wrong created event")
+
+	result := push(`{"changes": [{"event": {"id": "` + eventID + `"}, "deleted": true, "changed_at": "2018-11-25T11:00:00Z"}]}`)
+	util.Test.AssertEquals(t, 0, len(result.Applied), fn+": Older change applied")
+	util.Test.AssertEquals(t, 1, len(result.Conflicts), fn+": Conflict not reported")
+	util.Test.AssertEquals(t, conflictReasonServerNewer, result.Conflicts[0].Reason, fn+": Wrong conflict reason")
+
+	result = push(`{"changes": [{"event": {"id": "` + eventID + `"}, "deleted": true, "changed_at": "2018-11-25T12:00:00Z"}]}`)
+	util.Test.AssertEquals(t, []string{eventID}, result.Applied, fn+": Newer change not applied")
+
+	changes = pull(changes.Next)
+	util.Test.AssertEquals(t, 0, len(changes.Created)+len(changes.Updated), fn+": Unexpected changes")
+	util.Test.AssertEquals(t, 1, len(changes.Deleted), fn+": Tombstone missing")
+	util.Test.AssertEquals(t, eventID, changes.Deleted[0].ID, fn+": Wrong tombstone")
+
+	req, err := http.NewRequest(http.MethodGet, routeSync+"?since=garbage", nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Invalid token accepted")
+}
+
+func TestGetEventHistory(t *testing.T) {
+	fn := "TestGetEventHistory"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeGetHistory, GetEventHistoryHandler(env)).Methods(http.MethodGet)
+
+	event := GetTestEvent()
+	eventID, err := env.EventsHandler.CreateEvent(context.Background(), event)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	updated := GetTestEvent()
+	updated.ID = eventID
+	updated.Title = "Updated Test Event"
+	_, err = env.EventsHandler.PushChanges(context.Background(), []*EventChange{
+		{Event: updated, ChangedAt: time.Now()},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(routeGetHistoryF, eventID), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+	resp := &struct {
+		Data *EventHistoryResponse `json:"data"`
+	}{}
+	err = json.Unmarshal(rr.Body.Bytes(), resp)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	history := resp.Data.History
+	util.Test.AssertEquals(t, 2, len(history), fn+": Wrong number of entries")
+	util.Test.AssertEquals(t, auditActionCreate, history[0].Action, fn+": First entry should be create")
+	util.Test.AssertEquals(t, auditActionUpdate, history[1].Action, fn+": Second entry should be update")
+	util.Test.AssertEquals(t, &AuditChange{Before: "Test Event", After: "Updated Test Event"}, history[1].Changes["title"], fn+": Wrong title change")
+	util.Test.AssertEquals(t, 1, len(history[1].Changes), fn+": Unchanged fields in diff")
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf(routeGetHistoryF, "unknown"), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Wrong Status Code for unknown event")
+}
+
+func TestRestoreEventRevision(t *testing.T) {
+	fn := "TestRestoreEventRevision"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeGetRevisions, GetEventRevisionsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetRevision, GetEventRevisionHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeRestoreRevision, RestoreEventRevisionHandler(env)).Methods(http.MethodPost)
+
+	event := GetTestEvent()
+	eventID, err := env.EventsHandler.CreateEvent(context.Background(), event)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	updated := GetTestEvent()
+	updated.ID = eventID
+	updated.Title = "Updated Test Event"
+	_, err = env.EventsHandler.PushChanges(context.Background(), []*EventChange{
+		{Event: updated, ChangedAt: time.Now()},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(routeGetRevisionF, eventID, 1), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+	revisionResp := &struct {
+		Data *EventRevisionResponse `json:"data"`
+	}{}
+	err = json.Unmarshal(rr.Body.Bytes(), revisionResp)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, "Test Event", revisionResp.Data.Revision.Event.Title, fn+": Wrong title in first revision")
+
+	req, err = http.NewRequest(http.MethodPost, fmt.Sprintf(routeRestoreRevisionF, eventID, 1), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for restore")
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf(routeGetRevisionsF, eventID), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	revisionsResp := &struct {
+		Data *EventRevisionsResponse `json:"data"`
+	}{}
+	err = json.Unmarshal(rr.Body.Bytes(), revisionsResp)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	revisions := revisionsResp.Data.Revisions
+	util.Test.AssertEquals(t, 3, len(revisions), fn+": Restore should add a revision")
+	util.Test.AssertEquals(t, "Test Event", revisions[2].Event.Title, fn+": Wrong title after restore")
+
+	req, err = http.NewRequest(http.MethodPost, fmt.Sprintf(routeRestoreRevisionF, eventID, 10), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Wrong Status Code for unknown revision")
+}
+
+func TestRetention(t *testing.T) {
+	fn := "TestRetention"
+
+	router := mux.NewRouter()
+	handler := &TestEventHandler{}
+	env := &env{
+		EventsHandler: handler,
+		Retention:     RetentionPolicy{DeletedEventsAfterDays: 30},
+	}
+
+	router.HandleFunc(routeAdminRetention, RetentionHandler(env)).Methods(http.MethodPost)
+
+	_, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
+	util.Test.HandleIfTestError(t, err, fn)
+
+	deletedID, err := env.EventsHandler.CreateEvent(context.Background(), GetTestEvent())
+	util.Test.HandleIfTestError(t, err, fn)
+
+	deleted := GetTestEvent()
+	deleted.ID = deletedID
+	_, err = env.EventsHandler.PushChanges(context.Background(), []*EventChange{
+		{Event: deleted, Deleted: true, ChangedAt: time.Now().AddDate(0, 0, -40)},
+	})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	adminCtx := context.WithValue(context.Background(), ctxKeyActor, actorAdmin)
+
+	req, err := http.NewRequest(http.MethodPost, routeAdminRetention, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusForbidden, rr.Code, fn+": Wrong Status Code without the admin user")
+	util.Test.AssertEquals(t, 2, len(handler.events), fn+": Nothing should be purged without the admin user")
+
+	for _, dryRun := range []bool{true, false} {
+		req, err := http.NewRequestWithContext(adminCtx, http.MethodPost, routeAdminRetention+"?dry_run="+strconv.FormatBool(dryRun), nil)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+		resp := &struct {
+			Data *RetentionReport `json:"data"`
+		}{}
+		err = json.Unmarshal(rr.Body.Bytes(), resp)
+		util.Test.HandleIfTestError(t, err, fn)
+
+		util.Test.AssertEquals(t, &RetentionReport{DryRun: dryRun, PurgedEvents: 1}, resp.Data, fn+": Wrong report")
+	}
+
+	util.Test.AssertEquals(t, 1, len(handler.events), fn+": Deleted event should be purged")
+
+	req, err = http.NewRequestWithContext(adminCtx, http.MethodPost, routeAdminRetention+"?dry_run=maybe", nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Wrong Status Code for bad dry_run")
+}
+
+// queryTimeoutEventHandler fails GetEvent the way a db call hitting query_timeout does, wrapped in a DeepError
+type queryTimeoutEventHandler struct {
+	TestEventHandler
+}
+
+func (handler *queryTimeoutEventHandler) GetEvent(ctx context.Context, eventID string) (*Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+
+	<-ctx.Done()
+	return nil, deepError.New("GetEvent", "query", ctx.Err())
+}
+
+func TestQueryTimeout(t *testing.T) {
+	fn := "TestQueryTimeout"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &queryTimeoutEventHandler{},
+	}
+
+	router.HandleFunc(routeGetEvent, GetEventHandler(env)).Methods(http.MethodGet)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(routeGetEventF, "1"), nil)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	util.Test.AssertEquals(t, http.StatusGatewayTimeout, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"request timed out"`), fn+": Wrong error")
+}
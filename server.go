@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+// ServerConfig holds the settings for the http server and its shutdown
+type ServerConfig struct {
+	URL             string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	MaxHeaderBytes  int
+	ShutdownTimeout time.Duration
+}
+
+// BackgroundWorkers keeps track of long running goroutines, so they can be stopped and waited upon on shutdown
+type BackgroundWorkers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBackgroundWorkers creates an empty set of workers
+func NewBackgroundWorkers() *BackgroundWorkers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BackgroundWorkers{ctx: ctx, cancel: cancel}
+}
+
+// Go starts work in a goroutine. The context passed to work is cancelled when the workers are stopped.
+func (workers *BackgroundWorkers) Go(name string, work func(ctx context.Context)) {
+	workers.wg.Add(1)
+	go func() {
+		defer workers.wg.Done()
+
+		slog.Info("background worker started", "worker", name)
+		work(workers.ctx)
+		slog.Info("background worker stopped", "worker", name)
+	}()
+}
+
+// Stop cancels all workers and waits for them to return, or for ctx to be done
+func (workers *BackgroundWorkers) Stop(ctx context.Context) error {
+	workers.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		workers.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newHTTPServer(config ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:           config.URL,
+		Handler:        handler,
+		ReadTimeout:    config.ReadTimeout,
+		WriteTimeout:   config.WriteTimeout,
+		IdleTimeout:    config.IdleTimeout,
+		MaxHeaderBytes: config.MaxHeaderBytes,
+	}
+}
+
+// runServer serves until SIGINT/SIGTERM, then stops accepting connections, lets in-flight requests
+// finish within the shutdown timeout, stops the background workers and closes the db.
+func runServer(config ServerConfig, srv *http.Server, workers *BackgroundWorkers, db *sql.DB, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return serveUntilDone(ctx, config, srv, workers, db, logger)
+}
+
+// serveUntilDone serves until ctx is done or the server fails, and shuts everything down the same way either way.
+// An error serving wins over one shutting down.
+func serveUntilDone(ctx context.Context, config ServerConfig, srv *http.Server, workers *BackgroundWorkers, db *sql.DB, logger *slog.Logger) error {
+	fn := "serveUntilDone"
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "url", config.URL)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server failed, shutting down", errorAttr(err))
+			runErr = deepError.New(fn, "listen and serve", err)
+		}
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining requests", "timeout", config.ShutdownTimeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("http server shutdown incomplete", errorAttr(err))
+		runErr = firstError(runErr, deepError.New(fn, "shutdown http server", err))
+	}
+
+	err = workers.Stop(shutdownCtx)
+	if err != nil {
+		logger.Error("background workers did not stop in time", errorAttr(err))
+		runErr = firstError(runErr, deepError.New(fn, "stop workers", err))
+	}
+
+	err = db.Close()
+	if err != nil {
+		logger.Error("closing db failed", errorAttr(err))
+		runErr = firstError(runErr, deepError.New(fn, "close db", err))
+	}
+
+	logger.Info("server stopped")
+	return runErr
+}
+
+func firstError(err, next error) error {
+	if err != nil {
+		return err
+	}
+
+	return next
+}
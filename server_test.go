@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jforcode/Go-Util"
+)
+
+func TestServeUntilDone(t *testing.T) {
+	fn := "TestServeUntilDone"
+	logger := newLogger(io.Discard, "error")
+
+	bound, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Test.HandleIfTestError(t, err, fn)
+	defer bound.Close()
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Test.HandleIfTestError(t, err, fn)
+	freeURL := free.Addr().String()
+	free.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		url       string
+		expectErr bool
+	}{
+		{"port taken", context.Background(), bound.Addr().String(), true},
+		{"signal", cancelled, freeURL, false},
+	}
+
+	for _, test := range tests {
+		// never connected to, only closed
+		db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/events")
+		util.Test.HandleIfTestError(t, err, fn)
+
+		stopped := make(chan struct{})
+		workers := NewBackgroundWorkers()
+		workers.Go("fake", func(ctx context.Context) {
+			<-ctx.Done()
+			close(stopped)
+		})
+
+		config := ServerConfig{URL: test.url, ShutdownTimeout: time.Second}
+		err = serveUntilDone(test.ctx, config, newHTTPServer(config, http.NotFoundHandler()), workers, db, logger)
+		util.Test.AssertEquals(t, test.expectErr, err != nil, fn+": Wrong error for "+test.name)
+
+		workerStopped := false
+		select {
+		case <-stopped:
+			workerStopped = true
+		default:
+		}
+		util.Test.AssertEquals(t, true, workerStopped, fn+": Worker not stopped for "+test.name)
+
+		err = db.Ping()
+		util.Test.AssertEquals(t, true, err != nil && strings.Contains(err.Error(), "closed"), fn+": Db not closed for "+test.name)
+	}
+}
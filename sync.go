@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	syncTokenVersion = "v2"
+	// tokens from before the change sequence, ordered by _updated_at, can't be mapped onto it
+	syncTokenVersionUpdatedAt = "v1"
+
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+
+	conflictReasonServerNewer = "server_newer"
+)
+
+var (
+	// change_seq is handed out in commit order, see sequenceChangedEvents, so no change still in flight can land
+	// behind a token given out
+	queryGetChangesSince = fmt.Sprintf(`
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE (E.%s > ? OR (E.%s = ? AND E.%s > ?))
+		ORDER BY E.%s, E.%s
+		LIMIT ?`,
+		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields, eventsColChangeSeq,
+		eventsTableName,
+		eventsColChangeSeq, eventsColChangeSeq, colDbID,
+		eventsColChangeSeq, colDbID)
+
+	// locks an event, deleted or not, for a sync push to decide who wrote last
+	queryLockEventForSync = fmt.Sprintf(`
+		SELECT E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s, E.%s
+		FROM %s E
+		WHERE E.%s = ?
+		FOR UPDATE`,
+		colDbID, eventsColID, eventsColTitle, eventsColNote, eventsColCreatedAt, eventsColTypeID, colCreatedAt, colUpdatedAt, colStatus,
+		eventsColTimeZone, eventsColUTCOffset, eventsColFields, eventsColChangedAt,
+		eventsTableName,
+		eventsColID)
+
+	errInvalidSyncToken = errors.New("invalid sync token")
+)
+
+// SyncToken marks a position in the stream of changes to events, by change sequence and then db id.
+// UpdatedAt, of the last change given out, tells created events from updated ones.
+// Clients only ever see it as an opaque string.
+type SyncToken struct {
+	Seq       int64
+	UpdatedAt time.Time
+	DbID      int64
+}
+
+// String encodes the token to send to clients
+func (token SyncToken) String() string {
+	raw := fmt.Sprintf("%s:%d:%d:%d", syncTokenVersion, token.Seq, token.UpdatedAt.UnixNano(), token.DbID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseSyncToken decodes a token given by a client. An empty token, or one from before the change sequence,
+// is the start of the stream.
+func parseSyncToken(encoded string) (SyncToken, error) {
+	if encoded == "" {
+		return SyncToken{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return SyncToken{}, errInvalidSyncToken
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) == 3 && parts[0] == syncTokenVersionUpdatedAt {
+		return SyncToken{}, nil
+	}
+	if len(parts) != 4 || parts[0] != syncTokenVersion {
+		return SyncToken{}, errInvalidSyncToken
+	}
+
+	seq, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return SyncToken{}, errInvalidSyncToken
+	}
+
+	nanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return SyncToken{}, errInvalidSyncToken
+	}
+
+	dbID, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return SyncToken{}, errInvalidSyncToken
+	}
+
+	return SyncToken{Seq: seq, UpdatedAt: time.Unix(0, nanos).UTC(), DbID: dbID}, nil
+}
+
+// SyncEvent is an event along with when it was last changed on the server
+type SyncEvent struct {
+	*Event
+	UpdatedAt time.Time `json:"updated_at"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// Tombstone is what is left of an event deleted since the last sync
+type Tombstone struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncChanges represents the response to send to client, in case of a sync pull
+type SyncChanges struct {
+	Created []*SyncEvent `json:"created"`
+	Updated []*SyncEvent `json:"updated"`
+	Deleted []*Tombstone `json:"deleted"`
+	Next    string       `json:"next"`
+	HasMore bool         `json:"has_more"`
+}
+
+// EventChange is a change made by a client while offline. A change without an event ID creates a new event.
+type EventChange struct {
+	Event     *Event    `json:"event"`
+	Deleted   bool      `json:"deleted"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// PushRequest is the body of a sync push
+type PushRequest struct {
+	Changes []*EventChange `json:"changes"`
+}
+
+// SyncConflict reports a client change that lost to a newer change on the server
+type SyncConflict struct {
+	EventID string     `json:"event_id"`
+	Reason  string     `json:"reason"`
+	Server  *SyncEvent `json:"server"`
+}
+
+// PushResult represents the response to send to client, in case of a sync push
+type PushResult struct {
+	Applied   []string        `json:"applied"`
+	Conflicts []*SyncConflict `json:"conflicts"`
+}
+
+func newSyncChanges(since SyncToken) *SyncChanges {
+	return &SyncChanges{
+		Created: make([]*SyncEvent, 0),
+		Updated: make([]*SyncEvent, 0),
+		Deleted: make([]*Tombstone, 0),
+		Next:    since.String(),
+	}
+}
+
+// addChange sorts an event changed since the token into created, updated or deleted, and moves the token past it,
+// to seq in the change sequence
+func (changes *SyncChanges) addChange(since SyncToken, event *Event, seq int64) {
+	switch {
+	case event.Status == statusDeleted:
+		changes.Deleted = append(changes.Deleted, &Tombstone{ID: event.ID, DeletedAt: event.UpdatedAt})
+	case event.CreatedAt.After(since.UpdatedAt):
+		changes.Created = append(changes.Created, &SyncEvent{Event: event, UpdatedAt: event.UpdatedAt})
+	default:
+		changes.Updated = append(changes.Updated, &SyncEvent{Event: event, UpdatedAt: event.UpdatedAt})
+	}
+
+	changes.Next = SyncToken{Seq: seq, UpdatedAt: event.UpdatedAt, DbID: event.DbID}.String()
+}
+
+// GetChanges returns up to limit events created, updated or deleted after the token, oldest change first
+func (handler *EventsHandler) GetChanges(ctx context.Context, since SyncToken, limit int) (*SyncChanges, error) {
+	fn := "GetChanges"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	// one extra row tells if there is more to fetch
+	rows, err := handler.dbStuff.query(queryCtx, queryGetChangesSince, since.Seq, since.Seq, since.DbID, limit+1)
+	if err != nil {
+		logger.Error("query changes failed", errorAttr(err))
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	events := make([]*Event, 0)
+	seqs := make([]int64, 0)
+	for rows.Next() {
+		var seq int64
+		event := &Event{Type: &EventType{}, Tags: make([]*EventTag, 0)}
+		err = rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+			&event.TimeZone, &event.UTCOffset, &event.Fields, &seq)
+		if err != nil {
+			logger.Error("reading changes failed", errorAttr(err))
+			return nil, deepError.New(fn, "scan", err)
+		}
+		events = append(events, event)
+		seqs = append(seqs, seq)
+	}
+
+	changes := newSyncChanges(since)
+	if len(events) > limit {
+		events = events[:limit]
+		changes.HasMore = true
+	}
+
+	err = loadEventsDetails(ctx, handler.dbStuff, events)
+	if err != nil {
+		logger.Error("loading change details failed", errorAttr(err))
+		return nil, deepError.New(fn, "load events details", err)
+	}
+
+	for i, event := range events {
+		changes.addChange(since, event, seqs[i])
+	}
+
+	logger.Debug("fetched changes", "count", len(events), "has_more", changes.HasMore)
+	return changes, nil
+}
+
+// PushChanges applies changes made by a client, each in its own transaction. When the server has a newer
+// change to the same event, the server's version wins and is reported back as a conflict.
+func (handler *EventsHandler) PushChanges(ctx context.Context, changes []*EventChange) (*PushResult, error) {
+	fn := "PushChanges"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	result := &PushResult{
+		Applied:   make([]string, 0),
+		Conflicts: make([]*SyncConflict, 0),
+	}
+
+	for _, change := range changes {
+		var conflict *SyncConflict
+		err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+			var err error
+			conflict, err = applyChange(ctx, txStuff, change)
+			return err
+		})
+		if err != nil {
+			logger.Error("apply change failed", "event_id", change.Event.ID, errorAttr(err))
+			return nil, deepError.New(fn, "apply change", err)
+		}
+
+		if conflict != nil {
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+		result.Applied = append(result.Applied, change.Event.ID)
+	}
+
+	logger.Info("pushed changes", "applied", len(result.Applied), "conflicts", len(result.Conflicts))
+	return result, nil
+}
+
+// applyChange writes a single client change with last writer wins, going by when each change was made rather than
+// when it reached the server
+func applyChange(ctx context.Context, txStuff *dbStuff, change *EventChange) (*SyncConflict, error) {
+	fn := "applyChange"
+
+	event := change.Event
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+
+	existing, changedAt, err := txStuff.lockEventForSync(ctx, event.ID)
+	if err != nil {
+		return nil, deepError.New(fn, "lock event", err)
+	}
+
+	if existing == nil {
+		if change.Deleted {
+			// deleted before it ever reached the server, nothing to do
+			return nil, nil
+		}
+
+		err = insertEventWithTags(ctx, txStuff, event)
+		if err != nil {
+			return nil, deepError.New(fn, "insert event", err)
+		}
+		err = txStuff.setEventChangedAt(ctx, event.DbID, change.ChangedAt)
+		if err != nil {
+			return nil, deepError.New(fn, "set changed at", err)
+		}
+		return nil, nil
+	}
+
+	if existing.Status != statusDeleted {
+		err = loadEventDetails(ctx, txStuff, existing)
+		if err != nil {
+			return nil, deepError.New(fn, "load event details", err)
+		}
+	}
+
+	if !change.ChangedAt.After(changedAt) {
+		return &SyncConflict{
+			EventID: event.ID,
+			Reason:  conflictReasonServerNewer,
+			Server:  &SyncEvent{Event: existing, UpdatedAt: existing.UpdatedAt, Deleted: existing.Status == statusDeleted},
+		}, nil
+	}
+
+	if change.Deleted {
+		if existing.Status == statusDeleted {
+			return nil, nil
+		}
+
+		err = deleteEvent(ctx, txStuff, existing)
+		if err != nil {
+			return nil, deepError.New(fn, "delete event", err)
+		}
+		err = txStuff.setEventChangedAt(ctx, existing.DbID, change.ChangedAt)
+		if err != nil {
+			return nil, deepError.New(fn, "set changed at", err)
+		}
+		return nil, nil
+	}
+
+	event.DbRecord = existing.DbRecord
+	err = updateEventWithTags(ctx, txStuff, auditActionUpdate, existing, event)
+	if err != nil {
+		return nil, deepError.New(fn, "update event", err)
+	}
+
+	err = txStuff.setEventChangedAt(ctx, event.DbID, change.ChangedAt)
+	if err != nil {
+		return nil, deepError.New(fn, "set changed at", err)
+	}
+
+	return nil, nil
+}
+
+// lockEventForSync finds an event by its id, deleted or not, and locks it till the transaction ends.
+// Along with it comes when it was last changed, by the client that changed it. nil if there is no such event.
+func (dbStuff *dbStuff) lockEventForSync(ctx context.Context, eventID string) (*Event, time.Time, error) {
+	fn := "lockEventForSync"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryLockEventForSync, eventID)
+	if err != nil {
+		return nil, time.Time{}, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, time.Time{}, nil
+	}
+
+	var changedAt time.Time
+	event := &Event{Type: &EventType{}}
+	err = rows.Scan(&event.DbID, &event.ID, &event.Title, &event.Note, &event.UserCreatedAt, &event.Type.DbID, &event.CreatedAt, &event.UpdatedAt, &event.Status,
+		&event.TimeZone, &event.UTCOffset, &event.Fields, &changedAt)
+	if err != nil {
+		return nil, time.Time{}, deepError.New(fn, "scan", err)
+	}
+
+	return event, changedAt, nil
+}
+
+// setEventChangedAt records when a client made the change just written to an event
+func (dbStuff *dbStuff) setEventChangedAt(ctx context.Context, eventDbID int64, changedAt time.Time) error {
+	fn := "setEventChangedAt"
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ? WHERE %s = ?",
+		eventsTableName, eventsColChangedAt, colDbID)
+
+	_, err := dbStuff.exec(ctx, query, changedAt.UTC(), eventDbID)
+	if err != nil {
+		return deepError.New(fn, "prepare and exec", err)
+	}
+
+	return nil
+}
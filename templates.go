@@ -0,0 +1,641 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+	"github.com/teambition/rrule-go"
+)
+
+const (
+	auditEntityTemplate     = "template"
+	auditActionAddException = "add_exception"
+
+	actorTemplates = "templates"
+
+	defaultTemplatesInterval = 15 * time.Minute
+	defaultTemplatesLookback = 24 * time.Hour
+
+	// with only daily, weekly and monthly rules, this keeps a run to a few hundred occurrences per template
+	maxMaterializeRange    = 366 * 24 * time.Hour
+	maxTemplateRRuleLength = 1000
+)
+
+var (
+	queryGetTemplates = fmt.Sprintf(`
+		SELECT T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s
+		FROM %s T
+		WHERE T.%s = '%s'
+		ORDER BY T.%s`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		eventTemplatesColID, eventTemplatesColTitle, eventTemplatesColNote, eventTemplatesColType,
+		eventTemplatesColTags, eventTemplatesColRRule, eventTemplatesColStartsAt, eventTemplatesColExceptions, eventTemplatesColTimeZone,
+		eventTemplatesTableName,
+		colStatus, statusActive,
+		colDbID)
+
+	queryGetTemplate = fmt.Sprintf(`
+		SELECT T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s
+		FROM %s T
+		WHERE T.%s = ? AND T.%s = '%s'`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		eventTemplatesColID, eventTemplatesColTitle, eventTemplatesColNote, eventTemplatesColType,
+		eventTemplatesColTags, eventTemplatesColRRule, eventTemplatesColStartsAt, eventTemplatesColExceptions, eventTemplatesColTimeZone,
+		eventTemplatesTableName,
+		eventTemplatesColID, colStatus, statusActive)
+
+	queryGetTemplateOccurrence = fmt.Sprintf(`
+		SELECT O.%s
+		FROM %s O
+		WHERE O.%s = ? AND O.%s = ?`,
+		templateOccurrencesColEventID,
+		templateOccurrencesTableName,
+		templateOccurrencesColTemplateID, templateOccurrencesColOccursAt)
+
+	errTemplateNotFound        = newNotFoundError("Template with ID not found")
+	errInvalidMaterializeRange = newBadRequestError(fmt.Sprintf("to should be after from, and at most %d days later", int(maxMaterializeRange.Hours()/24)))
+)
+
+// EventTemplate is the Db model for an event happening again and again, by an RFC 5545 RRULE.
+// Its occurrences are materialized into events of the same title, note, type and tags.
+type EventTemplate struct {
+	DbRecord
+	ID    string      `json:"id"`
+	Title string      `json:"title"`
+	Note  string      `json:"note"`
+	Type  *EventType  `json:"type"`
+	Tags  []*EventTag `json:"tags"`
+	// RRule is the recurrence, like FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10, without a DTSTART
+	RRule string `json:"rrule"`
+	// StartsAt is the first occurrence, and gives the time of day of all of them
+	StartsAt time.Time `json:"starts_at"`
+	// Exceptions are occurrences which don't happen
+	Exceptions []time.Time `json:"exceptions"`
+	// TimeZone is where the rule is followed, keeping the time of day across DST and BYDAY to the local day, UTC if empty
+	TimeZone string `json:"time_zone"`
+}
+
+// TemplateIDResponse represents the response to send to client, in case of a create template
+type TemplateIDResponse struct {
+	TemplateID string `json:"templateID"`
+}
+
+// TemplateResponse represents the response to send to client, in case of a get template
+type TemplateResponse struct {
+	Template *EventTemplate `json:"template"`
+}
+
+// TemplatesResponse represents the response to send to client, in case of a get templates
+type TemplatesResponse struct {
+	Templates []*EventTemplate `json:"templates"`
+}
+
+// TemplateExceptionRequest is the occurrence of a template to skip
+type TemplateExceptionRequest struct {
+	At time.Time `json:"at"`
+}
+
+// MaterializeReport represents the response to send to client, in case of a materialize run
+type MaterializeReport struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Templates int       `json:"templates"`
+	// Created counts the events created, Existing the occurrences which already had one
+	Created  int `json:"created"`
+	Existing int `json:"existing"`
+}
+
+// parseRecurrence builds the recurrence of a template from its rule, first occurrence and exceptions, followed in
+// location. Only daily, weekly and monthly rules are allowed, with the time of day taken from start.
+func parseRecurrence(rule string, start time.Time, location *time.Location, exceptions []time.Time) (*rrule.Set, error) {
+	if len(rule) > maxTemplateRRuleLength || strings.ContainsAny(rule, "\r\n") {
+		return nil, errors.New("rrule should be a single line of at most 1000 characters")
+	}
+
+	option, err := rrule.StrToROption(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rrule: %w", err)
+	}
+
+	switch {
+	case option.Freq != rrule.DAILY && option.Freq != rrule.WEEKLY && option.Freq != rrule.MONTHLY:
+		return nil, errors.New("rrule FREQ should be DAILY, WEEKLY or MONTHLY")
+	case !option.Dtstart.IsZero():
+		return nil, errors.New("rrule should not have a DTSTART, starts_at is used")
+	case len(option.Byhour) > 0 || len(option.Byminute) > 0 || len(option.Bysecond) > 0:
+		return nil, errors.New("rrule should not have BYHOUR, BYMINUTE or BYSECOND, the time of starts_at is used")
+	case len(option.Byeaster) > 0:
+		return nil, errors.New("rrule BYEASTER is not supported")
+	case option.Interval < 0 || option.Count < 0:
+		return nil, errors.New("rrule INTERVAL and COUNT should not be negative")
+	}
+
+	option.Dtstart = start.In(location)
+	recurrence, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rrule: %w", err)
+	}
+
+	set := &rrule.Set{}
+	set.RRule(recurrence)
+	for _, exception := range exceptions {
+		set.ExDate(exception.UTC())
+	}
+
+	return set, nil
+}
+
+// occurrences returns the times the template happens at, from inclusive and to exclusive
+func (template *EventTemplate) occurrences(from, to time.Time) ([]time.Time, error) {
+	set, err := parseRecurrence(template.RRule, template.StartsAt, template.location(), template.Exceptions)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]time.Time, 0)
+	for _, occurrence := range set.Between(from.UTC(), to.UTC(), true) {
+		if occurrence.Before(to) {
+			occurrences = append(occurrences, occurrence.UTC())
+		}
+	}
+
+	return occurrences, nil
+}
+
+// location is where the rule of the template is followed
+func (template *EventTemplate) location() *time.Location {
+	if template.TimeZone != "" {
+		location, err := loadLocation(template.TimeZone)
+		if err == nil {
+			return location
+		}
+	}
+
+	return time.UTC
+}
+
+// eventAt is the event the template materializes into for an occurrence, in the time zone of the template
+func (template *EventTemplate) eventAt(occurrence time.Time) *Event {
+	event := &Event{
+		Title:         template.Title,
+		Note:          template.Note,
+		UserCreatedAt: occurrence,
+		TimeZone:      template.TimeZone,
+		Type:          &EventType{Value: template.Type.Value},
+		Tags:          make([]*EventTag, 0),
+	}
+
+	for _, tag := range template.Tags {
+		event.Tags = append(event.Tags, &EventTag{Value: tag.Value})
+	}
+
+	return event
+}
+
+// hasException tells if the occurrence at is already skipped
+func (template *EventTemplate) hasException(at time.Time) bool {
+	for _, exception := range template.Exceptions {
+		if exception.Equal(at) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateTemplate(template *EventTemplate) error {
+	if template.Type == nil || template.Type.Value == "" {
+		return errors.New("template type is required")
+	}
+	if template.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+	if template.RRule == "" {
+		return errors.New("rrule is required")
+	}
+	for _, tag := range template.Tags {
+		if tag == nil || tag.Value == "" {
+			return errors.New("template tags should not be empty")
+		}
+	}
+	if template.TimeZone != "" {
+		_, err := loadLocation(template.TimeZone)
+		if err != nil {
+			return err
+		}
+	}
+
+	// occurrences are only exact to the second
+	template.StartsAt = template.StartsAt.UTC().Truncate(time.Second)
+	if template.Tags == nil {
+		template.Tags = make([]*EventTag, 0)
+	}
+	if template.Exceptions == nil {
+		template.Exceptions = make([]time.Time, 0)
+	}
+	for i, exception := range template.Exceptions {
+		template.Exceptions[i] = exception.UTC().Truncate(time.Second)
+	}
+
+	_, err := parseRecurrence(template.RRule, template.StartsAt, template.location(), template.Exceptions)
+	return err
+}
+
+// validateMaterializeRange checks a range asked to be materialized
+func validateMaterializeRange(from, to time.Time) error {
+	if !to.After(from) || to.Sub(from) > maxMaterializeRange {
+		return errInvalidMaterializeRange
+	}
+
+	return nil
+}
+
+// runTemplates materializes the occurrences of the last lookback every interval, till ctx is done.
+// The lookback covers the runs missed while the server was down.
+func runTemplates(ctx context.Context, handler IEventsHandler, interval, lookback time.Duration, logger *slog.Logger) {
+	ctx = context.WithValue(ctx, ctxKeyActor, actorTemplates)
+	ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("worker", "templates"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().UTC()
+		report, err := handler.MaterializeTemplates(ctx, now.Add(-lookback), now)
+		if err != nil {
+			logger.Error("templates run failed", errorAttr(err))
+			continue
+		}
+
+		logger.Info("templates run done", "templates", report.Templates, "created", report.Created, "existing", report.Existing)
+	}
+}
+
+// CreateTemplate adds a template, after validating its recurrence
+func (handler *EventsHandler) CreateTemplate(ctx context.Context, template *EventTemplate) (string, error) {
+	fn := "CreateTemplate"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	template.ID = uuid.New().String()
+	tagsJSON, exceptionsJSON, err := marshalTemplateLists(template)
+	if err != nil {
+		return "", deepError.New(fn, "marshal", err)
+	}
+
+	err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			eventTemplatesTableName, eventTemplatesColID, eventTemplatesColTitle, eventTemplatesColNote, eventTemplatesColType,
+			eventTemplatesColTags, eventTemplatesColRRule, eventTemplatesColStartsAt, eventTemplatesColExceptions, eventTemplatesColTimeZone)
+
+		res, err := txStuff.exec(ctx, query, template.ID, template.Title, template.Note, template.Type.Value,
+			tagsJSON, template.RRule, template.StartsAt, exceptionsJSON, template.TimeZone)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+		template.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityTemplate, template.ID, auditActionCreate, nil, template)
+	})
+	if err != nil {
+		logger.Error("create template failed", errorAttr(err))
+		return "", deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("template created", "template_id", template.ID)
+	return template.ID, nil
+}
+
+// GetTemplates gets all the templates which are not deleted
+func (handler *EventsHandler) GetTemplates(ctx context.Context) ([]*EventTemplate, error) {
+	fn := "GetTemplates"
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, queryGetTemplates)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	templates := make([]*EventTemplate, 0)
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+// GetTemplate gets a template, nil if there is no such template
+func (handler *EventsHandler) GetTemplate(ctx context.Context, templateID string) (*EventTemplate, error) {
+	fn := "GetTemplate"
+
+	template, err := handler.dbStuff.findTemplate(ctx, templateID, false)
+	if err != nil {
+		return nil, deepError.New(fn, "find template", err)
+	}
+
+	return template, nil
+}
+
+// DeleteTemplate soft deletes a template, so that it is materialized no more. The events it already
+// materialized into are left alone.
+func (handler *EventsHandler) DeleteTemplate(ctx context.Context, templateID string) error {
+	fn := "DeleteTemplate"
+
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		template, err := txStuff.findTemplate(ctx, templateID, true)
+		if err != nil {
+			return deepError.New(fn, "find template", err)
+		}
+		if template == nil {
+			return errTemplateNotFound
+		}
+
+		_, err = txStuff.exec(ctx, fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", eventTemplatesTableName, colStatus, colDbID),
+			statusDeleted, template.DbID)
+		if err != nil {
+			return deepError.New(fn, "update", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityTemplate, template.ID, auditActionDelete, template, nil)
+	})
+	if err != nil {
+		return deepError.New(fn, "transaction", err)
+	}
+
+	return nil
+}
+
+// AddTemplateException skips an occurrence of a template. If it was already materialized, its event is deleted.
+func (handler *EventsHandler) AddTemplateException(ctx context.Context, templateID string, at time.Time) (*EventTemplate, error) {
+	fn := "AddTemplateException"
+	logger := loggerFromContext(ctx).With("fn", fn, "template_id", templateID)
+
+	at = at.UTC().Truncate(time.Second)
+	var template *EventTemplate
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		var err error
+		template, err = txStuff.findTemplate(ctx, templateID, true)
+		if err != nil {
+			return deepError.New(fn, "find template", err)
+		}
+		if template == nil {
+			return errTemplateNotFound
+		}
+		if template.hasException(at) {
+			return nil
+		}
+
+		before := *template
+		template.Exceptions = append(template.Exceptions, at)
+		_, exceptionsJSON, err := marshalTemplateLists(template)
+		if err != nil {
+			return deepError.New(fn, "marshal", err)
+		}
+
+		_, err = txStuff.exec(ctx, fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", eventTemplatesTableName, eventTemplatesColExceptions, colDbID),
+			exceptionsJSON, template.DbID)
+		if err != nil {
+			return deepError.New(fn, "update", err)
+		}
+
+		err = recordAudit(ctx, txStuff, auditEntityTemplate, template.ID, auditActionAddException, &before, template)
+		if err != nil {
+			return deepError.New(fn, "record audit", err)
+		}
+
+		eventID, err := txStuff.findTemplateOccurrence(ctx, template.DbID, at)
+		if err != nil {
+			return deepError.New(fn, "find occurrence", err)
+		}
+		if eventID == "" {
+			return nil
+		}
+
+		event, err := txStuff.findEventByID(ctx, eventID)
+		if err != nil {
+			return deepError.New(fn, "find event", err)
+		}
+		if event == nil || event.Status != statusActive {
+			return nil
+		}
+
+		err = loadEventDetails(ctx, txStuff, event)
+		if err != nil {
+			return deepError.New(fn, "load event details", err)
+		}
+
+		return deleteEvent(ctx, txStuff, event)
+	})
+	if err != nil {
+		logFailure(logger, "add exception failed", err)
+		return nil, deepError.New(fn, "transaction", err)
+	}
+
+	return template, nil
+}
+
+// MaterializeTemplates creates the events for the occurrences of every template from inclusive and to exclusive,
+// each in its own transaction. Occurrences already materialized, even if their event was deleted since, are skipped,
+// so ranges can be materialized again and again.
+func (handler *EventsHandler) MaterializeTemplates(ctx context.Context, from, to time.Time) (*MaterializeReport, error) {
+	fn := "MaterializeTemplates"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	err := validateMaterializeRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := handler.GetTemplates(ctx)
+	if err != nil {
+		return nil, deepError.New(fn, "get templates", err)
+	}
+
+	report := &MaterializeReport{From: from.UTC(), To: to.UTC(), Templates: len(templates)}
+	for _, template := range templates {
+		occurrences, err := template.occurrences(from, to)
+		if err != nil {
+			// stored templates were validated, so only a rule the library no longer takes gets here
+			logger.Error("invalid template", "template_id", template.ID, errorAttr(err))
+			continue
+		}
+
+		for _, occurrence := range occurrences {
+			created, err := handler.materializeOccurrence(ctx, template, occurrence)
+			if err != nil {
+				logger.Error("materialize failed", "template_id", template.ID, "occurrence", occurrence, errorAttr(err))
+				return nil, deepError.New(fn, "materialize occurrence", err)
+			}
+
+			if created {
+				report.Created++
+			} else {
+				report.Existing++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// materializeOccurrence creates the event of an occurrence, unless there already is one.
+// The unique occurrence row is inserted first, so that concurrent runs can't both create the event.
+func (handler *EventsHandler) materializeOccurrence(ctx context.Context, template *EventTemplate, occurrence time.Time) (bool, error) {
+	fn := "materializeOccurrence"
+
+	event := template.eventAt(occurrence)
+	event.ID = uuid.New().String()
+
+	created := false
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		res, err := txStuff.exec(ctx,
+			fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s, %s) VALUES (?, ?, ?)",
+				templateOccurrencesTableName, templateOccurrencesColTemplateID, templateOccurrencesColOccursAt, templateOccurrencesColEventID),
+			template.DbID, occurrence, event.ID)
+		if err != nil {
+			return deepError.New(fn, "insert occurrence", err)
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return deepError.New(fn, "rows affected", err)
+		}
+		if inserted == 0 {
+			return nil
+		}
+
+		created = true
+		return insertEventWithTags(ctx, txStuff, event)
+	})
+	if err != nil {
+		return false, deepError.New(fn, "transaction", err)
+	}
+
+	return created, nil
+}
+
+func marshalTemplateLists(template *EventTemplate) (string, string, error) {
+	tags := make([]string, 0)
+	for _, tag := range template.Tags {
+		tags = append(tags, tag.Value)
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return "", "", err
+	}
+
+	exceptionsJSON, err := json.Marshal(template.Exceptions)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(tagsJSON), string(exceptionsJSON), nil
+}
+
+func scanTemplate(rows *sql.Rows) (*EventTemplate, error) {
+	template := &EventTemplate{Type: &EventType{}, Tags: make([]*EventTag, 0)}
+	var tagsJSON, exceptionsJSON []byte
+	var note sql.NullString
+
+	err := rows.Scan(&template.DbID, &template.CreatedAt, &template.UpdatedAt, &template.Status,
+		&template.ID, &template.Title, &note, &template.Type.Value,
+		&tagsJSON, &template.RRule, &template.StartsAt, &exceptionsJSON, &template.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	template.Note = note.String
+
+	tags := make([]string, 0)
+	err = json.Unmarshal(tagsJSON, &tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		template.Tags = append(template.Tags, &EventTag{Value: tag})
+	}
+
+	err = json.Unmarshal(exceptionsJSON, &template.Exceptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// findTemplate finds an active template, nil if there is none. With forUpdate, its row stays locked till the transaction ends.
+func (dbStuff *dbStuff) findTemplate(ctx context.Context, templateID string, forUpdate bool) (*EventTemplate, error) {
+	fn := "findTemplate"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	query := queryGetTemplate
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := dbStuff.query(ctx, query, templateID)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	template, err := scanTemplate(rows)
+	if err != nil {
+		return nil, deepError.New(fn, "scan", err)
+	}
+
+	return template, nil
+}
+
+// findTemplateOccurrence finds the id of the event an occurrence was materialized into, empty if it wasn't
+func (dbStuff *dbStuff) findTemplateOccurrence(ctx context.Context, templateDbID int64, occursAt time.Time) (string, error) {
+	fn := "findTemplateOccurrence"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetTemplateOccurrence, templateDbID, occursAt)
+	if err != nil {
+		return "", deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	eventID := ""
+	if rows.Next() {
+		err = rows.Scan(&eventID)
+		if err != nil {
+			return "", deepError.New(fn, "scan", err)
+		}
+	}
+
+	return eventID, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestTemplateOccurrences(t *testing.T) {
+	fn := "TestTemplateOccurrences"
+
+	start := time.Date(2020, 1, 6, 9, 30, 0, 0, time.UTC) // a monday
+	day := func(d int) time.Time { return start.AddDate(0, 0, d) }
+
+	tests := []struct {
+		name       string
+		rule       string
+		exceptions []time.Time
+		expected   []time.Time
+	}{
+		{"daily", "FREQ=DAILY", nil, []time.Time{day(0), day(1), day(2), day(3), day(4), day(5), day(6), day(7)}},
+		{"byday", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE", nil, []time.Time{day(0), day(2), day(7)}},
+		{"count", "FREQ=DAILY;INTERVAL=2;COUNT=3", nil, []time.Time{day(0), day(2), day(4)}},
+		{"until", "FREQ=DAILY;UNTIL=20200108T093000Z", nil, []time.Time{day(0), day(1), day(2)}},
+		{"exceptions", "FREQ=WEEKLY;BYDAY=MO,WE", []time.Time{day(2)}, []time.Time{day(0), day(7)}},
+		{"monthly", "FREQ=MONTHLY;BYMONTHDAY=6", nil, []time.Time{day(0)}},
+	}
+
+	for _, test := range tests {
+		template := &EventTemplate{RRule: test.rule, StartsAt: start, Exceptions: test.exceptions}
+		occurrences, err := template.occurrences(start, day(8))
+		util.Test.HandleIfTestError(t, err, fn+": "+test.name)
+		util.Test.AssertEquals(t, test.expected, occurrences, fn+": Wrong occurrences for "+test.name)
+	}
+
+	// 9:00 in New York keeps its time of day across the start of DST on March 8, and BYDAY is the monday there
+	// even when it is a tuesday in UTC
+	newYork, err := loadLocation("America/New_York")
+	util.Test.HandleIfTestError(t, err, fn)
+	local := func(d, hour int) time.Time { return time.Date(2020, 3, d, hour, 0, 0, 0, newYork).UTC() }
+	template := &EventTemplate{RRule: "FREQ=WEEKLY;BYDAY=MO", StartsAt: local(2, 9), TimeZone: "America/New_York"}
+	occurrences, err := template.occurrences(local(2, 0), local(17, 0))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []time.Time{local(2, 9), local(9, 9), local(16, 9)}, occurrences, fn+": Wrong occurrences across DST")
+	template = &EventTemplate{RRule: "FREQ=WEEKLY;BYDAY=MO", StartsAt: local(2, 21), TimeZone: "America/New_York"}
+	occurrences, err = template.occurrences(local(2, 0), local(10, 0))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []time.Time{local(2, 21), local(9, 21)}, occurrences, fn+": Wrong occurrences on the local day")
+
+	for _, rule := range []string{"FREQ=YEARLY", "FREQ=HOURLY", "FREQ=DAILY;BYHOUR=10", "DTSTART:20200101T000000Z\nFREQ=DAILY", "BYDAY=MO", "FREQ=DAILY;COUNT=-1"} {
+		_, err := parseRecurrence(rule, start, time.UTC, nil)
+		util.Test.AssertEquals(t, true, err != nil, fn+": Rule accepted: "+rule)
+	}
+}
+
+func TestMaterializeTemplates(t *testing.T) {
+	fn := "TestMaterializeTemplates"
+
+	handler := &TestEventHandler{}
+	ctx := context.Background()
+	start := time.Date(2020, 1, 6, 9, 30, 0, 0, time.UTC)
+
+	template := &EventTemplate{
+		Title:    "standup",
+		Type:     &EventType{Value: "meeting"},
+		Tags:     []*EventTag{{Value: "work"}},
+		RRule:    "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		StartsAt: start,
+	}
+	util.Test.HandleIfTestError(t, validateTemplate(template), fn)
+	templateID, err := handler.CreateTemplate(ctx, template)
+	util.Test.HandleIfTestError(t, err, fn)
+
+	report, err := handler.MaterializeTemplates(ctx, start, start.AddDate(0, 0, 7))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 3, report.Created, fn+": Wrong events created")
+
+	events, _ := handler.GetAllEvents(ctx)
+	util.Test.AssertEquals(t, start.AddDate(0, 0, 2), events[1].UserCreatedAt, fn+": Wrong time")
+	util.Test.AssertEquals(t, "meeting", events[1].Type.Value, fn+": Type not copied")
+	util.Test.AssertEquals(t, "work", events[1].Tags[0].Value, fn+": Tags not copied")
+
+	report, err = handler.MaterializeTemplates(ctx, start, start.AddDate(0, 0, 14))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 3, report.Created, fn+": Wrong events created again")
+	util.Test.AssertEquals(t, 3, report.Existing, fn+": Materialized twice")
+
+	_, err = handler.AddTemplateException(ctx, templateID, start.AddDate(0, 0, 2))
+	util.Test.HandleIfTestError(t, err, fn)
+	events, _ = handler.GetAllEvents(ctx)
+	util.Test.AssertEquals(t, 5, len(events), fn+": Event of the exception not deleted")
+
+	report, err = handler.MaterializeTemplates(ctx, start, start.AddDate(0, 0, 14))
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 0, report.Created, fn+": Exception materialized")
+
+	_, err = handler.MaterializeTemplates(ctx, start, start.AddDate(2, 0, 0))
+	util.Test.AssertEquals(t, errInvalidMaterializeRange, err, fn+": Too long range accepted")
+
+	// events of a template with a time zone are in it
+	zoned := &EventTemplate{Type: &EventType{Value: "meeting"}, RRule: "FREQ=DAILY", StartsAt: start, TimeZone: "Asia/Kolkata"}
+	eventID, err := handler.CreateEvent(ctx, zoned.eventAt(start))
+	util.Test.HandleIfTestError(t, err, fn)
+	event, err := handler.GetEvent(ctx, eventID)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, "Asia/Kolkata", event.TimeZone, fn+": Time zone not copied")
+	util.Test.AssertEquals(t, 19800, event.UTCOffset, fn+": Wrong utc offset")
+}
+
+func TestTemplateRoutes(t *testing.T) {
+	fn := "TestTemplateRoutes"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeCreateTemplate, CreateTemplateHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeMaterializeTemplates, MaterializeTemplatesHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetTemplate, GetTemplateHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetTemplate, DeleteTemplateHandler(env)).Methods(http.MethodDelete)
+	router.HandleFunc(routeTemplateExceptions, AddTemplateExceptionHandler(env)).Methods(http.MethodPost)
+
+	rr := serveTest(t, fn, router, http.MethodPost, routeCreateTemplate, `{"title": "gym", "type": {"value": "start"}, "rrule": "FREQ=YEARLY", "starts_at": "2020-01-06T18:00:00Z"}`)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Yearly rule accepted")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeCreateTemplate, `{"title": "gym", "type": {"value": "start"}, "rrule": "FREQ=DAILY", "starts_at": "2020-01-06T18:00:00Z", "time_zone": "Mars/Olympus"}`)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Unknown time zone accepted")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeCreateTemplate, `{"title": "gym", "type": {"value": "start"}, "rrule": "FREQ=DAILY", "starts_at": "2020-01-06T18:00:00Z"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	created := struct {
+		Data TemplateIDResponse `json:"data"`
+	}{}
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &created), fn)
+	templateID := created.Data.TemplateID
+
+	rr = serveTest(t, fn, router, http.MethodPost, fmt.Sprintf(routeTemplateExceptionsF, templateID), `{"at": "2020-01-07T18:00:00Z"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Exception not added")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeMaterializeTemplates+"?from=2020-01-06T00:00:00Z&to=2020-01-09T00:00:00Z", "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"created":2`), fmt.Sprintf("Wrong report: %s", rr.Body))
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeMaterializeTemplates+"?from=2020-01-06T00:00:00Z", "")
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Missing to accepted")
+
+	rr = serveTest(t, fn, router, http.MethodDelete, fmt.Sprintf(routeGetTemplateF, templateID), "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Not deleted")
+
+	rr = serveTest(t, fn, router, http.MethodGet, fmt.Sprintf(routeGetTemplateF, templateID), "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Deleted template found")
+}
@@ -1,26 +1,61 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // TestEventHandler is a dummy event handler which uses an array for its operations
 type TestEventHandler struct {
 	events      []*Event
 	lastEventID int
+	history     map[string][]*AuditEntry
+	revisions   map[string][]*EventRevision
+	archived    []*Event
+	templates   []*EventTemplate
+	// occurrences maps a template id and occurrence to the event it was materialized into
+	occurrences map[string]string
+	links       []*EventLink
+	schemas     []*FieldSchema
+	attachments []*Attachment
+	goals       []*Goal
+	// timers are the running ones, removed once stopped
+	timers      []*Timer
+	lastTimerID int
 }
 
-// GetAllEvents gets all the events in the array
-func (handler *TestEventHandler) GetAllEvents() ([]*Event, error) {
-	return handler.events, nil
+// GetAllEvents gets all the events in the array, leaving out the deleted ones
+func (handler *TestEventHandler) GetAllEvents(ctx context.Context) ([]*Event, error) {
+	events := make([]*Event, 0)
+	for _, evt := range handler.events {
+		if evt.Status != statusDeleted {
+			events = append(events, evt)
+		}
+	}
+
+	return events, nil
+}
+
+// GetLastChangeTime is the latest UpdatedAt of the events in the array, deleted and archived ones included
+func (handler *TestEventHandler) GetLastChangeTime(ctx context.Context) (time.Time, error) {
+	var lastChange time.Time
+	for _, evt := range append(append([]*Event{}, handler.events...), handler.archived...) {
+		if evt.UpdatedAt.After(lastChange) {
+			lastChange = evt.UpdatedAt
+		}
+	}
+
+	return lastChange, nil
 }
 
 // GetEvent gets a specific event based on id from the array
-func (handler *TestEventHandler) GetEvent(eventID string) (*Event, error) {
+func (handler *TestEventHandler) GetEvent(ctx context.Context, eventID string) (*Event, error) {
 	for _, evt := range handler.events {
-		if strings.EqualFold(eventID, evt.ID) {
+		if strings.EqualFold(eventID, evt.ID) && evt.Status != statusDeleted {
 			return evt, nil
 		}
 	}
@@ -29,9 +64,742 @@ func (handler *TestEventHandler) GetEvent(eventID string) (*Event, error) {
 }
 
 // CreateEvent adds a new event to the array
-func (handler *TestEventHandler) CreateEvent(evt *Event) (string, error) {
+func (handler *TestEventHandler) CreateEvent(ctx context.Context, evt *Event) (string, error) {
 	handler.lastEventID++
 	evt.ID = strconv.Itoa(handler.lastEventID)
+	evt.DbID = int64(handler.lastEventID)
+	evt.setUTCOffset()
+	evt.UserCreatedAt = evt.UserCreatedAt.UTC()
 	handler.events = append(handler.events, evt)
+	handler.recordHistory(ctx, evt.ID, auditActionCreate, nil, evt)
 	return evt.ID, nil
 }
+
+// GetEventHistory gets the changes recorded for an event, nil if it never existed
+func (handler *TestEventHandler) GetEventHistory(ctx context.Context, eventID string) ([]*AuditEntry, error) {
+	history, ok := handler.history[eventID]
+	if !ok {
+		return nil, nil
+	}
+
+	return history, nil
+}
+
+// recordHistory keeps both the audit entry and the revision for a write
+func (handler *TestEventHandler) recordHistory(ctx context.Context, eventID, action string, before, after *Event) {
+	if handler.history == nil {
+		handler.history = make(map[string][]*AuditEntry)
+		handler.revisions = make(map[string][]*EventRevision)
+	}
+
+	diff, _ := auditDiff(newEventSnapshot(before), newEventSnapshot(after))
+	handler.history[eventID] = append(handler.history[eventID], &AuditEntry{
+		EntityType: auditEntityEvent,
+		EntityID:   eventID,
+		Action:     action,
+		Actor:      actorFromContext(ctx),
+		RequestID:  requestIDFromContext(ctx),
+		Changes:    diff,
+	})
+
+	handler.revisions[eventID] = append(handler.revisions[eventID], &EventRevision{
+		Revision: len(handler.revisions[eventID]) + 1,
+		Event:    newEventSnapshot(after),
+		Actor:    actorFromContext(ctx),
+	})
+}
+
+// GetEventRevisions gets the revisions kept for an event, nil if it never existed
+func (handler *TestEventHandler) GetEventRevisions(ctx context.Context, eventID string) ([]*EventRevision, error) {
+	revisions, ok := handler.revisions[eventID]
+	if !ok {
+		return nil, nil
+	}
+
+	return revisions, nil
+}
+
+// GetEventRevision gets a single revision of an event, nil if there is no such revision
+func (handler *TestEventHandler) GetEventRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error) {
+	revisions := handler.revisions[eventID]
+	if revision < 1 || revision > len(revisions) {
+		return nil, nil
+	}
+
+	return revisions[revision-1], nil
+}
+
+// RestoreEventRevision overwrites the event in the array with the revision, keeping it as a new revision
+func (handler *TestEventHandler) RestoreEventRevision(ctx context.Context, eventID string, revision int) (*Event, error) {
+	eventRevision, _ := handler.GetEventRevision(ctx, eventID, revision)
+	if eventRevision == nil {
+		return nil, nil
+	}
+	if eventRevision.Event.Status == statusDeleted {
+		return nil, errRevisionIsDeletion
+	}
+
+	for _, evt := range handler.events {
+		if evt.ID != eventID {
+			continue
+		}
+
+		before := *evt
+		restored := eventFromSnapshot(eventID, eventRevision.Event)
+		restored.DbRecord = evt.DbRecord
+		restored.Status = statusActive
+		*evt = *restored
+		handler.recordHistory(ctx, eventID, auditActionRestore, &before, evt)
+		return evt, nil
+	}
+
+	return nil, nil
+}
+
+// GetChanges gets the events updated after the token, in the order of their UpdatedAt
+func (handler *TestEventHandler) GetChanges(ctx context.Context, since SyncToken, limit int) (*SyncChanges, error) {
+	events := make([]*Event, 0)
+	for _, evt := range handler.events {
+		if evt.UpdatedAt.After(since.UpdatedAt) || (evt.UpdatedAt.Equal(since.UpdatedAt) && evt.DbID > since.DbID) {
+			events = append(events, evt)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].UpdatedAt.Before(events[j].UpdatedAt)
+	})
+
+	changes := newSyncChanges(since)
+	if len(events) > limit {
+		events = events[:limit]
+		changes.HasMore = true
+	}
+	for _, evt := range events {
+		changes.addChange(since, evt, 0)
+	}
+
+	return changes, nil
+}
+
+// PushChanges applies the changes to the array, with the latest change winning
+func (handler *TestEventHandler) PushChanges(ctx context.Context, changes []*EventChange) (*PushResult, error) {
+	result := &PushResult{
+		Applied:   make([]string, 0),
+		Conflicts: make([]*SyncConflict, 0),
+	}
+
+	for _, change := range changes {
+		var existing *Event
+		for _, evt := range handler.events {
+			if evt.ID == change.Event.ID {
+				existing = evt
+			}
+		}
+
+		switch {
+		case existing == nil && change.Deleted:
+			// deleted before it ever reached the server, nothing to do
+
+		case existing == nil:
+			handler.lastEventID++
+			if change.Event.ID == "" {
+				change.Event.ID = strconv.Itoa(handler.lastEventID)
+			}
+			change.Event.DbID = int64(handler.lastEventID)
+			change.Event.UpdatedAt = change.ChangedAt
+			handler.events = append(handler.events, change.Event)
+			handler.recordHistory(ctx, change.Event.ID, auditActionCreate, nil, change.Event)
+
+		case !change.ChangedAt.After(existing.UpdatedAt):
+			result.Conflicts = append(result.Conflicts, &SyncConflict{
+				EventID: existing.ID,
+				Reason:  conflictReasonServerNewer,
+				Server:  &SyncEvent{Event: existing, UpdatedAt: existing.UpdatedAt, Deleted: existing.Status == statusDeleted},
+			})
+			continue
+
+		case change.Deleted:
+			before := *existing
+			existing.Status = statusDeleted
+			existing.UpdatedAt = change.ChangedAt
+			handler.recordHistory(ctx, existing.ID, auditActionDelete, &before, existing)
+
+		default:
+			before := *existing
+			change.Event.DbRecord = existing.DbRecord
+			change.Event.UpdatedAt = change.ChangedAt
+			*existing = *change.Event
+			handler.recordHistory(ctx, existing.ID, auditActionUpdate, &before, existing)
+		}
+
+		result.Applied = append(result.Applied, change.Event.ID)
+	}
+
+	return result, nil
+}
+
+// ApplyRetention removes the old deleted events from the array, and moves the old ones to the archive.
+// Tags aren't kept apart from events here, so there are never orphan tags.
+func (handler *TestEventHandler) ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{DryRun: dryRun}
+	now := time.Now()
+
+	kept := make([]*Event, 0)
+	for _, evt := range handler.events {
+		switch {
+		case policy.DeletedEventsAfterDays > 0 && evt.Status == statusDeleted && evt.UpdatedAt.Before(now.AddDate(0, 0, -policy.DeletedEventsAfterDays)):
+			report.PurgedEvents++
+		case policy.ArchiveAfterYears > 0 && evt.Status != statusDeleted && evt.UserCreatedAt.Before(now.AddDate(-policy.ArchiveAfterYears, 0, 0)):
+			report.ArchivedEvents++
+			if !dryRun {
+				handler.archived = append(handler.archived, evt)
+			}
+		default:
+			kept = append(kept, evt)
+		}
+	}
+
+	if !dryRun {
+		handler.events = kept
+		handler.attachments = handler.attachmentsOf(kept)
+	}
+
+	return report, nil
+}
+
+// attachmentsOf keeps the attachments of the events
+func (handler *TestEventHandler) attachmentsOf(events []*Event) []*Attachment {
+	ids := make(map[string]bool)
+	for _, evt := range events {
+		ids[evt.ID] = true
+	}
+
+	attachments := make([]*Attachment, 0)
+	for _, attachment := range handler.attachments {
+		if ids[attachment.EventID] {
+			attachments = append(attachments, attachment)
+		}
+	}
+
+	return attachments
+}
+
+// FindEvents finds the events in the array matching filter, ordered by their user time
+func (handler *TestEventHandler) FindEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	events := make([]*Event, 0)
+	for _, evt := range handler.events {
+		if evt.Status != statusDeleted && matchesFilter(evt, filter) {
+			events = append(events, evt)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].UserCreatedAt.Before(events[j].UserCreatedAt)
+	})
+
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+
+	return events, nil
+}
+
+func matchesFilter(evt *Event, filter EventFilter) bool {
+	if filter.Type != "" && !isEventType(evt, filter.Type) {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		tagged := false
+		for _, evtTag := range evt.Tags {
+			tagged = tagged || evtTag.Value == tag
+		}
+		if !tagged {
+			return false
+		}
+	}
+	if !filter.From.IsZero() && evt.UserCreatedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !evt.UserCreatedAt.Before(filter.To) {
+		return false
+	}
+	if filter.Search != "" && !strings.Contains(evt.Title, filter.Search) && !strings.Contains(evt.Note, filter.Search) {
+		return false
+	}
+	for _, field := range filter.Fields {
+		value, ok := evt.Fields[field.Name]
+		if !ok || fieldValueText(value) != field.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetEventTypes gets the types of the events in the array
+func (handler *TestEventHandler) GetEventTypes(ctx context.Context) ([]*EventType, error) {
+	seen := make(map[string]bool)
+	eventTypes := make([]*EventType, 0)
+	for _, evt := range handler.events {
+		if evt.Type != nil && !seen[evt.Type.Value] {
+			seen[evt.Type.Value] = true
+			eventTypes = append(eventTypes, evt.Type)
+		}
+	}
+
+	return eventTypes, nil
+}
+
+// GetEventTags gets the tags of the events in the array
+func (handler *TestEventHandler) GetEventTags(ctx context.Context) ([]*EventTag, error) {
+	seen := make(map[string]bool)
+	eventTags := make([]*EventTag, 0)
+	for _, evt := range handler.events {
+		for _, tag := range evt.Tags {
+			if !seen[tag.Value] {
+				seen[tag.Value] = true
+				eventTags = append(eventTags, tag)
+			}
+		}
+	}
+
+	return eventTags, nil
+}
+
+// CreateTemplate adds a new template to the array
+func (handler *TestEventHandler) CreateTemplate(ctx context.Context, template *EventTemplate) (string, error) {
+	template.ID = "template-" + strconv.Itoa(len(handler.templates)+1)
+	template.DbID = int64(len(handler.templates) + 1)
+	template.Status = statusActive
+	handler.templates = append(handler.templates, template)
+	return template.ID, nil
+}
+
+// GetTemplates gets the templates in the array, leaving out the deleted ones
+func (handler *TestEventHandler) GetTemplates(ctx context.Context) ([]*EventTemplate, error) {
+	templates := make([]*EventTemplate, 0)
+	for _, template := range handler.templates {
+		if template.Status != statusDeleted {
+			templates = append(templates, template)
+		}
+	}
+
+	return templates, nil
+}
+
+// GetTemplate gets a template from the array, nil if there is no such template
+func (handler *TestEventHandler) GetTemplate(ctx context.Context, templateID string) (*EventTemplate, error) {
+	for _, template := range handler.templates {
+		if template.ID == templateID && template.Status != statusDeleted {
+			return template, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteTemplate marks a template in the array deleted
+func (handler *TestEventHandler) DeleteTemplate(ctx context.Context, templateID string) error {
+	template, _ := handler.GetTemplate(ctx, templateID)
+	if template == nil {
+		return errTemplateNotFound
+	}
+
+	template.Status = statusDeleted
+	return nil
+}
+
+// AddTemplateException skips an occurrence of a template, deleting its event if it was materialized
+func (handler *TestEventHandler) AddTemplateException(ctx context.Context, templateID string, at time.Time) (*EventTemplate, error) {
+	template, _ := handler.GetTemplate(ctx, templateID)
+	if template == nil {
+		return nil, errTemplateNotFound
+	}
+
+	at = at.UTC().Truncate(time.Second)
+	if !template.hasException(at) {
+		template.Exceptions = append(template.Exceptions, at)
+	}
+
+	eventID := handler.occurrences[occurrenceKey(template, at)]
+	for _, evt := range handler.events {
+		if evt.ID == eventID && evt.Status != statusDeleted {
+			before := *evt
+			evt.Status = statusDeleted
+			handler.recordHistory(ctx, evt.ID, auditActionDelete, &before, evt)
+		}
+	}
+
+	return template, nil
+}
+
+// MaterializeTemplates creates the events for the occurrences of the templates in the array, skipping
+// the ones already materialized
+func (handler *TestEventHandler) MaterializeTemplates(ctx context.Context, from, to time.Time) (*MaterializeReport, error) {
+	err := validateMaterializeRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if handler.occurrences == nil {
+		handler.occurrences = make(map[string]string)
+	}
+
+	templates, _ := handler.GetTemplates(ctx)
+	report := &MaterializeReport{From: from.UTC(), To: to.UTC(), Templates: len(templates)}
+	for _, template := range templates {
+		occurrences, err := template.occurrences(from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, occurrence := range occurrences {
+			key := occurrenceKey(template, occurrence)
+			if _, ok := handler.occurrences[key]; ok {
+				report.Existing++
+				continue
+			}
+
+			eventID, _ := handler.CreateEvent(ctx, template.eventAt(occurrence))
+			handler.occurrences[key] = eventID
+			report.Created++
+		}
+	}
+
+	return report, nil
+}
+
+func occurrenceKey(template *EventTemplate, occurrence time.Time) string {
+	return template.ID + "@" + occurrence.UTC().Format(time.RFC3339)
+}
+
+// CreateEventLink links two events in the array, keeping the link on both of them
+func (handler *TestEventHandler) CreateEventLink(ctx context.Context, fromEventID, linkType, toEventID string) (*EventLink, error) {
+	from, _ := handler.GetEvent(ctx, fromEventID)
+	to, _ := handler.GetEvent(ctx, toEventID)
+	if from == nil || to == nil {
+		return nil, errLinkEventNotFound
+	}
+
+	err := checkLinkEvents(linkType, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range handler.links {
+		if link.Type == linkType && link.FromEventID == fromEventID && link.ToEventID == toEventID {
+			return nil, errLinkExists
+		}
+		if linkType == linkTypeCloses && link.Type == linkTypeCloses &&
+			(link.FromEventID == fromEventID || link.ToEventID == toEventID) {
+			return nil, errAlreadyClosed
+		}
+	}
+
+	link := &EventLink{ID: "link-" + strconv.Itoa(len(handler.links)+1), Type: linkType, FromEventID: fromEventID, ToEventID: toEventID}
+	handler.links = append(handler.links, link)
+	from.Links = append(from.Links, link)
+	to.Links = append(to.Links, link)
+	from.UpdatedAt = time.Now()
+	to.UpdatedAt = from.UpdatedAt
+	return link, nil
+}
+
+// GetEventLinks gets the links from and to an event in the array, nil if there is no such event
+func (handler *TestEventHandler) GetEventLinks(ctx context.Context, eventID string) ([]*EventLink, error) {
+	event, _ := handler.GetEvent(ctx, eventID)
+	if event == nil {
+		return nil, nil
+	}
+	if event.Links == nil {
+		return make([]*EventLink, 0), nil
+	}
+
+	return event.Links, nil
+}
+
+// DeleteEventLink removes a link from the array and from its events
+func (handler *TestEventHandler) DeleteEventLink(ctx context.Context, eventID, linkID string) error {
+	for index, link := range handler.links {
+		if link.ID != linkID || (link.FromEventID != eventID && link.ToEventID != eventID) {
+			continue
+		}
+
+		handler.links = append(handler.links[:index], handler.links[index+1:]...)
+		now := time.Now()
+		for _, evt := range handler.events {
+			if evt.ID == link.FromEventID || evt.ID == link.ToEventID {
+				evt.Links = withoutLink(evt.Links, linkID)
+				evt.UpdatedAt = now
+			}
+		}
+		return nil
+	}
+
+	return errLinkNotFound
+}
+
+func withoutLink(links []*EventLink, linkID string) []*EventLink {
+	kept := make([]*EventLink, 0)
+	for _, link := range links {
+		if link.ID != linkID {
+			kept = append(kept, link)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+
+	return kept
+}
+
+// CreateFieldSchema adds a field schema to the array
+func (handler *TestEventHandler) CreateFieldSchema(ctx context.Context, schema *FieldSchema) error {
+	for _, existing := range handler.schemas {
+		if existing.Name == schema.Name {
+			return errFieldSchemaExists
+		}
+	}
+
+	handler.schemas = append(handler.schemas, schema)
+	return nil
+}
+
+// GetFieldSchemas gets the field schemas in the array, by name
+func (handler *TestEventHandler) GetFieldSchemas(ctx context.Context) ([]*FieldSchema, error) {
+	schemas := append(make([]*FieldSchema, 0), handler.schemas...)
+	sort.SliceStable(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+
+	return schemas, nil
+}
+
+// DeleteFieldSchema removes a field schema from the array
+func (handler *TestEventHandler) DeleteFieldSchema(ctx context.Context, name string) error {
+	for index, schema := range handler.schemas {
+		if schema.Name == name {
+			handler.schemas = append(handler.schemas[:index], handler.schemas[index+1:]...)
+			return nil
+		}
+	}
+
+	return errFieldSchemaNotFound
+}
+
+// CreateAttachments adds attachments of an event in the array
+func (handler *TestEventHandler) CreateAttachments(ctx context.Context, eventID string, attachments []*Attachment) error {
+	if _, err := handler.GetEvent(ctx, eventID); err != nil {
+		return errAttachmentEventNotFound
+	}
+
+	for _, attachment := range attachments {
+		attachment.ID = "attachment-" + strconv.Itoa(len(handler.attachments)+1)
+		attachment.EventID = eventID
+		handler.attachments = append(handler.attachments, attachment)
+	}
+	return nil
+}
+
+// GetAttachments gets the attachments of an event in the array, nil if there is no such event
+func (handler *TestEventHandler) GetAttachments(ctx context.Context, eventID string) ([]*Attachment, error) {
+	if _, err := handler.GetEvent(ctx, eventID); err != nil {
+		return nil, nil
+	}
+
+	attachments := make([]*Attachment, 0)
+	for _, attachment := range handler.attachments {
+		if attachment.EventID == eventID {
+			attachments = append(attachments, attachment)
+		}
+	}
+
+	return attachments, nil
+}
+
+// GetAttachment gets an attachment of an event from the array, nil if there is none
+func (handler *TestEventHandler) GetAttachment(ctx context.Context, eventID, attachmentID string) (*Attachment, error) {
+	attachments, err := handler.GetAttachments(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attachments {
+		if attachment.ID == attachmentID {
+			return attachment, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteAttachment removes an attachment of an event from the array
+func (handler *TestEventHandler) DeleteAttachment(ctx context.Context, eventID, attachmentID string) error {
+	for index, attachment := range handler.attachments {
+		if attachment.ID == attachmentID && attachment.EventID == eventID {
+			handler.attachments = append(handler.attachments[:index], handler.attachments[index+1:]...)
+			return nil
+		}
+	}
+
+	return errAttachmentNotFound
+}
+
+// FindAttachedBlobs tells which of the blobs an attachment in the array refers to
+func (handler *TestEventHandler) FindAttachedBlobs(ctx context.Context, hashes []string) (map[string]bool, error) {
+	wanted := make(map[string]bool)
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+
+	attached := make(map[string]bool)
+	for _, attachment := range handler.attachments {
+		if wanted[attachment.SHA256] {
+			attached[attachment.SHA256] = true
+		}
+	}
+
+	return attached, nil
+}
+
+// CreateGoal adds a new goal to the array
+func (handler *TestEventHandler) CreateGoal(ctx context.Context, goal *Goal) (string, error) {
+	goal.ID = "goal-" + strconv.Itoa(len(handler.goals)+1)
+	goal.DbID = int64(len(handler.goals) + 1)
+	goal.Status = statusActive
+	handler.goals = append(handler.goals, goal)
+	return goal.ID, nil
+}
+
+// GetGoals gets the goals in the array, leaving out the deleted ones
+func (handler *TestEventHandler) GetGoals(ctx context.Context) ([]*Goal, error) {
+	goals := make([]*Goal, 0)
+	for _, goal := range handler.goals {
+		if goal.Status != statusDeleted {
+			goals = append(goals, goal)
+		}
+	}
+
+	return goals, nil
+}
+
+// GetGoal gets a goal from the array, nil if there is no such goal
+func (handler *TestEventHandler) GetGoal(ctx context.Context, goalID string) (*Goal, error) {
+	for _, goal := range handler.goals {
+		if goal.ID == goalID && goal.Status != statusDeleted {
+			return goal, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteGoal marks a goal in the array deleted
+func (handler *TestEventHandler) DeleteGoal(ctx context.Context, goalID string) error {
+	goal, _ := handler.GetGoal(ctx, goalID)
+	if goal == nil {
+		return errGoalNotFound
+	}
+
+	goal.Status = statusDeleted
+	return nil
+}
+
+// StartTimer creates the start event and adds the timer to the array, if its owner has none yet
+func (handler *TestEventHandler) StartTimer(ctx context.Context, timer *Timer, event *Event) error {
+	existing, _ := handler.GetTimer(ctx, timer.Owner)
+	if existing != nil {
+		return errTimerRunning
+	}
+
+	eventID, err := handler.CreateEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	handler.lastTimerID++
+	timer.ID = "timer-" + strconv.Itoa(handler.lastTimerID)
+	timer.Status = statusActive
+	timer.StartEventID = eventID
+	timer.StartedAt = event.UserCreatedAt.UTC()
+	handler.timers = append(handler.timers, timer)
+	return nil
+}
+
+// GetTimer gets the timer of an owner from the array, nil if none is running
+func (handler *TestEventHandler) GetTimer(ctx context.Context, owner string) (*Timer, error) {
+	for _, timer := range handler.timers {
+		if timer.Owner == owner {
+			return timer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// PauseTimer pauses the timer of an owner in the array
+func (handler *TestEventHandler) PauseTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	timer, _ := handler.GetTimer(ctx, owner)
+	if timer == nil {
+		return nil, errTimerNotFound
+	}
+
+	return timer, timer.pause(at)
+}
+
+// ResumeTimer resumes the timer of an owner in the array
+func (handler *TestEventHandler) ResumeTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	timer, _ := handler.GetTimer(ctx, owner)
+	if timer == nil {
+		return nil, errTimerNotFound
+	}
+
+	return timer, timer.resume(at)
+}
+
+// StopTimer creates the end event of the timer of an owner, closing its start, and removes the timer from the array
+func (handler *TestEventHandler) StopTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	timer, _ := handler.GetTimer(ctx, owner)
+	if timer == nil {
+		return nil, errTimerNotFound
+	}
+
+	start, err := handler.GetEvent(ctx, timer.StartEventID)
+	if err == nil && start.Status != statusDeleted {
+		timer.EndEventID, err = handler.CreateEvent(ctx, newTimerEndEvent(start, at))
+		if err != nil {
+			return nil, err
+		}
+		_, err = handler.CreateEventLink(ctx, timer.EndEventID, linkTypeCloses, start.ID)
+		if err != nil && !errors.Is(err, errAlreadyClosed) {
+			return nil, err
+		}
+	}
+
+	timers := make([]*Timer, 0)
+	for _, other := range handler.timers {
+		if other != timer {
+			timers = append(timers, other)
+		}
+	}
+	handler.timers = timers
+
+	return timer, nil
+}
+
+// EndDueTimers stops the pomodoros in the array which are over by now, at the time they were over
+func (handler *TestEventHandler) EndDueTimers(ctx context.Context, owner string, now time.Time) (int, error) {
+	due := make([]*Timer, 0)
+	for _, timer := range handler.timers {
+		dueAt := timer.dueAt()
+		if (owner == "" || timer.Owner == owner) && !dueAt.IsZero() && !dueAt.After(now) {
+			due = append(due, timer)
+		}
+	}
+
+	for _, timer := range due {
+		_, err := handler.StopTimer(ctx, timer.Owner, timer.dueAt())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(due), nil
+}
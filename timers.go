@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityTimer = "timer"
+
+	actorTimers = "timers"
+
+	defaultTimersInterval = 30 * time.Second
+	maxPomodoroMinutes    = 24 * 60
+)
+
+var (
+	queryGetTimer = fmt.Sprintf(`
+		SELECT T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s
+		FROM %s T
+		WHERE T.%s = ?`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		timersColID, timersColOwner, timersColStartEventID, timersColStartedAt, timersColPausedAt, timersColPausedMs, timersColPomodoroMinutes,
+		timersTableName,
+		timersColOwner)
+
+	// pomodoros which are running, whether they are over yet is worked out by dueAt
+	queryGetRunningPomodoros = fmt.Sprintf(`
+		SELECT T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s, T.%s
+		FROM %s T
+		WHERE T.%s > 0 AND T.%s IS NULL`,
+		colDbID, colCreatedAt, colUpdatedAt, colStatus,
+		timersColID, timersColOwner, timersColStartEventID, timersColStartedAt, timersColPausedAt, timersColPausedMs, timersColPomodoroMinutes,
+		timersTableName,
+		timersColPomodoroMinutes, timersColPausedAt)
+
+	errTimerRunning   = newBadRequestError("a timer is already running")
+	errTimerNotFound  = newNotFoundError("no timer is running")
+	errTimerPaused    = newBadRequestError("timer is already paused")
+	errTimerNotPaused = newBadRequestError("timer isn't paused")
+)
+
+// Timer is the Db model for the timer a user has running, at most one. Starting it creates a start event, and
+// stopping it an end event closing the start. A pomodoro timer stops by itself once it ran for its minutes.
+type Timer struct {
+	DbRecord
+	ID           string `json:"id"`
+	Owner        string `json:"-"`
+	StartEventID string `json:"start_event_id"`
+	// EndEventID is set once the timer is stopped
+	EndEventID string     `json:"end_event_id,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	PausedAt   *time.Time `json:"paused_at,omitempty"`
+	// Paused is how long the timer was paused for, leaving out a pause still going
+	Paused          time.Duration `json:"-"`
+	PomodoroMinutes int           `json:"pomodoro_minutes,omitempty"`
+	// ElapsedSeconds and EndsAt are worked out when the timer is sent to the client
+	ElapsedSeconds float64    `json:"elapsed_seconds"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+}
+
+// TimerStartRequest is what a client starts a timer with, the rest of it making the start event
+type TimerStartRequest struct {
+	Title           string   `json:"title"`
+	Note            string   `json:"note"`
+	Tags            []string `json:"tags"`
+	TimeZone        string   `json:"time_zone"`
+	PomodoroMinutes int      `json:"pomodoro_minutes"`
+}
+
+// TimerResponse represents the response to send to client, in case of any timer route. Timer is nil when none
+// is running.
+type TimerResponse struct {
+	Timer *Timer `json:"timer"`
+}
+
+// timerOwner is who the timer of a request belongs to: the user of its token, or else the anonymous actor with
+// the address of the client, so that anonymous clients don't stop each other's timers
+func timerOwner(ctx context.Context) string {
+	return actorFromContext(ctx)
+}
+
+// newTimerStartEvent is the start event of a timer started now
+func newTimerStartEvent(request *TimerStartRequest, now time.Time) (*Event, error) {
+	if request.PomodoroMinutes < 0 || request.PomodoroMinutes > maxPomodoroMinutes {
+		return nil, fmt.Errorf("pomodoro_minutes should be between 0 and %d", maxPomodoroMinutes)
+	}
+
+	event := &Event{
+		Title:         request.Title,
+		Note:          request.Note,
+		UserCreatedAt: now,
+		TimeZone:      request.TimeZone,
+		Type:          &EventType{Value: eventTypeStart},
+		Tags:          make([]*EventTag, 0),
+	}
+	for _, tag := range request.Tags {
+		event.Tags = append(event.Tags, &EventTag{Value: tag})
+	}
+
+	return event, validateEvent(event)
+}
+
+// newTimerEndEvent is the end event of a timer, with the title, tags and time zone of its start
+func newTimerEndEvent(start *Event, at time.Time) *Event {
+	end := &Event{
+		Title:         start.Title,
+		UserCreatedAt: at,
+		TimeZone:      start.TimeZone,
+		Type:          &EventType{Value: eventTypeEnd},
+		Tags:          make([]*EventTag, 0),
+	}
+	for _, tag := range start.Tags {
+		end.Tags = append(end.Tags, &EventTag{Value: tag.Value})
+	}
+
+	return end
+}
+
+// elapsed is how long the timer ran till at, leaving out its pauses
+func (timer *Timer) elapsed(at time.Time) time.Duration {
+	if timer.PausedAt != nil {
+		at = *timer.PausedAt
+	}
+
+	return at.Sub(timer.StartedAt) - timer.Paused
+}
+
+// dueAt is when a running pomodoro is over, zero for a paused timer or one which isn't a pomodoro
+func (timer *Timer) dueAt() time.Time {
+	if timer.PomodoroMinutes <= 0 || timer.PausedAt != nil {
+		return time.Time{}
+	}
+
+	return timer.StartedAt.Add(timer.Paused + time.Duration(timer.PomodoroMinutes)*time.Minute)
+}
+
+func (timer *Timer) pause(at time.Time) error {
+	if timer.PausedAt != nil {
+		return errTimerPaused
+	}
+
+	at = at.UTC()
+	timer.PausedAt = &at
+	return nil
+}
+
+func (timer *Timer) resume(at time.Time) error {
+	if timer.PausedAt == nil {
+		return errTimerNotPaused
+	}
+
+	timer.Paused += at.Sub(*timer.PausedAt)
+	timer.PausedAt = nil
+	return nil
+}
+
+// withProgress fills in how long the timer ran till now, and when it ends if it is a pomodoro still running
+func (timer *Timer) withProgress(now time.Time) *Timer {
+	if timer == nil {
+		return nil
+	}
+
+	timer.ElapsedSeconds = timer.elapsed(now).Seconds()
+	timer.EndsAt = nil
+	if dueAt := timer.dueAt(); !dueAt.IsZero() && timer.EndEventID == "" {
+		timer.EndsAt = &dueAt
+	}
+
+	return timer
+}
+
+// runTimers stops the pomodoros which are over every interval, till ctx is done
+func runTimers(ctx context.Context, handler IEventsHandler, interval time.Duration, logger *slog.Logger) {
+	ctx = context.WithValue(ctx, ctxKeyActor, actorTimers)
+	ctx = context.WithValue(ctx, ctxKeyLogger, logger.With("worker", "timers"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ended, err := handler.EndDueTimers(ctx, "", time.Now())
+		if err != nil {
+			logger.Error("timers run failed", errorAttr(err))
+			continue
+		}
+		if ended > 0 {
+			logger.Info("timers run done", "ended", ended)
+		}
+	}
+}
+
+// StartTimer creates the start event of a timer and starts it, failing with errTimerRunning if its owner already
+// has one
+func (handler *EventsHandler) StartTimer(ctx context.Context, timer *Timer, event *Event) error {
+	fn := "StartTimer"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	timer.ID = uuid.New().String()
+	event.ID = uuid.New().String()
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		existing, err := txStuff.findTimer(ctx, queryGetTimer+" FOR UPDATE", timer.Owner)
+		if err != nil {
+			return deepError.New(fn, "find timer", err)
+		}
+		if len(existing) > 0 {
+			return errTimerRunning
+		}
+
+		err = insertEventWithTags(ctx, txStuff, event)
+		if err != nil {
+			return deepError.New(fn, "insert start event", err)
+		}
+		timer.StartEventID = event.ID
+		timer.StartedAt = event.UserCreatedAt
+
+		// the unique owner keeps a timer started at the same time on another device out
+		res, err := txStuff.exec(ctx,
+			fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?)",
+				timersTableName, timersColID, timersColOwner, timersColStartEventID, timersColStartedAt, timersColPomodoroMinutes),
+			timer.ID, timer.Owner, timer.StartEventID, timer.StartedAt, timer.PomodoroMinutes)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return deepError.New(fn, "rows affected", err)
+		}
+		if inserted == 0 {
+			return errTimerRunning
+		}
+		timer.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityTimer, timer.ID, auditActionCreate, nil, timer)
+	})
+	if err != nil {
+		logFailure(logger, "start timer failed", err)
+		return deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("timer started", "timer_id", timer.ID, "event_id", event.ID)
+	return nil
+}
+
+// GetTimer gets the timer of an owner, nil if none is running
+func (handler *EventsHandler) GetTimer(ctx context.Context, owner string) (*Timer, error) {
+	fn := "GetTimer"
+
+	timers, err := handler.dbStuff.findTimer(ctx, queryGetTimer, owner)
+	if err != nil {
+		return nil, deepError.New(fn, "find timer", err)
+	}
+	if len(timers) == 0 {
+		return nil, nil
+	}
+
+	return timers[0], nil
+}
+
+// PauseTimer pauses the timer of an owner
+func (handler *EventsHandler) PauseTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	return handler.updateTimer(ctx, owner, func(timer *Timer) error { return timer.pause(at) })
+}
+
+// ResumeTimer resumes the paused timer of an owner
+func (handler *EventsHandler) ResumeTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	return handler.updateTimer(ctx, owner, func(timer *Timer) error { return timer.resume(at) })
+}
+
+// updateTimer changes the pause of the timer of an owner, with its row locked
+func (handler *EventsHandler) updateTimer(ctx context.Context, owner string, change func(timer *Timer) error) (*Timer, error) {
+	fn := "updateTimer"
+
+	var timer *Timer
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		timers, err := txStuff.findTimer(ctx, queryGetTimer+" FOR UPDATE", owner)
+		if err != nil {
+			return deepError.New(fn, "find timer", err)
+		}
+		if len(timers) == 0 {
+			return errTimerNotFound
+		}
+
+		before := *timers[0]
+		timer = timers[0]
+		err = change(timer)
+		if err != nil {
+			return err
+		}
+
+		_, err = txStuff.exec(ctx,
+			fmt.Sprintf("UPDATE %s SET %s = ?, %s = ? WHERE %s = ?", timersTableName, timersColPausedAt, timersColPausedMs, colDbID),
+			timer.PausedAt, timer.Paused.Milliseconds(), timer.DbID)
+		if err != nil {
+			return deepError.New(fn, "update", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityTimer, timer.ID, auditActionUpdate, &before, timer)
+	})
+	if err != nil {
+		return nil, deepError.New(fn, "transaction", err)
+	}
+
+	return timer, nil
+}
+
+// StopTimer stops the timer of an owner, creating its end event at at
+func (handler *EventsHandler) StopTimer(ctx context.Context, owner string, at time.Time) (*Timer, error) {
+	fn := "StopTimer"
+	logger := loggerFromContext(ctx).With("fn", fn)
+
+	var timer *Timer
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		timers, err := txStuff.findTimer(ctx, queryGetTimer+" FOR UPDATE", owner)
+		if err != nil {
+			return deepError.New(fn, "find timer", err)
+		}
+		if len(timers) == 0 {
+			return errTimerNotFound
+		}
+
+		timer = timers[0]
+		return stopTimer(ctx, txStuff, timer, at)
+	})
+	if err != nil {
+		logFailure(logger, "stop timer failed", err)
+		return nil, deepError.New(fn, "transaction", err)
+	}
+
+	logger.Info("timer stopped", "timer_id", timer.ID, "event_id", timer.EndEventID)
+	return timer, nil
+}
+
+// EndDueTimers stops the pomodoros of an owner, or of everyone if owner is empty, which are over by now. Their end
+// events are at the time they were over, however late this runs.
+func (handler *EventsHandler) EndDueTimers(ctx context.Context, owner string, now time.Time) (int, error) {
+	fn := "EndDueTimers"
+
+	query, args := queryGetRunningPomodoros, make([]interface{}, 0)
+	if owner != "" {
+		query += fmt.Sprintf(" AND T.%s = ?", timersColOwner)
+		args = append(args, owner)
+	}
+	timers, err := handler.dbStuff.findTimer(ctx, query, args...)
+	if err != nil {
+		return 0, deepError.New(fn, "find running pomodoros", err)
+	}
+
+	ended := 0
+	for _, due := range timers {
+		if due.dueAt().After(now) {
+			continue
+		}
+
+		stopped := false
+		err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+			// the timer may have been paused or stopped since, so it is looked at again with its row locked
+			timers, err := txStuff.findTimer(ctx, queryGetTimer+" FOR UPDATE", due.Owner)
+			if err != nil {
+				return deepError.New(fn, "find timer", err)
+			}
+			if len(timers) == 0 || timers[0].ID != due.ID {
+				return nil
+			}
+			dueAt := timers[0].dueAt()
+			if dueAt.IsZero() || dueAt.After(now) {
+				return nil
+			}
+
+			stopped = true
+			return stopTimer(ctx, txStuff, timers[0], dueAt)
+		})
+		if err != nil {
+			return ended, deepError.New(fn, "transaction", err)
+		}
+		if stopped {
+			ended++
+		}
+	}
+
+	return ended, nil
+}
+
+// stopTimer creates the end event of a locked timer, closing its start event, and removes the timer. If the start
+// event was deleted meanwhile, there is nothing to end and only the timer is removed.
+func stopTimer(ctx context.Context, txStuff *dbStuff, timer *Timer, at time.Time) error {
+	fn := "stopTimer"
+
+	start, err := txStuff.lockEvent(ctx, timer.StartEventID)
+	if err != nil {
+		return deepError.New(fn, "lock start event", err)
+	}
+	if start != nil {
+		err = loadEventDetails(ctx, txStuff, start)
+		if err != nil {
+			return deepError.New(fn, "load start event details", err)
+		}
+
+		end := newTimerEndEvent(start, at)
+		end.ID = uuid.New().String()
+		err = insertEventWithTags(ctx, txStuff, end)
+		if err != nil {
+			return deepError.New(fn, "insert end event", err)
+		}
+		timer.EndEventID = end.ID
+
+		// the start may have been closed by hand already
+		closes, err := txStuff.count(ctx, queryCountClosesLinks, start.DbID, start.DbID)
+		if err != nil {
+			return deepError.New(fn, "count closes links", err)
+		}
+		if closes == 0 {
+			link := &EventLink{ID: uuid.New().String(), Type: linkTypeCloses, FromEventID: end.ID, ToEventID: start.ID}
+			err = txStuff.insertEventLink(ctx, link, end.DbID, start.DbID)
+			if err != nil {
+				return deepError.New(fn, "insert closes link", err)
+			}
+		}
+	}
+
+	_, err = txStuff.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", timersTableName, colDbID), timer.DbID)
+	if err != nil {
+		return deepError.New(fn, "delete", err)
+	}
+
+	return recordAudit(ctx, txStuff, auditEntityTimer, timer.ID, auditActionDelete, timer, nil)
+}
+
+// findTimer runs one of the timer queries
+func (dbStuff *dbStuff) findTimer(ctx context.Context, query string, args ...interface{}) ([]*Timer, error) {
+	fn := "findTimer"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, query, args...)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	timers := make([]*Timer, 0)
+	for rows.Next() {
+		timer := &Timer{}
+		var pausedAt sql.NullTime
+		var pausedMs int64
+		err = rows.Scan(&timer.DbID, &timer.CreatedAt, &timer.UpdatedAt, &timer.Status,
+			&timer.ID, &timer.Owner, &timer.StartEventID, &timer.StartedAt, &pausedAt, &pausedMs, &timer.PomodoroMinutes)
+		if err != nil {
+			return nil, deepError.New(fn, "scan", err)
+		}
+		if pausedAt.Valid {
+			at := pausedAt.Time.UTC()
+			timer.PausedAt = &at
+		}
+		timer.Paused = time.Duration(pausedMs) * time.Millisecond
+		timers = append(timers, timer)
+	}
+
+	return timers, nil
+}
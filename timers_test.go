@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestTimerPauses(t *testing.T) {
+	fn := "TestTimerPauses"
+
+	start := time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)
+	timer := &Timer{StartedAt: start, PomodoroMinutes: 25}
+	util.Test.AssertEquals(t, start.Add(25*time.Minute), timer.dueAt(), fn+": Wrong end")
+
+	util.Test.HandleIfTestError(t, timer.pause(start.Add(10*time.Minute)), fn)
+	util.Test.AssertEquals(t, errTimerPaused, timer.pause(start.Add(11*time.Minute)), fn+": Paused twice")
+	util.Test.AssertEquals(t, true, timer.dueAt().IsZero(), fn+": Paused pomodoro has an end")
+	util.Test.AssertEquals(t, 10*time.Minute, timer.elapsed(start.Add(time.Hour)), fn+": Paused timer kept going")
+
+	util.Test.HandleIfTestError(t, timer.resume(start.Add(15*time.Minute)), fn)
+	util.Test.AssertEquals(t, errTimerNotPaused, timer.resume(start.Add(16*time.Minute)), fn+": Resumed twice")
+	util.Test.AssertEquals(t, 15*time.Minute, timer.elapsed(start.Add(20*time.Minute)), fn+": Wrong elapsed")
+	util.Test.AssertEquals(t, start.Add(30*time.Minute), timer.dueAt(), fn+": Pause not added to the end")
+}
+
+func TestTimerRoutes(t *testing.T) {
+	fn := "TestTimerRoutes"
+	ctx := context.Background()
+
+	handler := &TestEventHandler{}
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: handler,
+	}
+
+	router.HandleFunc(routeStartTimer, StartTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routePauseTimer, PauseTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeResumeTimer, ResumeTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeStopTimer, StopTimerHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeCurrentTimer, GetCurrentTimerHandler(env)).Methods(http.MethodGet)
+
+	serve := func(actor, method, target, body string) (*httptest.ResponseRecorder, *Timer) {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		util.Test.HandleIfTestError(t, err, fn)
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyActor, actor))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		response := struct {
+			Data TimerResponse `json:"data"`
+		}{}
+		if rr.Code == http.StatusOK {
+			util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &response), fn)
+		}
+		return rr, response.Data.Timer
+	}
+
+	rr, started := serve("bob", http.MethodPost, routeStartTimer, `{"title": "coding", "tags": ["work"]}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+	rr, _ = serve("bob", http.MethodPost, routeStartTimer, `{"title": "reading"}`)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Second timer started")
+
+	// every anonymous client has a timer of its own
+	rr, _ = serve(actorAnonymous+"@10.0.0.1", http.MethodPost, routeStartTimer, `{"title": "reading"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code for anonymous")
+	_, current := serve(actorAnonymous+"@10.0.0.1", http.MethodGet, routeCurrentTimer, "")
+	util.Test.AssertEquals(t, "timer-2", current.ID, fn+": Anonymous timer not found")
+	_, current = serve(actorAnonymous+"@10.0.0.2", http.MethodGet, routeCurrentTimer, "")
+	util.Test.AssertEquals(t, true, current == nil, fn+": Anonymous timer shared")
+
+	_, current = serve("bob", http.MethodGet, routeCurrentTimer, "")
+	util.Test.AssertEquals(t, started.ID, current.ID, fn+": Wrong current timer")
+
+	rr, paused := serve("bob", http.MethodPost, routePauseTimer, "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Timer not paused")
+	util.Test.AssertEquals(t, true, paused.PausedAt != nil, fn+": No pause time")
+	rr, _ = serve("bob", http.MethodPost, routePauseTimer, "")
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Paused twice")
+	rr, _ = serve("bob", http.MethodPost, routeResumeTimer, "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Timer not resumed")
+
+	rr, stopped := serve("bob", http.MethodPost, routeStopTimer, "")
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Timer not stopped")
+	end, err := handler.GetEvent(ctx, stopped.EndEventID)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, eventTypeEnd, end.Type.Value, fn+": Wrong end event type")
+	util.Test.AssertEquals(t, "work", end.Tags[0].Value, fn+": Tags not kept")
+	util.Test.AssertEquals(t, started.StartEventID, end.Links[0].ToEventID, fn+": End doesn't close the start")
+
+	_, current = serve("bob", http.MethodGet, routeCurrentTimer, "")
+	util.Test.AssertEquals(t, true, current == nil, fn+": Stopped timer still running")
+	rr, _ = serve("bob", http.MethodPost, routeStopTimer, "")
+	util.Test.AssertEquals(t, http.StatusNotFound, rr.Code, fn+": Stopped twice")
+
+	// a pomodoro which is over is ended at the time it was over, before the worker gets to it
+	startedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	pomodoro := &Timer{Owner: "bob", PomodoroMinutes: 25}
+	err = handler.StartTimer(ctx, pomodoro, &Event{Title: "focus", UserCreatedAt: startedAt, Type: &EventType{Value: eventTypeStart}})
+	util.Test.HandleIfTestError(t, err, fn)
+
+	_, current = serve("bob", http.MethodGet, routeCurrentTimer, "")
+	util.Test.AssertEquals(t, true, current == nil, fn+": Pomodoro still running")
+	end, err = handler.GetEvent(ctx, pomodoro.EndEventID)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, true, end.UserCreatedAt.Equal(startedAt.Add(25*time.Minute)), fn+": Wrong pomodoro end")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// tzEvent asks for every event in its own local time
+	tzEvent = "event"
+
+	bucketHour    = "hour"
+	bucketWeekday = "weekday"
+	bucketDate    = "date"
+
+	// no time zone is further than this from UTC
+	maxUTCOffset = 18 * 60 * 60
+)
+
+// locations caches the time zones loaded, as reading them from the tz database every time is slow
+var locations sync.Map
+
+// EventBucket is the number of events whose local time falls in a bucket, like an hour of the day
+type EventBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// EventStatsResponse represents the response to send to client, in case of a get event stats.
+// TimeZone is empty when every event was bucketed by its own local time.
+type EventStatsResponse struct {
+	Bucket   string         `json:"bucket"`
+	TimeZone string         `json:"time_zone"`
+	Buckets  []*EventBucket `json:"buckets"`
+}
+
+// loadLocation loads an IANA time zone. Unlike time.LoadLocation, the server's own zone, Local, isn't one.
+func loadLocation(name string) (*time.Location, error) {
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	locations.Store(name, location)
+	return location, nil
+}
+
+func validateTimeZone(event *Event) error {
+	if event.TimeZone != "" {
+		_, err := loadLocation(event.TimeZone)
+		if err != nil {
+			return err
+		}
+	}
+	if event.UTCOffset < -maxUTCOffset || event.UTCOffset > maxUTCOffset {
+		return errors.New("utc_offset should be within 18 hours")
+	}
+
+	return nil
+}
+
+// setUTCOffset works out the offset of the event's local time, before UserCreatedAt is turned to UTC.
+// The time zone, if there is one, decides it. Else the offset the time was sent with does, and a time sent
+// in UTC keeps the utc_offset sent along.
+func (event *Event) setUTCOffset() {
+	if event.TimeZone != "" {
+		location, err := loadLocation(event.TimeZone)
+		if err == nil {
+			_, event.UTCOffset = event.UserCreatedAt.In(location).Zone()
+			return
+		}
+	}
+
+	if _, offset := event.UserCreatedAt.Zone(); offset != 0 {
+		event.UTCOffset = offset
+	}
+}
+
+// location is where the event happened, its time zone if known or else its offset
+func (event *Event) location() *time.Location {
+	if event.TimeZone != "" {
+		location, err := loadLocation(event.TimeZone)
+		if err == nil {
+			return location
+		}
+	}
+
+	return time.FixedZone("", event.UTCOffset)
+}
+
+// localTime is the time of the event as it was for the person it happened to
+func (event *Event) localTime() time.Time {
+	return event.UserCreatedAt.In(event.location())
+}
+
+// parseTimeZone reads the tz a client asked for. An empty one means UTC, while tzEvent means every event
+// in its own local time, both given back as a nil location.
+func parseTimeZone(raw string) (*time.Location, error) {
+	if raw == "" || raw == tzEvent {
+		return nil, nil
+	}
+
+	return loadLocation(raw)
+}
+
+// eventsInLocation gives copies of the events with their times in location, or in their own local time if it is nil.
+// The events themselves are left alone, they may be shared.
+func eventsInLocation(events []*Event, location *time.Location) []*Event {
+	localEvents := make([]*Event, 0)
+	for _, event := range events {
+		localEvent := *event
+		if location != nil {
+			localEvent.UserCreatedAt = event.UserCreatedAt.In(location)
+		} else {
+			localEvent.UserCreatedAt = event.localTime()
+		}
+		localEvents = append(localEvents, &localEvent)
+	}
+
+	return localEvents
+}
+
+// bucketEvents counts the events by the hour, weekday or date of their time in location, or of their own local time
+// if it is nil. Every hour and weekday is there even without events, while dates only are with some, oldest first.
+func bucketEvents(events []*Event, bucket string, location *time.Location) ([]*EventBucket, error) {
+	var keys []string
+	var keyOf func(t time.Time) string
+	switch bucket {
+	case bucketHour:
+		for hour := 0; hour < 24; hour++ {
+			keys = append(keys, fmt.Sprintf("%02d", hour))
+		}
+		keyOf = func(t time.Time) string { return fmt.Sprintf("%02d", t.Hour()) }
+	case bucketWeekday:
+		for day := time.Monday; day <= time.Saturday; day++ {
+			keys = append(keys, day.String())
+		}
+		keys = append(keys, time.Sunday.String())
+		keyOf = func(t time.Time) string { return t.Weekday().String() }
+	case bucketDate:
+		keyOf = func(t time.Time) string { return t.Format("2006-01-02") }
+	default:
+		return nil, fmt.Errorf("bucket should be %s, %s or %s", bucketHour, bucketWeekday, bucketDate)
+	}
+
+	counts := make(map[string]int)
+	for _, event := range eventsInLocation(events, location) {
+		counts[keyOf(event.UserCreatedAt)]++
+	}
+
+	if keys == nil {
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	buckets := make([]*EventBucket, 0)
+	for _, key := range keys {
+		buckets = append(buckets, &EventBucket{Key: key, Count: counts[key]})
+	}
+
+	return buckets, nil
+}
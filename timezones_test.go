@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+func TestSetUTCOffset(t *testing.T) {
+	fn := "TestSetUTCOffset"
+
+	tests := []struct {
+		name     string
+		event    *Event
+		expected int
+	}{
+		{"time zone", &Event{UserCreatedAt: time.Date(2020, 7, 1, 8, 0, 0, 0, time.UTC), TimeZone: "Europe/Berlin"}, 2 * 60 * 60},
+		{"time zone in winter", &Event{UserCreatedAt: time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), TimeZone: "Europe/Berlin"}, 60 * 60},
+		{"offset of the time", &Event{UserCreatedAt: time.Date(2020, 1, 1, 8, 0, 0, 0, time.FixedZone("", -5*60*60))}, -5 * 60 * 60},
+		{"utc keeps the sent offset", &Event{UserCreatedAt: time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), UTCOffset: 19800}, 19800},
+	}
+
+	for _, test := range tests {
+		test.event.setUTCOffset()
+		util.Test.AssertEquals(t, test.expected, test.event.UTCOffset, fn+": Wrong offset for "+test.name)
+	}
+
+	for _, event := range []*Event{{TimeZone: "Mars/Olympus"}, {TimeZone: "Local"}, {UTCOffset: 20 * 60 * 60}} {
+		util.Test.AssertEquals(t, true, validateTimeZone(event) != nil, fn+": Invalid time zone accepted")
+	}
+}
+
+func TestBucketEvents(t *testing.T) {
+	fn := "TestBucketEvents"
+
+	// both at 7 in the morning, local time, on a monday
+	events := []*Event{
+		{UserCreatedAt: time.Date(2020, 1, 6, 6, 0, 0, 0, time.UTC), TimeZone: "Europe/Berlin"},
+		{UserCreatedAt: time.Date(2020, 1, 6, 12, 0, 0, 0, time.UTC), UTCOffset: -5 * 60 * 60},
+	}
+
+	buckets, err := bucketEvents(events, bucketHour, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 24, len(buckets), fn+": Wrong hours")
+	util.Test.AssertEquals(t, &EventBucket{Key: "07", Count: 2}, buckets[7], fn+": Not bucketed by local time")
+
+	buckets, err = bucketEvents(events, bucketHour, time.UTC)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, 1, buckets[6].Count, fn+": Not bucketed in the asked time zone")
+	util.Test.AssertEquals(t, 1, buckets[12].Count, fn+": Not bucketed in the asked time zone")
+
+	location, err := loadLocation("Pacific/Auckland")
+	util.Test.HandleIfTestError(t, err, fn)
+	buckets, err = bucketEvents(events, bucketDate, location)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, []*EventBucket{{Key: "2020-01-06", Count: 1}, {Key: "2020-01-07", Count: 1}}, buckets, fn+": Wrong dates")
+
+	buckets, err = bucketEvents(events, bucketWeekday, nil)
+	util.Test.HandleIfTestError(t, err, fn)
+	util.Test.AssertEquals(t, &EventBucket{Key: "Monday", Count: 2}, buckets[0], fn+": Wrong weekday")
+
+	_, err = bucketEvents(events, "month", nil)
+	util.Test.AssertEquals(t, true, err != nil, fn+": Invalid bucket accepted")
+}
+
+func TestEventTimeZoneRoutes(t *testing.T) {
+	fn := "TestEventTimeZoneRoutes"
+
+	router := mux.NewRouter()
+	env := &env{
+		EventsHandler: &TestEventHandler{},
+	}
+
+	router.HandleFunc(routeCreateEvent, CreateEventHandler(env)).Methods(http.MethodPost)
+	router.HandleFunc(routeGetEvents, GetEventsHandler(env)).Methods(http.MethodGet)
+	router.HandleFunc(routeGetEventStats, GetEventStatsHandler(env)).Methods(http.MethodGet)
+
+	rr := serveTest(t, fn, router, http.MethodPost, routeCreateEvent, `{"title": "run", "type": {"value": "start"}, "created_at": "2020-01-06T07:00:00+05:30"}`)
+	util.Test.AssertEquals(t, http.StatusOK, rr.Code, fn+": Wrong Status Code")
+
+	rr = serveTest(t, fn, router, http.MethodPost, routeCreateEvent, `{"title": "run", "type": {"value": "start"}, "created_at": "2020-01-06T07:00:00Z", "time_zone": "Nowhere/Town"}`)
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Unknown time zone accepted")
+
+	events := struct {
+		Data EventsResponse `json:"data"`
+	}{}
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEvents, "")
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &events), fn)
+	util.Test.AssertEquals(t, 19800, events.Data.Events[0].UTCOffset, fn+": Offset not kept")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"created_at":"2020-01-06T01:30:00Z"`), fn+": Not in UTC by default")
+
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEvents+"?tz=event", "")
+	util.Test.AssertEquals(t, true, strings.Contains(rr.Body.String(), `"created_at":"2020-01-06T07:00:00+05:30"`), fn+": Not in local time")
+
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEventStats+"?bucket=hour&tz=America/New_York", "")
+	stats := struct {
+		Data EventStatsResponse `json:"data"`
+	}{}
+	util.Test.HandleIfTestError(t, json.Unmarshal(rr.Body.Bytes(), &stats), fn)
+	util.Test.AssertEquals(t, 1, stats.Data.Buckets[20].Count, fn+": Not bucketed in the asked time zone")
+
+	rr = serveTest(t, fn, router, http.MethodGet, routeGetEventStats+"?tz=Nowhere/Town", "")
+	util.Test.AssertEquals(t, http.StatusBadRequest, rr.Code, fn+": Unknown time zone accepted")
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jforcode/Go-DeepError"
+)
+
+const (
+	auditEntityUser     = "user"
+	auditEntityAPIToken = "api_token"
+	auditActionIssue    = "issue"
+
+	tokenPrefix = "et_"
+	tokenBytes  = 32
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,100}$`)
+	// the actors the server itself acts as, which a user can't pass for
+	reservedUsernames = map[string]bool{
+		actorAnonymous: true, actorAdmin: true, actorTemplates: true, actorRetention: true, actorTimers: true,
+	}
+
+	errInvalidUsername  = newBadRequestError("username should be 1 to 100 letters, digits, _, . or -")
+	errReservedUsername = newBadRequestError("username is reserved")
+	errUserExists       = newBadRequestError("user already exists")
+	errUserNotFound     = newNotFoundError("user not found")
+
+	queryGetUser = fmt.Sprintf(`
+		SELECT U.%s, U.%s, U.%s, U.%s, U.%s
+		FROM %s U
+		WHERE U.%s = ?`,
+		colDbID, usersColUsername, colCreatedAt, colUpdatedAt, colStatus,
+		usersTableName,
+		usersColUsername)
+
+	// a token counts only while both it and its user are active
+	queryGetTokenUser = fmt.Sprintf(`
+		SELECT U.%s
+		FROM %s T JOIN %s U ON U.%s = T.%s
+		WHERE T.%s = ? AND T.%s = '%s' AND U.%s = '%s' AND (T.%s IS NULL OR T.%s > UTC_TIMESTAMP(6))`,
+		usersColUsername,
+		apiTokensTableName, usersTableName, colDbID, apiTokensColUserID,
+		apiTokensColTokenHash, colStatus, statusActive, colStatus, statusActive, apiTokensColExpiresAt, apiTokensColExpiresAt)
+)
+
+// User is the Db model for someone calling the api
+type User struct {
+	DbRecord
+	Username string `json:"username"`
+}
+
+// APIToken is the Db model for a token a user calls the api with. Only a hash of the token is kept.
+type APIToken struct {
+	DbRecord
+	UserID    int64      `json:"-"`
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Authenticator tells who a bearer token belongs to
+type Authenticator interface {
+	// Authenticate returns the username of the token, empty if the token is unknown, revoked or expired
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+// UsersHandler manages users and their api tokens in mysql
+type UsersHandler struct {
+	dbStuff *dbStuff
+}
+
+// Init sets up the handler on db. Users don't go through the event type and tag cache, so it gets none,
+// as a second one would take over the cache stats of the events handler.
+func (handler *UsersHandler) Init(db *sql.DB, options HandlerOptions) {
+	options.CacheSize = 0
+	handler.dbStuff = newDbStuff(db, options)
+}
+
+// CreateUser adds a user with the username
+func (handler *UsersHandler) CreateUser(ctx context.Context, username string) (*User, error) {
+	fn := "CreateUser"
+
+	if !usernamePattern.MatchString(username) {
+		return nil, errInvalidUsername
+	}
+	if reservedUsernames[username] {
+		return nil, errReservedUsername
+	}
+
+	user := &User{Username: username}
+	err := handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		existing, err := txStuff.findUser(ctx, username)
+		if err != nil {
+			return deepError.New(fn, "find user", err)
+		}
+		if existing != nil {
+			return errUserExists
+		}
+
+		res, err := txStuff.exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (?)", usersTableName, usersColUsername), username)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+		user.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityUser, username, auditActionCreate, nil, user)
+	})
+	if errors.Is(err, errUserExists) {
+		return nil, errUserExists
+	}
+	if err != nil {
+		return nil, deepError.New(fn, "create user", err)
+	}
+
+	return user, nil
+}
+
+// IssueToken makes a new token for the user, expiring after ttl unless it is zero.
+// The token is only ever returned here, the db keeps its hash.
+func (handler *UsersHandler) IssueToken(ctx context.Context, username, name string, ttl time.Duration) (string, *APIToken, error) {
+	fn := "IssueToken"
+
+	raw := make([]byte, tokenBytes)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", nil, deepError.New(fn, "random", err)
+	}
+	token := tokenPrefix + hex.EncodeToString(raw)
+
+	apiToken := &APIToken{Name: name}
+	if ttl > 0 {
+		expiresAt := time.Now().UTC().Add(ttl)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	err = handler.dbStuff.withTx(ctx, func(txStuff *dbStuff) error {
+		user, err := txStuff.findUser(ctx, username)
+		if err != nil {
+			return deepError.New(fn, "find user", err)
+		}
+		if user == nil || user.Status != statusActive {
+			return errUserNotFound
+		}
+		apiToken.UserID = user.DbID
+
+		res, err := txStuff.exec(ctx,
+			fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)",
+				apiTokensTableName, apiTokensColUserID, apiTokensColName, apiTokensColTokenHash, apiTokensColExpiresAt),
+			apiToken.UserID, apiToken.Name, hashToken(token), apiToken.ExpiresAt)
+		if err != nil {
+			return deepError.New(fn, "insert", err)
+		}
+		apiToken.DbID, err = getDbID(res)
+		if err != nil {
+			return deepError.New(fn, "get db id", err)
+		}
+
+		return recordAudit(ctx, txStuff, auditEntityAPIToken, username+"/"+name, auditActionIssue, nil, apiToken)
+	})
+	if errors.Is(err, errUserNotFound) {
+		return "", nil, errUserNotFound
+	}
+	if err != nil {
+		return "", nil, deepError.New(fn, "issue token", err)
+	}
+
+	return token, apiToken, nil
+}
+
+// Authenticate returns the username the token belongs to, empty if there is no such usable token
+func (handler *UsersHandler) Authenticate(ctx context.Context, token string) (string, error) {
+	fn := "Authenticate"
+
+	queryCtx, cancel := handler.dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := handler.dbStuff.query(queryCtx, queryGetTokenUser, hashToken(token))
+	if err != nil {
+		return "", deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	username := ""
+	if rows.Next() {
+		err = rows.Scan(&username)
+		if err != nil {
+			return "", deepError.New(fn, "scan", err)
+		}
+	}
+
+	return username, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (dbStuff *dbStuff) findUser(ctx context.Context, username string) (*User, error) {
+	fn := "findUser"
+
+	ctx, cancel := dbStuff.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbStuff.query(ctx, queryGetUser, username)
+	if err != nil {
+		return nil, deepError.New(fn, "query", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	user := &User{}
+	err = rows.Scan(&user.DbID, &user.Username, &user.CreatedAt, &user.UpdatedAt, &user.Status)
+	if err != nil {
+		return nil, deepError.New(fn, "scan", err)
+	}
+
+	return user, nil
+}
+
+// bearerToken is the token in the Authorization header, empty if there is none
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// AuthMiddleware makes the user of a bearer token the actor of the request, in place of the anonymous one
+// set by ActorMiddleware, so it has to run after it. Requests without a token stay anonymous,
+// while unknown, revoked or expired tokens are turned away with 401.
+func AuthMiddleware(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			username, err := authenticator.Authenticate(r.Context(), token)
+			if err != nil {
+				handleHTTPError(w, r, err)
+				return
+			}
+			if username == "" {
+				loggerFromContext(r.Context()).Info("invalid token", "method", r.Method, "path", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				handleHTTPFailure(w, http.StatusUnauthorized, nil, &ResponseError{
+					Code:    errCodeUnauthorized,
+					Message: "invalid or expired token",
+				})
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ctxKeyActor, username)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jforcode/Go-Util"
+)
+
+// testAuthenticator knows tokens by a map of token to username
+type testAuthenticator map[string]string
+
+func (authenticator testAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	return authenticator[token], nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	fn := "TestAuthMiddleware"
+
+	router := mux.NewRouter()
+	router.HandleFunc(routeGetHealth, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, actorFromContext(r.Context()))
+	})
+	handler := AuthMiddleware(testAuthenticator{"et_good": "bob"})(router)
+	handler = ActorMiddleware()(handler)
+
+	cases := []struct {
+		authorization string
+		status        int
+		actor         string
+	}{
+		{"", http.StatusOK, actorAnonymous + "@192.0.2.1"},
+		{"Bearer et_good", http.StatusOK, "bob"},
+		{"Basic Ym9iOnNlY3JldA==", http.StatusOK, actorAnonymous + "@192.0.2.1"},
+		{"Bearer et_bad", http.StatusUnauthorized, ""},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(http.MethodGet, routeGetHealth, nil)
+		util.Test.HandleIfTestError(t, err, fn)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		util.Test.AssertEquals(t, c.status, rr.Code, fn+": Wrong Status Code for "+c.authorization)
+		if c.status == http.StatusOK {
+			util.Test.AssertEquals(t, c.actor, rr.Body.String(), fn+": Wrong actor for "+c.authorization)
+		}
+	}
+}
+
+func TestCreateUserReserved(t *testing.T) {
+	fn := "TestCreateUserReserved"
+
+	handler := &UsersHandler{}
+	for _, username := range []string{actorAnonymous, actorAdmin, actorTimers} {
+		_, err := handler.CreateUser(context.Background(), username)
+		util.Test.AssertEquals(t, errReservedUsername, err, fn+": Reserved username accepted: "+username)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	fn := "TestHashToken"
+
+	util.Test.AssertEquals(t, 64, len(hashToken("et_token")), fn+": Wrong hash length")
+	util.Test.AssertEquals(t, hashToken("et_token"), hashToken("et_token"), fn+": Hash not stable")
+	util.Test.AssertEquals(t, false, hashToken("et_token") == hashToken("et_other"), fn+": Hashes collide")
+}